@@ -9,10 +9,13 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/sashabaranov/go-openai"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/webfetch"
 )
 
 // AIClient handles interactions with OpenAI and Grok APIs
@@ -21,6 +24,7 @@ type AIClient struct {
 	openaiAPIKey string
 	xaiAPIKey    string
 	httpClient   *http.Client
+	fetcher      *webfetch.Fetcher
 	logger       *slog.Logger
 }
 
@@ -38,50 +42,63 @@ func NewAIClient(openaiAPIKey, xaiAPIKey string, logger *slog.Logger) *AIClient
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
-		logger: logger,
+		fetcher: webfetch.NewFetcher(30*time.Second, MaxImageBytes),
+		logger:  logger,
 	}
 }
 
 // AskClient sends a prompt to OpenAI or Grok with a system message and returns the response
 func (c *AIClient) AskClient(ctx context.Context, prompt, systemMessage, model, provider string, maxTokens int) (string, error) {
+	return c.Chat(ctx, []ChatMessage{{Role: RoleUser, Content: prompt}}, systemMessage, model, provider, maxTokens)
+}
+
+// Chat sends a multi-turn conversation to OpenAI or Grok and returns the next assistant reply
+func (c *AIClient) Chat(ctx context.Context, messages []ChatMessage, systemMessage, model, provider string, maxTokens int) (string, error) {
+	promptLength := 0
+	for _, msg := range messages {
+		promptLength += len(msg.Content)
+	}
+
 	c.logger.InfoContext(ctx, "sending AI request",
 		"provider", provider,
 		"model", model,
 		"max_tokens", maxTokens,
-		"prompt_length", len(prompt))
+		"turns", len(messages),
+		"prompt_length", promptLength)
 
 	switch provider {
 	case ProviderOpenAI:
 		if c.openaiClient == nil {
 			return "", NewValidationError("OPENAI_API_KEY", "OpenAI support is deprecated; set OPENAI_API_KEY to enable overrides")
 		}
-		return c.askOpenAI(ctx, prompt, systemMessage, model, maxTokens)
+		return c.askOpenAI(ctx, messages, systemMessage, model, maxTokens)
 	default:
-		return c.askGrok(ctx, prompt, systemMessage, model, maxTokens)
+		return c.askGrok(ctx, messages, systemMessage, model, maxTokens)
 	}
 }
 
 // askOpenAI sends a request to OpenAI API
-func (c *AIClient) askOpenAI(ctx context.Context, prompt, systemMessage, model string, maxTokens int) (string, error) {
+func (c *AIClient) askOpenAI(ctx context.Context, messages []ChatMessage, systemMessage, model string, maxTokens int) (string, error) {
 	// Add timeout to context
 	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
+	chatMessages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: systemMessage,
+		},
+	}
+	for _, msg := range messages {
+		chatMessages = append(chatMessages, openai.ChatCompletionMessage{Role: msg.Role, Content: msg.Content})
+	}
+
 	resp, err := c.openaiClient.CreateChatCompletion(
 		ctx,
 		openai.ChatCompletionRequest{
 			Model:     model,
 			MaxTokens: maxTokens,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: systemMessage,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
+			Messages:  chatMessages,
 		},
 	)
 
@@ -103,7 +120,7 @@ func (c *AIClient) askOpenAI(ctx context.Context, prompt, systemMessage, model s
 }
 
 // askGrok sends a request to Grok API
-func (c *AIClient) askGrok(ctx context.Context, prompt, systemMessage, model string, maxTokens int) (string, error) {
+func (c *AIClient) askGrok(ctx context.Context, messages []ChatMessage, systemMessage, model string, maxTokens int) (string, error) {
 	if c.xaiAPIKey == "" {
 		return "", NewValidationError("XAI_API_KEY", "environment variable not set")
 	}
@@ -121,12 +138,16 @@ func (c *AIClient) askGrok(ctx context.Context, prompt, systemMessage, model str
 		maxTokens = DefaultMaxTokens
 	}
 
+	grokMessages := []map[string]string{
+		{"role": "system", "content": systemMessage},
+	}
+	for _, msg := range messages {
+		grokMessages = append(grokMessages, map[string]string{"role": msg.Role, "content": msg.Content})
+	}
+
 	requestBody := map[string]interface{}{
-		"model": grokModel,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemMessage},
-			{"role": "user", "content": prompt},
-		},
+		"model":      grokModel,
+		"messages":   grokMessages,
 		"max_tokens": maxTokens,
 	}
 
@@ -181,6 +202,91 @@ func (c *AIClient) askGrok(ctx context.Context, prompt, systemMessage, model str
 	return content, nil
 }
 
+// Moderate classifies text with the OpenAI moderation endpoint and returns the flagged categories
+func (c *AIClient) Moderate(ctx context.Context, text string) (*ModerationResult, error) {
+	if c.openaiClient == nil {
+		return nil, NewValidationError("OPENAI_API_KEY", "moderation endpoint requires OPENAI_API_KEY")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	resp, err := c.openaiClient.Moderations(ctx, openai.ModerationRequest{
+		Input: text,
+		Model: DefaultModerationModel,
+	})
+	if err != nil {
+		c.logger.ErrorContext(ctx, "OpenAI moderation error", "error", err)
+		return nil, fmt.Errorf("OpenAI moderation error: %w", err)
+	}
+
+	if len(resp.Results) == 0 {
+		return nil, NewAPIError("OpenAI", 0, "no moderation results", nil)
+	}
+
+	// Round-trip through JSON to map category flags to their endpoint names
+	encoded, err := json.Marshal(resp.Results[0].Categories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode moderation categories: %w", err)
+	}
+	var categories map[string]bool
+	if err := json.Unmarshal(encoded, &categories); err != nil {
+		return nil, fmt.Errorf("failed to decode moderation categories: %w", err)
+	}
+
+	result := &ModerationResult{Flagged: resp.Results[0].Flagged}
+	for category, flagged := range categories {
+		if flagged {
+			result.Categories = append(result.Categories, category)
+		}
+	}
+	sort.Strings(result.Categories)
+
+	return result, nil
+}
+
+// Embed returns an embedding for each text using the OpenAI embeddings endpoint, sending
+// at most MaxEmbeddingBatch texts per request
+func (c *AIClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if c.openaiClient == nil {
+		return nil, NewValidationError("OPENAI_API_KEY", "embeddings require OPENAI_API_KEY")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += MaxEmbeddingBatch {
+		batch := texts[start:min(start+MaxEmbeddingBatch, len(texts))]
+		resp, err := c.openaiClient.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+			Input:      batch,
+			Model:      DefaultEmbeddingModel,
+			Dimensions: EmbeddingDimensions,
+		})
+		if err != nil {
+			c.logger.ErrorContext(ctx, "OpenAI embeddings error", "error", err)
+			return nil, fmt.Errorf("OpenAI embeddings error: %w", err)
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, NewAPIError("OpenAI", 0, fmt.Sprintf("expected %d embeddings, got %d", len(batch), len(resp.Data)), nil)
+		}
+
+		// Results carry their input index, which is the only ordering guarantee
+		ordered := make([][]float32, len(batch))
+		for _, item := range resp.Data {
+			if item.Index < 0 || item.Index >= len(batch) {
+				return nil, NewAPIError("OpenAI", 0, fmt.Sprintf("embedding index %d out of range", item.Index), nil)
+			}
+			ordered[item.Index] = item.Embedding
+		}
+		vectors = append(vectors, ordered...)
+	}
+
+	c.logger.InfoContext(ctx, "received OpenAI embeddings", "count", len(vectors))
+
+	return vectors, nil
+}
+
 // ImageOpinionOpenAI sends an image to OpenAI's vision endpoint
 func (c *AIClient) ImageOpinionOpenAI(ctx context.Context, imageURL, systemMessage, model string, maxTokens int, customPrompt *string) (string, error) {
 	// Add timeout to context
@@ -368,33 +474,15 @@ func (c *AIClient) ImageOpinionGrok(ctx context.Context, imageURL, systemMessage
 	return content, nil
 }
 
-// downloadAndEncodeImage downloads an image from URL and returns base64 encoded string
+// downloadAndEncodeImage downloads an image from URL and returns base64 encoded string.
+// Downloads go through the SSRF-safe fetcher since the URL is user supplied.
 func (c *AIClient) downloadAndEncodeImage(ctx context.Context, imageURL string) (string, error) {
-	// Add timeout to context if not already set
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.fetcher.Fetch(ctx, imageURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to download image: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download image: status %d", resp.StatusCode)
-	}
-
-	imageData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read image data: %w", err)
-	}
 
-	return base64.StdEncoding.EncodeToString(imageData), nil
+	return base64.StdEncoding.EncodeToString(resp.Body), nil
 }
 
 // SuggestMessageBreaks uses AI to intelligently break a message into natural chunks
@@ -429,7 +517,7 @@ Example output: "I think pizza is great. It has cheese and sauce.<<<BREAK>>>But
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	response, err := c.askGrok(ctx, userPrompt, systemPrompt, DefaultGrokModel, 1000)
+	response, err := c.askGrok(ctx, []ChatMessage{{Role: RoleUser, Content: userPrompt}}, systemPrompt, DefaultGrokModel, 1000)
 	if err != nil {
 		c.logger.ErrorContext(ctx, "failed to get message breaks, falling back to simple chunking", "error", err)
 		// Fallback to simple paragraph-based chunking
@@ -7,12 +7,21 @@ type Client interface {
 	// AskClient sends a prompt to an AI provider and returns the response
 	AskClient(ctx context.Context, prompt, systemMessage, model, provider string, maxTokens int) (string, error)
 
+	// Chat sends a multi-turn conversation to an AI provider and returns the next assistant reply
+	Chat(ctx context.Context, messages []ChatMessage, systemMessage, model, provider string, maxTokens int) (string, error)
+
 	// ImageOpinionOpenAI sends an image to OpenAI's vision endpoint
 	ImageOpinionOpenAI(ctx context.Context, imageURL, systemMessage, model string, maxTokens int, customPrompt *string) (string, error)
 
 	// ImageOpinionGrok sends an image to Grok's vision endpoint
 	ImageOpinionGrok(ctx context.Context, imageURL, systemMessage string, customPrompt *string) (string, error)
 
+	// Moderate classifies text with the provider moderation endpoint
+	Moderate(ctx context.Context, text string) (*ModerationResult, error)
+
+	// Embed returns an embedding vector for each text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+
 	// SuggestMessageBreaks uses AI to break a message into natural chunks for human-like delivery
 	SuggestMessageBreaks(ctx context.Context, message string) ([]string, error)
 }
@@ -14,4 +14,29 @@ const (
 	DefaultOpenAIVisionModel        = "gpt-4o"
 	DefaultOpenAIVisionModelVersion = "2024-05-13"
 	DefaultMaxTokens                = 1500
+	MaxImageBytes                   = 20 << 20 // 20 MiB
+	DefaultModerationModel          = "omni-moderation-latest"
+	DefaultEmbeddingModel           = "text-embedding-3-small"
+	// EmbeddingDimensions shortens embeddings to keep the local vector index small
+	EmbeddingDimensions = 256
+	// MaxEmbeddingBatch caps the texts sent in one embeddings request
+	MaxEmbeddingBatch = 100
 )
+
+// ModerationResult holds the outcome of a moderation endpoint call
+type ModerationResult struct {
+	Flagged    bool
+	Categories []string
+}
+
+// Chat message roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ChatMessage is one turn of a multi-turn conversation
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
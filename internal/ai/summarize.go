@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MapReduce summarizes text too long for one prompt. Lines are packed into chunks of at
+// most ChunkTokens, each chunk is summarized on its own with at most Concurrency requests
+// in flight, and the summaries come back in order for a final prompt to run over.
+type MapReduce struct {
+	Client   Client
+	Provider string
+	Model    string
+	// ChunkTokens caps the estimated size of each chunk
+	ChunkTokens int
+	// SummaryTokens caps the length of each chunk summary
+	SummaryTokens int
+	// Concurrency caps the chunk summaries requested at once; below 1 means 1
+	Concurrency int
+	// System is the system message of every chunk summary
+	System string
+	// Prompt builds the user turn that asks for a chunk's summary
+	Prompt func(chunk string) string
+	// Progress, when set, is called after each chunk finishes, one call at a time
+	Progress func(done, total int)
+}
+
+// Coverage reports how much of the input the summaries cover. Chunks whose summary
+// failed are left out rather than failing the whole run.
+type Coverage struct {
+	Chunks          int
+	Summarized      int
+	Lines           int
+	LinesSummarized int
+}
+
+// Complete reports whether every chunk was summarized
+func (c Coverage) Complete() bool {
+	return c.Summarized == c.Chunks
+}
+
+// Summarize returns the summaries of the chunks of lines that succeeded, in input order.
+// It fails only when the context ends or no chunk could be summarized.
+func (mr *MapReduce) Summarize(ctx context.Context, lines []string) ([]string, Coverage, error) {
+	chunks := ChunkLines(lines, mr.ChunkTokens)
+	coverage := Coverage{Chunks: len(chunks), Lines: len(lines)}
+	if len(chunks) == 0 {
+		return nil, coverage, nil
+	}
+
+	summaries := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, max(mr.Concurrency, 1))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for i, chunk := range chunks {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, chunk []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			prompt := mr.Prompt(strings.Join(chunk, "\n"))
+			summaries[i], errs[i] = mr.Client.AskClient(ctx, prompt, mr.System, mr.Model, mr.Provider, mr.SummaryTokens)
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if mr.Progress != nil {
+				mr.Progress(done, len(chunks))
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, coverage, err
+	}
+
+	var kept []string
+	var firstErr error
+	for i, summary := range summaries {
+		if errs[i] != nil {
+			firstErr = cmp.Or(firstErr, errs[i])
+			continue
+		}
+		kept = append(kept, summary)
+		coverage.Summarized++
+		coverage.LinesSummarized += len(chunks[i])
+	}
+	if len(kept) == 0 {
+		return nil, coverage, fmt.Errorf("failed to summarize any of %d chunks: %w", len(chunks), firstErr)
+	}
+	return kept, coverage, nil
+}
+
+// ChunkLines packs consecutive lines into chunks of at most maxTokens estimated tokens.
+// A line too long for a chunk of its own is truncated to fit.
+func ChunkLines(lines []string, maxTokens int) [][]string {
+	var chunks [][]string
+	var current []string
+	size := 0
+	for _, line := range lines {
+		line, _ = TruncateToTokens(line, maxTokens)
+		tokens := EstimateTokens(line) + 1
+		if len(current) > 0 && size+tokens > maxTokens {
+			chunks = append(chunks, current)
+			current, size = nil, 0
+		}
+		current = append(current, line)
+		size += tokens
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
@@ -0,0 +1,152 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// summaryClient answers every prompt with its first history line, failing prompts that
+// contain fail and tracking how many requests were in flight at once
+type summaryClient struct {
+	Client
+	fail string
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (c *summaryClient) AskClient(ctx context.Context, prompt, systemMessage, model, provider string, maxTokens int) (string, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.peak = max(c.peak, c.inFlight)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	if c.fail != "" && strings.Contains(prompt, c.fail) {
+		return "", errors.New("rate limited")
+	}
+	first, _, _ := strings.Cut(prompt, "\n")
+	return "summary of " + first, nil
+}
+
+func TestChunkLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		maxTokens int
+		want      [][]string
+	}{
+		{
+			name:      "packs lines up to the limit",
+			lines:     []string{"aaaa", "bbbb", "cccc", "dddd"},
+			maxTokens: 4,
+			want:      [][]string{{"aaaa", "bbbb"}, {"cccc", "dddd"}},
+		},
+		{
+			name:      "truncates a line too long for any chunk",
+			lines:     []string{"short", strings.Repeat("x", 40)},
+			maxTokens: 3,
+			want:      [][]string{{"short"}, {strings.Repeat("x", 12)}},
+		},
+		{
+			name:      "no lines",
+			maxTokens: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ChunkLines(tt.lines, tt.maxTokens)
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("ChunkLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapReduceSummarize(t *testing.T) {
+	lines := make([]string, 12)
+	for i := range lines {
+		lines[i] = strings.Repeat(string(rune('a'+i)), 8)
+	}
+
+	tests := []struct {
+		name         string
+		fail         string
+		want         []string
+		wantCoverage Coverage
+		wantErr      bool
+	}{
+		{
+			name: "summaries in input order",
+			want: []string{"summary of aaaaaaaa", "summary of cccccccc", "summary of eeeeeeee",
+				"summary of gggggggg", "summary of iiiiiiii", "summary of kkkkkkkk"},
+			wantCoverage: Coverage{Chunks: 6, Summarized: 6, Lines: 12, LinesSummarized: 12},
+		},
+		{
+			name: "failed chunks are left out of the coverage",
+			fail: "eeeeeeee",
+			want: []string{"summary of aaaaaaaa", "summary of cccccccc",
+				"summary of gggggggg", "summary of iiiiiiii", "summary of kkkkkkkk"},
+			wantCoverage: Coverage{Chunks: 6, Summarized: 5, Lines: 12, LinesSummarized: 10},
+		},
+		{
+			name:         "every chunk failing is an error",
+			fail:         "summarize",
+			wantCoverage: Coverage{Chunks: 6, Lines: 12},
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &summaryClient{fail: tt.fail}
+			var progress []int
+			mr := &MapReduce{
+				Client:      client,
+				ChunkTokens: 6,
+				Concurrency: 2,
+				Prompt:      func(chunk string) string { return chunk + "\nsummarize" },
+				Progress:    func(done, total int) { progress = append(progress, done) },
+			}
+
+			got, coverage, err := mr.Summarize(context.Background(), lines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Summarize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Summarize() = %q, want %q", got, tt.want)
+			}
+			if coverage != tt.wantCoverage {
+				t.Errorf("coverage = %+v, want %+v", coverage, tt.wantCoverage)
+			}
+			if client.peak > 2 {
+				t.Errorf("%d requests were in flight at once, want at most 2", client.peak)
+			}
+			if !slices.Equal(progress, []int{1, 2, 3, 4, 5, 6}) {
+				t.Errorf("progress = %v, want one call per chunk", progress)
+			}
+		})
+	}
+}
+
+func TestMapReduceSummarizeCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mr := &MapReduce{Client: &summaryClient{}, ChunkTokens: 6, Prompt: func(chunk string) string { return chunk }}
+	if _, _, err := mr.Summarize(ctx, []string{"one", "two"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Summarize() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,23 @@
+package ai
+
+import "unicode/utf8"
+
+// ApproxCharsPerToken is the rough number of characters per token for English text
+const ApproxCharsPerToken = 4
+
+// EstimateTokens returns a rough token count for text without calling a tokenizer
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + ApproxCharsPerToken - 1) / ApproxCharsPerToken
+}
+
+// TruncateToTokens shortens text so that its estimated token count fits within maxTokens.
+// The boolean result reports whether the text was truncated.
+func TruncateToTokens(text string, maxTokens int) (string, bool) {
+	maxChars := maxTokens * ApproxCharsPerToken
+	if maxTokens <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text, false
+	}
+
+	runes := []rune(text)
+	return string(runes[:maxChars]), true
+}
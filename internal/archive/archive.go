@@ -0,0 +1,349 @@
+// Package archive keeps a local copy of channel messages in an embedded bbolt database,
+// so history can be read without Discord's 100-messages-per-request limit.
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/boltdb"
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+var (
+	// messagesBucket holds one nested bucket of messages per channel, keyed by message ID
+	messagesBucket = []byte("messages")
+	// channelsBucket holds each channel's coverage record
+	channelsBucket = []byte("channels")
+)
+
+// coverage records how far back a channel's archive is complete. Messages from
+// Since onward have all been archived; anything older may have gaps. Newest is the
+// newest message the last backfill reached: live messages archived after a restart
+// are newer than the ones sent while the bot was down, so the next backfill has to
+// page back to Newest rather than to the newest archived message.
+type coverage struct {
+	GuildID string `json:"guild_id"`
+	Since   string `json:"since"`
+	Newest  string `json:"newest,omitempty"`
+}
+
+// Archive stores messages per channel in a bbolt database
+type Archive struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the archive database at path
+func Open(path string) (*Archive, error) {
+	db, err := boltdb.Open(path, "message archive", messagesBucket, channelsBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{db: db}, nil
+}
+
+// Close closes the database
+func (a *Archive) Close() error {
+	return a.db.Close()
+}
+
+// Put stores messages, replacing any archived copies with the same IDs
+func (a *Archive) Put(messages ...*discordgo.Message) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		for _, msg := range messages {
+			if err := putMessage(tx, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update applies an edit to an archived message. Edits of messages that were never
+// archived are ignored. Discord sends embed unfurls as updates without an author; those
+// only replace the embeds.
+func (a *Archive) Update(msg *discordgo.Message) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		stored, err := getMessage(tx, msg.ChannelID, msg.ID)
+		if err != nil || stored == nil {
+			return err
+		}
+		if msg.Author == nil {
+			stored.Embeds = msg.Embeds
+			return putMessage(tx, stored)
+		}
+		return putMessage(tx, msg)
+	})
+}
+
+// Delete removes messages from a channel's archive
+func (a *Archive) Delete(channelID string, messageIDs ...string) error {
+	return a.db.Update(func(tx *bolt.Tx) error {
+		channel := tx.Bucket(messagesBucket).Bucket([]byte(channelID))
+		if channel == nil {
+			return nil
+		}
+		for _, id := range messageIDs {
+			key, err := snowflake.Key(id)
+			if err != nil {
+				return err
+			}
+			if err := channel.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Latest returns the ID of the newest archived message in a channel, or "" if there is none
+func (a *Archive) Latest(channelID string) (string, error) {
+	var latest string
+	err := a.db.View(func(tx *bolt.Tx) error {
+		channel := tx.Bucket(messagesBucket).Bucket([]byte(channelID))
+		if channel == nil {
+			return nil
+		}
+		if key, _ := channel.Cursor().Last(); key != nil {
+			latest = snowflake.ID(key)
+		}
+		return nil
+	})
+	return latest, err
+}
+
+// Covered reports the ID from which a channel's archive is complete and the newest
+// message its last backfill reached, or "" for both if it hasn't been backfilled yet
+func (a *Archive) Covered(channelID string) (since, newest string, err error) {
+	cov, err := a.coverage(channelID)
+	return cov.Since, cov.Newest, err
+}
+
+// MarkCovered records that every message in a channel from sinceID up to newestID is
+// archived. Messages after newestID are only complete while the bot stays connected.
+func (a *Archive) MarkCovered(guildID, channelID, sinceID, newestID string) error {
+	data, err := json.Marshal(coverage{GuildID: guildID, Since: sinceID, Newest: newestID})
+	if err != nil {
+		return err
+	}
+	return a.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(channelsBucket).Put([]byte(channelID), data)
+	})
+}
+
+// Messages returns up to limit archived messages older than beforeID, newest first, like
+// Discord's ChannelMessages. An empty beforeID starts from the newest message. Only the
+// complete part of the archive is read, so a short result means older messages have to
+// come from Discord.
+func (a *Archive) Messages(channelID string, limit int, beforeID string) ([]*discordgo.Message, error) {
+	cov, err := a.coverage(channelID)
+	if err != nil || cov.Since == "" || limit <= 0 {
+		return nil, err
+	}
+	floor, err := snowflake.Key(cov.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []*discordgo.Message
+	err = a.db.View(func(tx *bolt.Tx) error {
+		channel := tx.Bucket(messagesBucket).Bucket([]byte(channelID))
+		if channel == nil {
+			return nil
+		}
+
+		c := channel.Cursor()
+		var key, value []byte
+		if beforeID == "" {
+			key, value = c.Last()
+		} else {
+			before, err := snowflake.Key(beforeID)
+			if err != nil {
+				return err
+			}
+			// Seek lands on the first key at or after beforeID, so step back from there
+			if key, _ = c.Seek(before); key == nil {
+				key, value = c.Last()
+			} else {
+				key, value = c.Prev()
+			}
+		}
+
+		for ; key != nil && len(messages) < limit; key, value = c.Prev() {
+			if bytes.Compare(key, floor) < 0 {
+				break
+			}
+			var msg discordgo.Message
+			if err := json.Unmarshal(value, &msg); err != nil {
+				return fmt.Errorf("failed to decode archived message %s: %w", snowflake.ID(key), err)
+			}
+			messages = append(messages, &msg)
+		}
+		return nil
+	})
+	return messages, err
+}
+
+// Message returns an archived message, or nil if it isn't archived
+func (a *Archive) Message(channelID, id string) (*discordgo.Message, error) {
+	var msg *discordgo.Message
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+		msg, err = getMessage(tx, channelID, id)
+		return err
+	})
+	return msg, err
+}
+
+// After returns up to limit archived messages newer than afterID, oldest first. An empty
+// afterID starts from the oldest archived message.
+func (a *Archive) After(channelID, afterID string, limit int) ([]*discordgo.Message, error) {
+	var messages []*discordgo.Message
+	err := a.db.View(func(tx *bolt.Tx) error {
+		channel := tx.Bucket(messagesBucket).Bucket([]byte(channelID))
+		if channel == nil || limit <= 0 {
+			return nil
+		}
+
+		c := channel.Cursor()
+		key, value := c.First()
+		if afterID != "" {
+			after, err := snowflake.Key(afterID)
+			if err != nil {
+				return err
+			}
+			if key, value = c.Seek(after); key != nil && bytes.Equal(key, after) {
+				key, value = c.Next()
+			}
+		}
+
+		for ; key != nil && len(messages) < limit; key, value = c.Next() {
+			var msg discordgo.Message
+			if err := json.Unmarshal(value, &msg); err != nil {
+				return fmt.Errorf("failed to decode archived message %s: %w", snowflake.ID(key), err)
+			}
+			messages = append(messages, &msg)
+		}
+		return nil
+	})
+	return messages, err
+}
+
+// Channels returns the IDs of a guild's backfilled channels. An empty guildID returns
+// every backfilled channel.
+func (a *Archive) Channels(guildID string) ([]string, error) {
+	var channels []string
+	err := a.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(channelsBucket).ForEach(func(key, value []byte) error {
+			var cov coverage
+			if err := json.Unmarshal(value, &cov); err != nil {
+				return fmt.Errorf("failed to decode coverage of channel %s: %w", key, err)
+			}
+			if guildID == "" || cov.GuildID == guildID {
+				channels = append(channels, string(key))
+			}
+			return nil
+		})
+	})
+	return channels, err
+}
+
+// Prune deletes messages sent before cutoff and returns how many were removed
+func (a *Archive) Prune(cutoff time.Time) (int, error) {
+	limit := snowflake.TimeKey(cutoff)
+	removed := 0
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(messagesBucket).ForEachBucket(func(name []byte) error {
+			n, err := boltdb.DeleteBefore(tx.Bucket(messagesBucket).Bucket(name), limit)
+			removed += n
+			return err
+		})
+	})
+	return removed, err
+}
+
+// coverage reads a channel's coverage record
+func (a *Archive) coverage(channelID string) (coverage, error) {
+	var cov coverage
+	err := a.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(channelsBucket).Get([]byte(channelID))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &cov)
+	})
+	return cov, err
+}
+
+// putMessage stores a trimmed copy of msg in its channel's bucket
+func putMessage(tx *bolt.Tx, msg *discordgo.Message) error {
+	key, err := snowflake.Key(msg.ID)
+	if err != nil {
+		return err
+	}
+	channel, err := tx.Bucket(messagesBucket).CreateBucketIfNotExists([]byte(msg.ChannelID))
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(trim(msg))
+	if err != nil {
+		return fmt.Errorf("failed to encode message %s: %w", msg.ID, err)
+	}
+	return channel.Put(key, data)
+}
+
+// getMessage loads an archived message, returning nil if it isn't archived
+func getMessage(tx *bolt.Tx, channelID, id string) (*discordgo.Message, error) {
+	channel := tx.Bucket(messagesBucket).Bucket([]byte(channelID))
+	if channel == nil {
+		return nil, nil
+	}
+	key, err := snowflake.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	data := channel.Get(key)
+	if data == nil {
+		return nil, nil
+	}
+	var msg discordgo.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode archived message %s: %w", id, err)
+	}
+	return &msg, nil
+}
+
+// trim keeps the parts of a message that history formatting uses and drops the rest,
+// such as member objects and the full referenced message
+func trim(msg *discordgo.Message) *discordgo.Message {
+	trimmed := &discordgo.Message{
+		ID:               msg.ID,
+		ChannelID:        msg.ChannelID,
+		GuildID:          msg.GuildID,
+		Content:          msg.Content,
+		Timestamp:        msg.Timestamp,
+		EditedTimestamp:  msg.EditedTimestamp,
+		Attachments:      msg.Attachments,
+		Embeds:           msg.Embeds,
+		StickerItems:     msg.StickerItems,
+		MessageReference: msg.MessageReference,
+		Type:             msg.Type,
+	}
+	if msg.Author != nil {
+		trimmed.Author = trimUser(msg.Author)
+	}
+	for _, user := range msg.Mentions {
+		trimmed.Mentions = append(trimmed.Mentions, trimUser(user))
+	}
+	return trimmed
+}
+
+// trimUser keeps a user's identity and names
+func trimUser(user *discordgo.User) *discordgo.User {
+	return &discordgo.User{ID: user.ID, Username: user.Username, GlobalName: user.GlobalName, Bot: user.Bot}
+}
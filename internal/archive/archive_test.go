@@ -0,0 +1,217 @@
+package archive
+
+import (
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+func openTestArchive(t *testing.T) (*Archive, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.db")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a, path
+}
+
+// seed archives one message per minute starting at start and returns their IDs, oldest first
+func seed(t *testing.T, a *Archive, start time.Time, count int) []string {
+	t.Helper()
+	var ids []string
+	for i := range count {
+		sent := start.Add(time.Duration(i) * time.Minute)
+		id := snowflake.At(sent)
+		err := a.Put(&discordgo.Message{
+			ID:        id,
+			ChannelID: "channel",
+			GuildID:   "guild",
+			Content:   "message " + strconv.Itoa(i),
+			Timestamp: sent,
+			Author:    &discordgo.User{ID: "1", Username: "dave", Email: "dave@example.com"},
+		})
+		if err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func messageIDs(messages []*discordgo.Message) []string {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestMessages(t *testing.T) {
+	a, _ := openTestArchive(t)
+	ids := seed(t, a, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), 6)
+
+	// Nothing is served until the channel's coverage is known
+	if got, _ := a.Messages("channel", 10, ""); len(got) != 0 {
+		t.Fatalf("Messages() before coverage = %v, want none", messageIDs(got))
+	}
+	if err := a.MarkCovered("guild", "channel", ids[1], ""); err != nil {
+		t.Fatalf("MarkCovered() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		limit    int
+		beforeID string
+		want     []string
+	}{
+		{name: "newest first", limit: 2, want: []string{ids[5], ids[4]}},
+		{name: "stops at coverage", limit: 10, want: []string{ids[5], ids[4], ids[3], ids[2], ids[1]}},
+		{name: "before an archived message", limit: 2, beforeID: ids[4], want: []string{ids[3], ids[2]}},
+		{name: "before an unknown message", limit: 1, beforeID: snowflake.At(time.Date(2024, 5, 1, 12, 2, 30, 0, time.UTC)), want: []string{ids[2]}},
+		{name: "before everything", limit: 5, beforeID: ids[1], want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.Messages("channel", tt.limit, tt.beforeID)
+			if err != nil {
+				t.Fatalf("Messages() error = %v", err)
+			}
+			if gotIDs := messageIDs(got); !slices.Equal(gotIDs, tt.want) {
+				t.Errorf("Messages() = %v, want %v", gotIDs, tt.want)
+			}
+		})
+	}
+
+	got, _ := a.Messages("channel", 1, "")
+	if got[0].Content != "message 5" || got[0].Author.Username != "dave" || got[0].Author.Email != "" {
+		t.Errorf("archived message = %+v, author %+v", got[0], got[0].Author)
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	a, _ := openTestArchive(t)
+	ids := seed(t, a, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), 3)
+	a.MarkCovered("guild", "channel", ids[0], "")
+
+	edited := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	updates := []*discordgo.Message{
+		{ID: ids[0], ChannelID: "channel", Content: "edited", EditedTimestamp: &edited, Author: &discordgo.User{ID: "1", Username: "dave"}},
+		// Embed unfurls arrive without an author and must not wipe the content
+		{ID: ids[1], ChannelID: "channel", Embeds: []*discordgo.MessageEmbed{{Title: "Vegas hotels"}}},
+		// Edits of messages that were never archived are ignored
+		{ID: snowflake.At(edited), ChannelID: "channel", Content: "unknown", Author: &discordgo.User{ID: "1"}},
+	}
+	for _, msg := range updates {
+		if err := a.Update(msg); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	if err := a.Delete("channel", ids[2]); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	got, _ := a.Messages("channel", 10, "")
+	if gotIDs := messageIDs(got); !slices.Equal(gotIDs, []string{ids[1], ids[0]}) {
+		t.Fatalf("Messages() after delete = %v", gotIDs)
+	}
+	if got[1].Content != "edited" || got[1].EditedTimestamp == nil {
+		t.Errorf("edit not applied: %+v", got[1])
+	}
+	if got[0].Content != "message 1" || len(got[0].Embeds) != 1 {
+		t.Errorf("embed update = content %q, %d embeds", got[0].Content, len(got[0].Embeds))
+	}
+}
+
+func TestPruneAndReopen(t *testing.T) {
+	a, path := openTestArchive(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ids := seed(t, a, start, 5)
+	a.MarkCovered("guild", "channel", ids[0], ids[4])
+
+	removed, err := a.Prune(start.Add(3 * time.Minute))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("Prune() removed %d, want 3", removed)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	latest, err := reopened.Latest("channel")
+	if err != nil || latest != ids[4] {
+		t.Errorf("Latest() = %q, %v, want %q", latest, err, ids[4])
+	}
+	if since, newest, err := reopened.Covered("channel"); err != nil || since != ids[0] || newest != ids[4] {
+		t.Errorf("Covered() = %q, %q, %v, want %q, %q", since, newest, err, ids[0], ids[4])
+	}
+	got, _ := reopened.Messages("channel", 10, "")
+	if gotIDs := messageIDs(got); !slices.Equal(gotIDs, []string{ids[4], ids[3]}) {
+		t.Errorf("Messages() after prune = %v", gotIDs)
+	}
+	if latest, _ := reopened.Latest("empty"); latest != "" {
+		t.Errorf("Latest() of an empty channel = %q", latest)
+	}
+}
+
+func TestAfterAndChannels(t *testing.T) {
+	a, _ := openTestArchive(t)
+	ids := seed(t, a, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), 5)
+	a.MarkCovered("guild", "channel", ids[0], "")
+	a.MarkCovered("other-guild", "elsewhere", "0", "")
+
+	tests := []struct {
+		name    string
+		afterID string
+		limit   int
+		want    []string
+	}{
+		{name: "from the start", limit: 2, want: []string{ids[0], ids[1]}},
+		{name: "after an archived message", afterID: ids[2], limit: 5, want: []string{ids[3], ids[4]}},
+		{name: "after an unknown message", afterID: snowflake.At(time.Date(2024, 5, 1, 12, 1, 30, 0, time.UTC)), limit: 1, want: []string{ids[2]}},
+		{name: "after the newest", afterID: ids[4], limit: 5, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.After("channel", tt.afterID, tt.limit)
+			if err != nil {
+				t.Fatalf("After() error = %v", err)
+			}
+			if gotIDs := messageIDs(got); !slices.Equal(gotIDs, tt.want) {
+				t.Errorf("After() = %v, want %v", gotIDs, tt.want)
+			}
+		})
+	}
+
+	if msg, err := a.Message("channel", ids[3]); err != nil || msg == nil || msg.Content != "message 3" {
+		t.Errorf("Message() = %+v, %v", msg, err)
+	}
+	if msg, _ := a.Message("channel", snowflake.At(time.Now())); msg != nil {
+		t.Errorf("Message() of an unknown ID = %+v, want nil", msg)
+	}
+
+	channels, err := a.Channels("guild")
+	if err != nil || !slices.Equal(channels, []string{"channel"}) {
+		t.Errorf("Channels(guild) = %v, %v", channels, err)
+	}
+	if all, _ := a.Channels(""); len(all) != 2 {
+		t.Errorf("Channels() = %v, want both channels", all)
+	}
+}
@@ -0,0 +1,64 @@
+// Package boltdb holds the bbolt plumbing shared by the bot's embedded databases
+package boltdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Open opens or creates the database at path and creates its top-level buckets. name
+// describes the database in errors, e.g. "message archive".
+func Open(path, name string, buckets ...[]byte) (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s %s: %w", name, path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize %s: %w", name, err)
+	}
+
+	return db, nil
+}
+
+// KeysBefore returns copies of the keys in bucket that sort before limit, oldest first.
+// Deleting through a cursor skips the following key, so callers collect keys with this
+// before deleting them.
+func KeysBefore(bucket *bolt.Bucket, limit []byte) [][]byte {
+	var keys [][]byte
+	c := bucket.Cursor()
+	for key, _ := c.First(); key != nil && bytes.Compare(key, limit) < 0; key, _ = c.Next() {
+		keys = append(keys, bytes.Clone(key))
+	}
+	return keys
+}
+
+// DeleteBefore deletes the keys in bucket that sort before limit and returns how many
+// were removed
+func DeleteBefore(bucket *bolt.Bucket, limit []byte) (int, error) {
+	keys := KeysBefore(bucket, limit)
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
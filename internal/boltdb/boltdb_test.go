@@ -0,0 +1,60 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpenCreatesBuckets(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "nested", "test.db"), "test database", []byte("a"), []byte("b"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for _, name := range []string{"a", "b"} {
+			if tx.Bucket([]byte(name)) == nil {
+				t.Errorf("bucket %q not created", name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View() error = %v", err)
+	}
+}
+
+func TestDeleteBefore(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), "test database", []byte("keys"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	var removed int
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("keys"))
+		for _, key := range []string{"a", "b", "c", "d"} {
+			if err := bucket.Put([]byte(key), nil); err != nil {
+				return err
+			}
+		}
+		removed, err = DeleteBefore(bucket, []byte("c"))
+		if err != nil {
+			return err
+		}
+		if got := KeysBefore(bucket, []byte("z")); len(got) != 2 || string(got[0]) != "c" || string(got[1]) != "d" {
+			t.Errorf("remaining keys = %q, want c and d", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("DeleteBefore() removed %d, want 2", removed)
+	}
+}
@@ -0,0 +1,351 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/archive"
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+)
+
+// newArchive opens the local message archive, or returns nil when it is disabled
+func newArchive(cfg *config.Config) (*archive.Archive, error) {
+	if !cfg.ArchiveEnabled {
+		return nil, nil
+	}
+	return archive.Open(filepath.Join(cfg.DataDir, ArchiveFile))
+}
+
+// registerArchiveHandlers keeps the archive in sync with gateway events
+func (b *Bot) registerArchiveHandlers() {
+	b.session.AddHandler(b.messageUpdateHandler)
+	b.session.AddHandler(b.messageDeleteHandler)
+	b.session.AddHandler(b.messageDeleteBulkHandler)
+	b.session.AddHandler(b.guildCreateHandler)
+}
+
+// archiveMessage stores a newly created message
+func (b *Bot) archiveMessage(ctx context.Context, msg *discordgo.Message) {
+	if b.archive == nil || msg.GuildID == "" {
+		return
+	}
+	if err := b.archive.Put(msg); err != nil {
+		b.logger.ErrorContext(ctx, "failed to archive message", "message_id", msg.ID, "error", err)
+	}
+}
+
+// messageUpdateHandler applies edits to archived messages and the search index
+func (b *Bot) messageUpdateHandler(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	if b.archive == nil {
+		return
+	}
+	if err := b.archive.Update(m.Message); err != nil {
+		b.logger.Error("failed to archive message edit", "message_id", m.ID, "error", err)
+		return
+	}
+	if b.search == nil {
+		return
+	}
+	msg, err := b.archive.Message(m.ChannelID, m.ID)
+	if err != nil || msg == nil || msg.Author == nil {
+		return
+	}
+	if err := b.search.Update(searchDocument(msg)); err != nil {
+		b.logger.Error("failed to update search index", "message_id", m.ID, "error", err)
+	}
+}
+
+// messageDeleteHandler removes deleted messages from the archive and indexes
+func (b *Bot) messageDeleteHandler(s *discordgo.Session, m *discordgo.MessageDelete) {
+	if b.archive == nil {
+		return
+	}
+	if err := b.archive.Delete(m.ChannelID, m.ID); err != nil {
+		b.logger.Error("failed to remove deleted message from archive", "message_id", m.ID, "error", err)
+	}
+	b.removeFromIndex(m.ChannelID, m.ID)
+}
+
+// messageDeleteBulkHandler removes purged messages from the archive and indexes
+func (b *Bot) messageDeleteBulkHandler(s *discordgo.Session, m *discordgo.MessageDeleteBulk) {
+	if b.archive == nil {
+		return
+	}
+	if err := b.archive.Delete(m.ChannelID, m.Messages...); err != nil {
+		b.logger.Error("failed to remove purged messages from archive", "channel_id", m.ChannelID, "error", err)
+	}
+	b.removeFromIndex(m.ChannelID, m.Messages...)
+}
+
+// guildCreateHandler backfills a guild's channels when the bot joins or reconnects to it
+func (b *Bot) guildCreateHandler(s *discordgo.Session, g *discordgo.GuildCreate) {
+	if b.archive == nil {
+		return
+	}
+	go b.backfillGuild(b.background, s.State.User.ID, g.Guild)
+}
+
+// backfillGuild archives recent history of every text channel the bot can read, one
+// channel at a time to stay clear of rate limits
+func (b *Bot) backfillGuild(ctx context.Context, botID string, guild *discordgo.Guild) {
+	for _, channel := range guild.Channels {
+		if ctx.Err() != nil {
+			return
+		}
+		if channel.Type != discordgo.ChannelTypeGuildText && channel.Type != discordgo.ChannelTypeGuildNews {
+			continue
+		}
+
+		perms, err := b.session.UserChannelPermissions(botID, channel.ID)
+		required := int64(discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory)
+		if err != nil || perms&required != required {
+			continue
+		}
+
+		if err := b.backfillChannel(ctx, guild.ID, channel.ID); err != nil {
+			b.logger.ErrorContext(ctx, "failed to backfill channel",
+				"guild_id", guild.ID,
+				"channel_id", channel.ID,
+				"error", err)
+		}
+	}
+}
+
+// backfillChannel fetches messages sent since the newest one the last backfill reached,
+// newest first, until it meets that message, the retention period, the start of the
+// channel or the configured backfill limit. It then records how far back the archive is
+// complete. Live messages archived since a restart don't stop it, so messages sent while
+// the bot was down are fetched too.
+func (b *Bot) backfillChannel(ctx context.Context, guildID, channelID string) error {
+	covered, previous, err := b.archive.Covered(channelID)
+	if err != nil {
+		return err
+	}
+
+	var cutoff time.Time
+	if days := b.config.ArchiveRetentionDays; days > 0 {
+		cutoff = time.Now().AddDate(0, 0, -days)
+	}
+
+	var newest, oldest, beforeID string
+	fetched := 0
+	reachedArchive, reachedEnd := false, false
+	for fetched < b.config.ArchiveBackfill && !reachedArchive && !reachedEnd {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		limit := min(MaxMessagesPerRequest, b.config.ArchiveBackfill-fetched)
+		batch, err := b.session.ChannelMessages(channelID, limit, beforeID, "", "")
+		if err != nil {
+			return err
+		}
+		reachedEnd = len(batch) < limit
+
+		var keep []*discordgo.Message
+		for _, msg := range batch {
+			if previous != "" && !snowflakeBefore(previous, msg.ID) {
+				reachedArchive = true
+				break
+			}
+			if msg.Timestamp.Before(cutoff) {
+				reachedEnd = true
+				break
+			}
+			msg.GuildID = guildID
+			keep = append(keep, msg)
+		}
+		if len(keep) > 0 {
+			if err := b.archive.Put(keep...); err != nil {
+				return err
+			}
+			b.indexBackfilled(ctx, channelID, keep)
+			if newest == "" {
+				newest = keep[0].ID
+			}
+			oldest = keep[len(keep)-1].ID
+			fetched += len(keep)
+		}
+		if len(batch) > 0 {
+			beforeID = batch[len(batch)-1].ID
+		}
+	}
+
+	// The archive stays complete as far back as before when backfill closes the gap
+	// since the last run; otherwise it is complete from the oldest message fetched
+	since := oldest
+	switch {
+	case reachedArchive && covered != "":
+		since = covered
+	case reachedEnd:
+		since = "0"
+	case since == "":
+		since = covered
+	}
+	if since == "" {
+		return nil
+	}
+	if newest == "" {
+		newest = previous
+	}
+
+	b.logger.InfoContext(ctx, "channel backfilled",
+		"guild_id", guildID,
+		"channel_id", channelID,
+		"messages", fetched)
+
+	return b.archive.MarkCovered(guildID, channelID, since, newest)
+}
+
+// indexBackfilled makes sure the indexes pick up messages backfill stored. They can be
+// older than messages indexed since a restart, which the indexes' cursors have passed.
+func (b *Bot) indexBackfilled(ctx context.Context, channelID string, messages []*discordgo.Message) {
+	// Adding without a cursor leaves the search cursor where it is
+	if b.search != nil {
+		if err := b.search.Add(channelID, searchDocuments(messages), ""); err != nil {
+			b.logger.ErrorContext(ctx, "failed to index backfilled messages for search", "channel_id", channelID, "error", err)
+		}
+	}
+	if b.vectors != nil {
+		if err := b.rewindRecallCursor(channelID, messages[len(messages)-1].ID); err != nil {
+			b.logger.ErrorContext(ctx, "failed to rewind recall index", "channel_id", channelID, "error", err)
+		}
+	}
+}
+
+// pruneArchive deletes messages, and their index entries, older than the retention
+// period every ArchivePruneInterval
+func (b *Bot) pruneArchive(ctx context.Context) {
+	if b.archive == nil || b.config.ArchiveRetentionDays == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(ArchivePruneInterval)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().AddDate(0, 0, -b.config.ArchiveRetentionDays)
+		removed, err := b.archive.Prune(cutoff)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to prune message archive", "error", err)
+		} else if removed > 0 {
+			b.logger.InfoContext(ctx, "pruned message archive", "messages", removed)
+		}
+		if b.search != nil {
+			if _, err := b.search.Prune(cutoff); err != nil {
+				b.logger.ErrorContext(ctx, "failed to prune search index", "error", err)
+			}
+		}
+		if b.vectors != nil {
+			if _, err := b.vectors.Prune(cutoff); err != nil {
+				b.logger.ErrorContext(ctx, "failed to prune recall index", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// indexLoop brings the search and recall indexes up to date with the archive every
+// IndexInterval
+func (b *Bot) indexLoop(ctx context.Context) {
+	if b.archive == nil || (b.search == nil && b.vectors == nil) {
+		return
+	}
+
+	ticker := time.NewTicker(IndexInterval)
+	defer ticker.Stop()
+	for {
+		channels, err := b.archive.Channels("")
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to list archived channels", "error", err)
+		}
+		for _, channelID := range channels {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := b.indexSearchChannel(ctx, channelID); err != nil {
+				b.logger.ErrorContext(ctx, "failed to update search index", "channel_id", channelID, "error", err)
+			}
+			if b.vectors == nil {
+				continue
+			}
+			if _, err := b.indexChannel(ctx, channelID, b.config.ArchiveBackfill); err != nil {
+				b.logger.ErrorContext(ctx, "failed to index channel", "channel_id", channelID, "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readableChannels returns the archived channels of the invoker's guild that they can
+// read. The channel the command was sent in is always included.
+func (b *Bot) readableChannels(m *discordgo.MessageCreate) []string {
+	channels, err := b.archive.Channels(m.GuildID)
+	if err != nil {
+		b.logger.Error("failed to list archived channels", "guild_id", m.GuildID, "error", err)
+	}
+
+	readable := []string{m.ChannelID}
+	for _, channelID := range channels {
+		if channelID != m.ChannelID && b.canRead(m.Author.ID, channelID) {
+			readable = append(readable, channelID)
+		}
+	}
+	return readable
+}
+
+// canRead reports whether a member can read a channel's message history
+func (b *Bot) canRead(userID, channelID string) bool {
+	required := int64(discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory)
+	perms, err := b.session.UserChannelPermissions(userID, channelID)
+	return err == nil && (perms&required == required || perms&discordgo.PermissionAdministrator != 0)
+}
+
+// lookupChannel returns a channel from the state cache, falling back to the API
+func (b *Bot) lookupChannel(channelID string) (*discordgo.Channel, error) {
+	if channel, err := b.session.GetState().Channel(channelID); err == nil {
+		return channel, nil
+	}
+	return b.session.Channel(channelID)
+}
+
+// messageLink returns the jump link to a message
+func messageLink(guildID, channelID, messageID string) string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, channelID, messageID)
+}
+
+// removeFromIndex drops deleted messages from the search and recall indexes
+func (b *Bot) removeFromIndex(channelID string, messageIDs ...string) {
+	if b.search != nil {
+		if err := b.search.Delete(channelID, messageIDs...); err != nil {
+			b.logger.Error("failed to remove deleted messages from search index", "channel_id", channelID, "error", err)
+		}
+	}
+	if b.vectors != nil {
+		if err := b.vectors.Delete(channelID, messageIDs...); err != nil {
+			b.logger.Error("failed to remove deleted messages from recall index", "channel_id", channelID, "error", err)
+		}
+	}
+}
+
+// snowflakeBefore reports whether snowflake a is older than snowflake b. Snowflakes grow
+// over time, so a shorter ID is always the older one.
+func snowflakeBefore(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
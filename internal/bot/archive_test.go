@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/archive"
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+)
+
+// testSnowflake returns the ID Discord would give a message sent at t
+func testSnowflake(t time.Time) string {
+	return strconv.FormatUint(uint64(t.UnixMilli()-1420070400000)<<22, 10)
+}
+
+// testHistory returns count messages sent a minute apart and ending now, newest first
+func testHistory(count int) []*discordgo.Message {
+	now := time.Now()
+	history := make([]*discordgo.Message, count)
+	for i := range history {
+		sent := now.Add(-time.Duration(i) * time.Minute)
+		history[i] = &discordgo.Message{
+			ID:        testSnowflake(sent),
+			ChannelID: "channel",
+			Content:   "message " + strconv.Itoa(count-i),
+			Timestamp: sent,
+			Author:    &discordgo.User{ID: "1", Username: "dave"},
+		}
+	}
+	return history
+}
+
+func newArchiveTestBot(t *testing.T, cfg *config.Config) (*Bot, *mockDiscordSession) {
+	t.Helper()
+	a, err := archive.Open(filepath.Join(t.TempDir(), ArchiveFile))
+	if err != nil {
+		t.Fatalf("archive.Open() error = %v", err)
+	}
+	t.Cleanup(func() { a.Close() })
+
+	session := &mockDiscordSession{}
+	return &Bot{
+		session: session,
+		archive: a,
+		config:  cfg,
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}, session
+}
+
+func contents(messages []*discordgo.Message) []string {
+	var list []string
+	for _, msg := range messages {
+		list = append(list, msg.Content)
+	}
+	return list
+}
+
+func TestBackfillChannel(t *testing.T) {
+	bot, session := newArchiveTestBot(t, &config.Config{ArchiveBackfill: 3})
+	ctx := context.Background()
+	history := testHistory(7)
+
+	// The first run stops at the backfill limit
+	session.history = history[2:]
+	if err := bot.backfillChannel(ctx, "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+	got, _ := bot.archive.Messages("channel", 10, "")
+	if want := []string{"message 5", "message 4", "message 3"}; !slices.Equal(contents(got), want) {
+		t.Fatalf("archived %q after first backfill, want %q", contents(got), want)
+	}
+
+	// A later run only fetches what is new and keeps the archive complete back to
+	// where the first run stopped
+	session.history = history
+	if err := bot.backfillChannel(ctx, "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+	got, _ = bot.archive.Messages("channel", 10, "")
+	if want := []string{"message 7", "message 6", "message 5", "message 4", "message 3"}; !slices.Equal(contents(got), want) {
+		t.Errorf("archived %q after second backfill, want %q", contents(got), want)
+	}
+	if got[0].GuildID != "guild" {
+		t.Errorf("archived guild = %q, want guild", got[0].GuildID)
+	}
+}
+
+func TestBackfillChannelAfterDowntime(t *testing.T) {
+	bot, session := newArchiveTestBot(t, &config.Config{ArchiveBackfill: 1000})
+	ctx := context.Background()
+	history := testHistory(10)
+
+	session.history = history[5:]
+	if err := bot.backfillChannel(ctx, "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+
+	// After a restart a live message is archived before backfill reaches the channel;
+	// messages 6 to 9 were sent while the bot was down
+	history[0].GuildID = "guild"
+	bot.archiveMessage(ctx, history[0])
+	session.history = history
+	if err := bot.backfillChannel(ctx, "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+
+	session.history = nil
+	got, err := bot.fetchHistory(ctx, historyQuery{channelID: "channel", limit: 20})
+	if err != nil {
+		t.Fatalf("fetchHistory() error = %v", err)
+	}
+	if want := contents(history); !slices.Equal(contents(got), want) {
+		t.Errorf("fetchHistory() = %q, want %q", contents(got), want)
+	}
+}
+
+func TestBackfillChannelStopsAtRetention(t *testing.T) {
+	bot, session := newArchiveTestBot(t, &config.Config{ArchiveBackfill: 1000, ArchiveRetentionDays: 1})
+	history := testHistory(3)
+	history[2].Timestamp = time.Now().AddDate(0, 0, -2)
+	session.history = history
+
+	if err := bot.backfillChannel(context.Background(), "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+
+	got, _ := bot.archive.Messages("channel", 10, "")
+	if want := []string{"message 3", "message 2"}; !slices.Equal(contents(got), want) {
+		t.Errorf("archived %q, want %q", contents(got), want)
+	}
+}
+
+func TestArchiveEventHandlers(t *testing.T) {
+	bot, session := newArchiveTestBot(t, &config.Config{})
+	ctx := context.Background()
+	bot.archive.MarkCovered("guild", "channel", "0", "")
+
+	history := testHistory(3)
+	for i := len(history) - 1; i >= 0; i-- {
+		history[i].GuildID = "guild"
+		bot.archiveMessage(ctx, history[i])
+	}
+
+	bot.messageUpdateHandler(nil, &discordgo.MessageUpdate{Message: &discordgo.Message{
+		ID: history[0].ID, ChannelID: "channel", Content: "message 3 (edited)", Author: history[0].Author,
+	}})
+	bot.messageDeleteHandler(nil, &discordgo.MessageDelete{Message: &discordgo.Message{ID: history[1].ID, ChannelID: "channel"}})
+
+	got, err := bot.fetchHistory(ctx, historyQuery{channelID: "channel", limit: 2})
+	if err != nil {
+		t.Fatalf("fetchHistory() error = %v", err)
+	}
+	if want := []string{"message 3 (edited)", "message 1"}; !slices.Equal(contents(got), want) {
+		t.Errorf("fetchHistory() = %q, want %q", contents(got), want)
+	}
+
+	bot.messageDeleteBulkHandler(nil, &discordgo.MessageDeleteBulk{ChannelID: "channel", Messages: []string{history[0].ID, history[2].ID}})
+
+	// With too little archived, history comes from Discord
+	session.history = []*discordgo.Message{{ID: "1", Content: "from discord", Author: &discordgo.User{ID: "1"}}}
+	got, err = bot.fetchHistory(ctx, historyQuery{channelID: "channel", limit: 2})
+	if err != nil {
+		t.Fatalf("fetchHistory() error = %v", err)
+	}
+	if want := []string{"from discord"}; !slices.Equal(contents(got), want) {
+		t.Errorf("fetchHistory() after purge = %q, want %q", contents(got), want)
+	}
+}
+
+func TestSnowflakeBefore(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "99", b: "100", want: true},
+		{a: "100", b: "99", want: false},
+		{a: "123", b: "124", want: true},
+		{a: "124", b: "124", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := snowflakeBefore(tt.a, tt.b); got != tt.want {
+			t.Errorf("snowflakeBefore(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/cmdparse"
+)
+
+// Flags accepted by every command that declares a default provider
+const (
+	providerFlag = "provider"
+	modelFlag    = "model"
+)
+
+// parsedArgs holds a command's arguments after binding them to its definition
+type parsedArgs struct {
+	// values maps argument names to validated values. Mentions and references are
+	// stored as bare IDs and choices in lower case.
+	values   map[string]string
+	provider string
+	model    string
+	// rest holds the tokens that didn't bind to a typed argument, in order. Free-text
+	// arguments such as a question are read from here.
+	rest []string
+}
+
+// takesFreeText reports whether the command has a string argument without fixed choices
+func (c *command) takesFreeText() bool {
+	for _, arg := range c.args {
+		if arg.kind == argString && len(arg.choices) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsFlag reports whether a flag name is one of the command's own flags
+func (c *command) acceptsFlag(flag string) bool {
+	if c.defaultProvider != "" && (flag == providerFlag || flag == modelFlag) {
+		return true
+	}
+	_, ok := c.argForFlag(flag)
+	return ok
+}
+
+// argForFlag finds the argument a flag name or alias refers to
+func (c *command) argForFlag(flag string) (*commandArg, bool) {
+	for i := range c.args {
+		arg := &c.args[i]
+		if arg.name == flag {
+			return arg, true
+		}
+		for _, alias := range arg.flagAliases {
+			if alias == flag {
+				return arg, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// parseCommandArgs binds tokens to a command's arguments. Named flags (--n=50 or --n 50)
+// can appear anywhere; remaining tokens are matched to unfilled arguments by type, so
+// "!opinion grok 50" and "!opinion 50 grok" mean the same thing. Free text can mention
+// flags of its own ("what does --force do"), so free-text commands only parse the flags
+// they declare and keep other ones as text.
+func parseCommandArgs(cmd *command, tokens []string) (*parsedArgs, error) {
+	parsed := &parsedArgs{
+		values:   make(map[string]string),
+		provider: cmd.defaultProvider,
+	}
+
+	var positional []string
+	for i := 0; i < len(tokens); i++ {
+		name, value, hasValue, isFlag := cmdparse.SplitFlag(tokens[i])
+		if !isFlag || (cmd.takesFreeText() && !cmd.acceptsFlag(name)) {
+			positional = append(positional, tokens[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(tokens) {
+				return nil, cmdparse.NewMissingValueError(name)
+			}
+			i++
+			value = tokens[i]
+		}
+		if err := parsed.setFlag(cmd, name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// A bare provider name selects the provider. Free-text commands only look at the
+	// first word so "!ask is grok better" keeps its question intact.
+	if cmd.defaultProvider != "" {
+		providerGiven := false
+		for i := 0; i < len(positional); i++ {
+			if _, isUser := cmdparse.UserID(positional[i]); isUser {
+				continue
+			}
+			if provider, ok := parseProvider(positional[i]); ok && !providerGiven {
+				parsed.provider = provider
+				positional = append(positional[:i], positional[i+1:]...)
+				providerGiven = true
+				i--
+				continue
+			}
+			if cmd.takesFreeText() {
+				break
+			}
+		}
+	}
+
+	for _, token := range positional {
+		arg := parsed.bindPositional(cmd, token)
+		if arg != nil {
+			continue
+		}
+		if !cmd.takesFreeText() && !isDiscordReference(token) {
+			return nil, fmt.Errorf("I didn't understand %q", token)
+		}
+		parsed.rest = append(parsed.rest, token)
+	}
+
+	return parsed, nil
+}
+
+// setFlag validates and records a named flag
+func (p *parsedArgs) setFlag(cmd *command, name, value string) error {
+	if value == "" {
+		return cmdparse.NewMissingValueError(name)
+	}
+
+	if cmd.defaultProvider != "" {
+		switch name {
+		case providerFlag:
+			provider, ok := parseProvider(value)
+			if !ok {
+				return cmdparse.NewInvalidValueError(name, value, ai.ProviderGrok+" or "+ai.ProviderOpenAI)
+			}
+			p.provider = provider
+			return nil
+		case modelFlag:
+			p.model = value
+			return nil
+		}
+	}
+
+	arg, ok := cmd.argForFlag(name)
+	if !ok {
+		return cmdparse.NewUnknownFlagError(name)
+	}
+
+	converted, err := convertArg(arg, name, value)
+	if err != nil {
+		return err
+	}
+	p.values[arg.name] = converted
+	return nil
+}
+
+// bindPositional stores token in the first unfilled argument whose type it matches. Free
+// text is full of words like "6pm" and "2h", so free-text commands only take times and
+// durations as flags.
+func (p *parsedArgs) bindPositional(cmd *command, token string) *commandArg {
+	for i := range cmd.args {
+		arg := &cmd.args[i]
+		if _, filled := p.values[arg.name]; filled {
+			continue
+		}
+		if cmd.takesFreeText() && (arg.kind == argDuration || arg.kind == argTime) {
+			continue
+		}
+		if value, ok := matchPositional(arg, token); ok {
+			p.values[arg.name] = value
+			return arg
+		}
+	}
+	return nil
+}
+
+// matchPositional reports whether a bare token fits an argument, returning its value
+func matchPositional(arg *commandArg, token string) (string, bool) {
+	switch arg.kind {
+	case argInteger:
+		if n, err := strconv.Atoi(token); err == nil && n >= 1 {
+			return token, true
+		}
+	case argUser:
+		return cmdparse.UserID(token)
+	case argRole:
+		return cmdparse.RoleID(token)
+	case argChannel:
+		return cmdparse.ChannelID(token)
+	case argDuration:
+		if _, err := cmdparse.ParseDuration(arg.name, token); err == nil {
+			return strings.ToLower(token), true
+		}
+	case argMessage:
+		if _, _, _, ok := cmdparse.MessageLink(token); ok {
+			return strings.Trim(token, "<>"), true
+		}
+	case argTime:
+		if _, err := cmdparse.ParseTime(arg.name, token, time.Now()); err == nil {
+			return strings.ToLower(token), true
+		}
+	case argString:
+		if choice, ok := matchChoice(arg, token); ok {
+			return choice, true
+		}
+	}
+	return "", false
+}
+
+// convertArg validates a flag value against the argument's type
+func convertArg(arg *commandArg, flag, value string) (string, error) {
+	switch arg.kind {
+	case argInteger:
+		n, err := cmdparse.ParseInt(flag, value, 1)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(n), nil
+	case argUser:
+		return idOrMention(flag, value, cmdparse.UserID, "a user mention")
+	case argRole:
+		return idOrMention(flag, value, cmdparse.RoleID, "a role mention")
+	case argChannel:
+		return idOrMention(flag, value, cmdparse.ChannelID, "a channel like #general")
+	case argDuration:
+		if _, err := cmdparse.ParseDuration(flag, value); err != nil {
+			return "", err
+		}
+		return strings.ToLower(value), nil
+	case argAttachment:
+		return "", fmt.Errorf("--%s can only be attached, not typed", flag)
+	case argMessage:
+		if _, _, _, ok := cmdparse.MessageLink(value); !ok {
+			return "", cmdparse.NewInvalidValueError(flag, value, "a message link")
+		}
+		return strings.Trim(value, "<>"), nil
+	case argTime:
+		if _, err := cmdparse.ParseTime(flag, value, time.Now()); err != nil {
+			return "", err
+		}
+		return strings.ToLower(value), nil
+	}
+
+	if len(arg.choices) > 0 {
+		choice, ok := matchChoice(arg, value)
+		if !ok {
+			return "", cmdparse.NewInvalidValueError(flag, value, "one of "+strings.Join(arg.choices, ", "))
+		}
+		return choice, nil
+	}
+	return value, nil
+}
+
+// matchChoice finds the choice a value names, ignoring case
+func matchChoice(arg *commandArg, value string) (string, bool) {
+	for _, choice := range arg.choices {
+		if strings.EqualFold(choice, value) {
+			return choice, true
+		}
+	}
+	return "", false
+}
+
+// idOrMention accepts either a Discord mention/reference or a raw snowflake ID
+func idOrMention(flag, value string, extract func(string) (string, bool), expected string) (string, error) {
+	if id, ok := extract(value); ok {
+		return id, nil
+	}
+	if cmdparse.IsInt(value) {
+		return value, nil
+	}
+	return "", cmdparse.NewInvalidValueError(flag, value, expected)
+}
+
+// isDiscordReference reports whether a token is a user, role or channel reference
+func isDiscordReference(token string) bool {
+	if _, ok := cmdparse.UserID(token); ok {
+		return true
+	}
+	if _, ok := cmdparse.RoleID(token); ok {
+		return true
+	}
+	_, ok := cmdparse.ChannelID(token)
+	return ok
+}
+
+// parseProvider recognizes a provider name, ignoring case
+func parseProvider(value string) (string, bool) {
+	lower := strings.ToLower(value)
+	if lower == ai.ProviderGrok || lower == ai.ProviderOpenAI {
+		return lower, true
+	}
+	return "", false
+}
+
+// capitalize upper-cases the first letter of an error message shown to users
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// value returns a bound argument value
+func (r *commandRequest) value(name string) (string, bool) {
+	v, ok := r.values[name]
+	return v, ok
+}
+
+// text returns a free-text argument: its flag value if one was given, otherwise the
+// remaining words of the command
+func (r *commandRequest) text(name string) string {
+	if v, ok := r.values[name]; ok {
+		return v
+	}
+	return strings.Join(r.args, " ")
+}
+
+// intValue returns an integer argument, or fallback when it wasn't given
+func (r *commandRequest) intValue(name string, fallback int) int {
+	if v, ok := r.values[name]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// userArg resolves a user argument to a user. Discord includes mentioned users with the
+// message; a raw ID given as a flag is looked up instead.
+func (b *Bot) userArg(req *commandRequest, name string) (*discordgo.User, bool) {
+	userID, ok := req.values[name]
+	if !ok {
+		return nil, false
+	}
+	for _, user := range req.m.Mentions {
+		if user.ID == userID {
+			return user, true
+		}
+	}
+	user, err := b.session.User(userID)
+	if err != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+// modelOr returns the --model override, or fallback when none was given
+func (r *commandRequest) modelOr(fallback string) string {
+	if r.model != "" {
+		return r.model
+	}
+	return fallback
+}
@@ -0,0 +1,234 @@
+package bot
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/cmdparse"
+)
+
+func TestParseCommandArgs(t *testing.T) {
+	registry := mustCommandRegistry(t)
+
+	tests := []struct {
+		name         string
+		command      string
+		tokens       []string
+		wantValues   map[string]string
+		wantProvider string
+		wantModel    string
+		wantRest     []string
+	}{
+		{
+			name:         "defaults",
+			command:      "opinion",
+			wantValues:   map[string]string{},
+			wantProvider: "grok",
+		},
+		{
+			name:         "provider before count",
+			command:      "opinion",
+			tokens:       []string{"openai", "50"},
+			wantValues:   map[string]string{"messages": "50"},
+			wantProvider: "openai",
+		},
+		{
+			name:         "provider after count",
+			command:      "opinion",
+			tokens:       []string{"50", "OpenAI"},
+			wantValues:   map[string]string{"messages": "50"},
+			wantProvider: "openai",
+		},
+		{
+			name:         "flag alias and model",
+			command:      "who_won",
+			tokens:       []string{"--n=50", "--provider=openai", "--model", "gpt-4o"},
+			wantValues:   map[string]string{"messages": "50"},
+			wantProvider: "openai",
+			wantModel:    "gpt-4o",
+		},
+		{
+			name:         "mention, days and max messages",
+			command:      "user_opinion",
+			tokens:       []string{"<@123456>", "10", "500"},
+			wantValues:   map[string]string{"user": "123456", "days": "10", "max_messages": "500"},
+			wantProvider: "openai",
+		},
+		{
+			name:         "days before mention",
+			command:      "user_opinion",
+			tokens:       []string{"5", "<@!123456>"},
+			wantValues:   map[string]string{"user": "123456", "days": "5"},
+			wantProvider: "openai",
+		},
+		{
+			name:         "provider after mention",
+			command:      "user_opinion",
+			tokens:       []string{"<@123456>", "grok", "--days=7"},
+			wantValues:   map[string]string{"user": "123456", "days": "7"},
+			wantProvider: "grok",
+		},
+		{
+			name:         "unknown flag in a question stays text",
+			command:      "ask",
+			tokens:       []string{"what", "does", "--force", "do"},
+			wantValues:   map[string]string{},
+			wantProvider: "grok",
+			wantRest:     []string{"what", "does", "--force", "do"},
+		},
+		{
+			name:         "known flag in a question is parsed",
+			command:      "ask",
+			tokens:       []string{"what", "does", "--provider=openai", "think"},
+			wantValues:   map[string]string{},
+			wantProvider: "openai",
+			wantRest:     []string{"what", "does", "think"},
+		},
+		{
+			name:         "leading provider on free text",
+			command:      "ask",
+			tokens:       []string{"openai", "who", "is", "best"},
+			wantValues:   map[string]string{},
+			wantProvider: "openai",
+			wantRest:     []string{"who", "is", "best"},
+		},
+		{
+			name:         "provider word inside a question is kept",
+			command:      "ask",
+			tokens:       []string{"is", "grok", "better"},
+			wantValues:   map[string]string{},
+			wantProvider: "grok",
+			wantRest:     []string{"is", "grok", "better"},
+		},
+		{
+			name:       "choice matched case-insensitively",
+			command:    "roast",
+			tokens:     []string{"<@42>", "SAVAGE"},
+			wantValues: map[string]string{"user": "42", "intensity": "savage"},
+		},
+		{
+			name:       "raw ID as flag",
+			command:    "roast",
+			tokens:     []string{"--user=42"},
+			wantValues: map[string]string{"user": "42"},
+		},
+		{
+			name:         "channel and message link",
+			command:      "opinion",
+			tokens:       []string{"<#200>", "<https://discord.com/channels/1/200/3>", "50"},
+			wantValues:   map[string]string{"messages": "50", "channel": "200", "message": "https://discord.com/channels/1/200/3"},
+			wantProvider: "grok",
+		},
+		{
+			name:         "time window",
+			command:      "who_won",
+			tokens:       []string{"18:00", "2H", "20:00"},
+			wantValues:   map[string]string{"from": "18:00", "since": "2h", "to": "20:00"},
+			wantProvider: "grok",
+		},
+		{
+			name:         "times in free text stay text",
+			command:      "most",
+			tokens:       []string{"who", "said", "they'd", "be", "there", "at", "6pm", "2h", "--since", "3h"},
+			wantValues:   map[string]string{"since": "3h"},
+			wantProvider: "openai",
+			wantRest:     []string{"who", "said", "they'd", "be", "there", "at", "6pm", "2h"},
+		},
+		{
+			name:       "message link instead of a mention",
+			command:    "roast",
+			tokens:     []string{"https://discord.com/channels/1/2/3", "mild"},
+			wantValues: map[string]string{"message": "https://discord.com/channels/1/2/3", "intensity": "mild"},
+		},
+		{
+			name:       "extra mentions are kept",
+			command:    "roast",
+			tokens:     []string{"<@1>", "<@2>"},
+			wantValues: map[string]string{"user": "1"},
+			wantRest:   []string{"<@2>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := registry.lookup(tt.command)
+			if !ok {
+				t.Fatalf("command %q not registered", tt.command)
+			}
+
+			got, err := parseCommandArgs(cmd, tt.tokens)
+			if err != nil {
+				t.Fatalf("parseCommandArgs(%q) error = %v", tt.tokens, err)
+			}
+			if !maps.Equal(got.values, tt.wantValues) {
+				t.Errorf("values = %v, want %v", got.values, tt.wantValues)
+			}
+			if got.provider != tt.wantProvider {
+				t.Errorf("provider = %q, want %q", got.provider, tt.wantProvider)
+			}
+			if got.model != tt.wantModel {
+				t.Errorf("model = %q, want %q", got.model, tt.wantModel)
+			}
+			if !slices.Equal(got.rest, tt.wantRest) {
+				t.Errorf("rest = %q, want %q", got.rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestParseCommandArgsErrors(t *testing.T) {
+	registry := mustCommandRegistry(t)
+
+	tests := []struct {
+		name    string
+		command string
+		tokens  []string
+		wantErr any
+	}{
+		{name: "unknown flag", command: "opinion", tokens: []string{"--days=3"}, wantErr: &cmdparse.UnknownFlagError{}},
+		{name: "bad integer", command: "opinion", tokens: []string{"--n=lots"}, wantErr: &cmdparse.InvalidValueError{}},
+		{name: "zero count", command: "opinion", tokens: []string{"--n=0"}, wantErr: &cmdparse.InvalidValueError{}},
+		{name: "bad choice", command: "roast", tokens: []string{"--intensity=nuclear"}, wantErr: &cmdparse.InvalidValueError{}},
+		{name: "bad provider", command: "ask", tokens: []string{"--provider=bard", "hi"}, wantErr: &cmdparse.InvalidValueError{}},
+		{name: "missing value at end", command: "opinion", tokens: []string{"--n"}, wantErr: &cmdparse.MissingValueError{}},
+		{name: "empty value", command: "opinion", tokens: []string{"--n="}, wantErr: &cmdparse.MissingValueError{}},
+		{name: "provider flag on command without one", command: "roast", tokens: []string{"--provider=grok"}, wantErr: &cmdparse.UnknownFlagError{}},
+		{name: "bad message link", command: "who_won", tokens: []string{"--message=https://example.com/1/2/3"}, wantErr: &cmdparse.InvalidValueError{}},
+		{name: "bad time", command: "most", tokens: []string{"--from=teatime", "funniest"}, wantErr: &cmdparse.InvalidValueError{}},
+		{name: "leftover word", command: "opinion", tokens: []string{"lots"}},
+		{name: "second provider word", command: "opinion", tokens: []string{"grok", "openai"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := registry.lookup(tt.command)
+			if !ok {
+				t.Fatalf("command %q not registered", tt.command)
+			}
+
+			_, err := parseCommandArgs(cmd, tt.tokens)
+			if err == nil {
+				t.Fatalf("parseCommandArgs(%q) succeeded, want error", tt.tokens)
+			}
+			switch tt.wantErr.(type) {
+			case *cmdparse.UnknownFlagError:
+				var target *cmdparse.UnknownFlagError
+				if !errors.As(err, &target) {
+					t.Errorf("error = %v, want UnknownFlagError", err)
+				}
+			case *cmdparse.InvalidValueError:
+				var target *cmdparse.InvalidValueError
+				if !errors.As(err, &target) {
+					t.Errorf("error = %v, want InvalidValueError", err)
+				}
+			case *cmdparse.MissingValueError:
+				var target *cmdparse.MissingValueError
+				if !errors.As(err, &target) {
+					t.Errorf("error = %v, want MissingValueError", err)
+				}
+			}
+		})
+	}
+}
@@ -4,23 +4,65 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"strconv"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 
 	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/archive"
+	"github.com/Dmetrikx/goDiscordChatter/internal/cmdparse"
 	"github.com/Dmetrikx/goDiscordChatter/internal/config"
 	"github.com/Dmetrikx/goDiscordChatter/internal/discord"
+	"github.com/Dmetrikx/goDiscordChatter/internal/facts"
+	"github.com/Dmetrikx/goDiscordChatter/internal/memory"
+	"github.com/Dmetrikx/goDiscordChatter/internal/moderation"
+	"github.com/Dmetrikx/goDiscordChatter/internal/redact"
+	"github.com/Dmetrikx/goDiscordChatter/internal/search"
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
+	"github.com/Dmetrikx/goDiscordChatter/internal/vectors"
+	"github.com/Dmetrikx/goDiscordChatter/internal/webfetch"
 )
 
 // Bot represents the Discord bot
 type Bot struct {
-	session  discord.Session
-	aiClient ai.Client
-	config   *config.Config
+	session           discord.Session
+	aiClient          ai.Client
+	fetcher           *webfetch.Fetcher
+	settings          *settings.Store
+	moderator         *moderation.Moderator
+	defaultModeration moderation.Level
+	redactor          *redact.Redactor
+	memory            *memory.Store
+	facts             *facts.Store
+	archive           *archive.Archive
+	vectors           *vectors.Index
+	search            *search.Index
+	jobs              *jobQueue
+	members           *memberCache
+	commands          *commandRegistry
+	cooldowns         *cooldownTracker
+	config            *config.Config
+	location          *time.Location
+	// captions caches image captions for history transcripts
+	captions captionCache
 	logger   *slog.Logger
+	// indexing serializes updates to the recall index
+	indexing sync.Mutex
+	// background is cancelled on Close to stop backfill, pruning, indexing and jobs
+	background     context.Context
+	stopBackground context.CancelFunc
+	// lifetime is the context message and slash command handlers run under. It is only
+	// cancelled when Close gives up waiting for them.
+	lifetime     context.Context
+	stopHandlers context.CancelFunc
+	// handlers tracks in-flight handlers and jobs, so Close can wait for them
+	handlers sync.WaitGroup
+	// lifecycle guards closing, which stops new handlers once Close begins
+	lifecycle sync.Mutex
+	closing   bool
 }
 
 // NewBot creates a new bot instance
@@ -30,17 +72,111 @@ func NewBot(cfg *config.Config, logger *slog.Logger) (*Bot, error) {
 		return nil, fmt.Errorf("error creating Discord session: %w", err)
 	}
 
+	// Member events need the privileged Server Members intent enabled in the developer portal
+	if cfg.MembersIntent {
+		session.Identify.Intents |= discordgo.IntentsGuildMembers
+	}
+
 	aiClient := ai.NewAIClient(cfg.OpenAIAPIKey, cfg.XAIAPIKey, logger)
 
-	bot := &Bot{
-		session:  session,
-		aiClient: aiClient,
-		config:   cfg,
-		logger:   logger,
+	guildSettings, err := settings.NewStore(filepath.Join(cfg.DataDir, GuildSettingsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	defaultModeration, err := moderation.ParseLevel(cfg.ModerationLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MODERATION_LEVEL: %w", err)
+	}
+
+	location, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TIME_ZONE: %w", err)
+	}
+
+	moderator, err := newModerator(aiClient, cfg.OpenAIAPIKey != "", cfg.ModerationBlocklist)
+	if err != nil {
+		return nil, err
+	}
+
+	redactor, err := newRedactor(cfg.PIIPatternsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryStore, err := newMemoryStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	factStore, err := newFactStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	messageArchive, err := newArchive(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	vectorIndex, err := newVectorIndex(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	// Register message handler
+	searchIndex, err := newSearchIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	jobQueue, err := newJobQueue(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	commands, err := newCommandRegistry(defaultCommands())
+	if err != nil {
+		return nil, err
+	}
+
+	background, stopBackground := context.WithCancel(context.Background())
+	lifetime, stopHandlers := context.WithCancel(context.Background())
+
+	bot := &Bot{
+		session:           session,
+		aiClient:          aiClient,
+		fetcher:           webfetch.NewFetcher(TLDRFetchTimeout, TLDRMaxPageBytes),
+		settings:          guildSettings,
+		moderator:         moderator,
+		defaultModeration: defaultModeration,
+		redactor:          redactor,
+		memory:            memoryStore,
+		facts:             factStore,
+		archive:           messageArchive,
+		vectors:           vectorIndex,
+		search:            searchIndex,
+		jobs:              jobQueue,
+		members:           newMemberCache(MemberCacheTTL, MemberErrorTTL),
+		commands:          commands,
+		cooldowns:         newCooldownTracker(),
+		config:            cfg,
+		location:          location,
+		logger:            logger,
+		background:        background,
+		stopBackground:    stopBackground,
+		lifetime:          lifetime,
+		stopHandlers:      stopHandlers,
+	}
+
+	// Register message and slash command handlers
 	session.AddHandler(bot.messageHandler)
+	session.AddHandler(bot.interactionHandler)
+	session.AddHandler(bot.guildMemberAddHandler)
+	session.AddHandler(bot.guildMemberUpdateHandler)
+	session.AddHandler(bot.guildMemberRemoveHandler)
+	if messageArchive != nil {
+		bot.registerArchiveHandlers()
+	}
 
 	return bot, nil
 }
@@ -61,31 +197,149 @@ func (b *Bot) Start(ctx context.Context) error {
 		"username", user.Username,
 		"user_id", user.ID)
 
+	if err := b.registerSlashCommands(ctx, user.ID); err != nil {
+		// Prefix commands still work, so this isn't fatal
+		b.logger.ErrorContext(ctx, "failed to register slash commands", "error", err)
+	}
+
+	go b.pruneArchive(b.background)
+	go b.indexLoop(b.background)
+	b.resumeJobs(ctx)
+
 	return nil
 }
 
-// Close closes the bot session
+// Close stops accepting commands, lets in-flight handlers and jobs finish until shortly
+// before ctx's deadline, and then closes the session and stores. Jobs still running by
+// then resume on the next start.
 func (b *Bot) Close(ctx context.Context) error {
 	b.logger.InfoContext(ctx, "closing bot session")
-	return b.session.Close()
+
+	b.lifecycle.Lock()
+	b.closing = true
+	b.lifecycle.Unlock()
+
+	b.drain(ctx)
+	b.stopBackground()
+
+	err := b.session.Close()
+	if b.search != nil {
+		if searchErr := b.search.Close(); searchErr != nil && err == nil {
+			err = fmt.Errorf("error closing search index: %w", searchErr)
+		}
+	}
+	if b.vectors != nil {
+		if vectorsErr := b.vectors.Close(); vectorsErr != nil && err == nil {
+			err = fmt.Errorf("error closing recall index: %w", vectorsErr)
+		}
+	}
+	if b.archive != nil {
+		if archiveErr := b.archive.Close(); archiveErr != nil && err == nil {
+			err = fmt.Errorf("error closing message archive: %w", archiveErr)
+		}
+	}
+	return err
 }
 
-// messageHandler handles incoming messages
+// beginHandler registers an in-flight handler and reports whether the bot still accepts
+// work. Callers that get true must call b.handlers.Done when they finish.
+func (b *Bot) beginHandler() bool {
+	b.lifecycle.Lock()
+	defer b.lifecycle.Unlock()
+	if b.closing {
+		return false
+	}
+	b.handlers.Add(1)
+	return true
+}
+
+// handlerContext returns the context handlers run under
+func (b *Bot) handlerContext() context.Context {
+	if b.lifetime == nil {
+		return context.Background()
+	}
+	return b.lifetime
+}
+
+// drain waits for in-flight handlers and jobs to finish. Jobs run under the background
+// context, so it stays live until then. ShutdownCancelGrace before ctx's deadline,
+// handlers and jobs are cancelled and get the rest of the time to return, so the session
+// and stores are closed on time; cancelled jobs stay active and resume on the next start.
+func (b *Bot) drain(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		b.handlers.Wait()
+		close(done)
+	}()
+
+	waitCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithDeadline(ctx, deadline.Add(-ShutdownCancelGrace))
+		defer cancel()
+	}
+	select {
+	case <-done:
+		return
+	case <-waitCtx.Done():
+	}
+
+	b.logger.WarnContext(ctx, "shutdown timeout reached, cancelling in-flight handlers and jobs")
+	if b.stopHandlers != nil {
+		b.stopHandlers()
+	}
+	b.stopBackground()
+
+	grace := ShutdownCancelGrace
+	if deadline, ok := ctx.Deadline(); ok {
+		grace = min(grace, time.Until(deadline))
+	}
+	select {
+	case <-done:
+	case <-time.After(grace):
+		b.logger.ErrorContext(ctx, "in-flight handlers did not stop after cancellation")
+	}
+}
+
+// messageHandler handles incoming messages. Once the bot is closing, messages are
+// ignored; the archive backfills them on the next start.
 func (b *Bot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	ctx := context.Background()
+	if !b.beginHandler() {
+		return
+	}
+	defer b.handlers.Done()
+	ctx := b.handlerContext()
+
+	// Everything is archived, the bot's own replies included, so history reads match Discord's
+	b.archiveMessage(ctx, m.Message)
+	b.cacheMessageMember(m)
 
 	// Ignore messages from the bot itself
 	if m.Author.ID == s.State.User.ID {
 		return
 	}
 
-	// Check if message starts with command prefix
+	// Mentions and replies to the bot are answered conversationally. Other bots are
+	// ignored so two bots can't talk to each other forever.
 	if !strings.HasPrefix(m.Content, "!") {
+		if !m.Author.Bot && isConversationTrigger(m.Message, s.State.User.ID) {
+			b.handleConversation(ctx, m, s.State.User.ID)
+		}
 		return
 	}
 
-	// Parse command and arguments
-	parts := strings.Fields(m.Content)
+	// Parse command and arguments. Free text such as a question keeps its quotes.
+	tokenize := cmdparse.Tokenize
+	if fields := strings.Fields(m.Content); len(fields) > 0 {
+		if cmd, ok := b.commands.lookup(strings.TrimPrefix(fields[0], "!")); ok && cmd.takesFreeText() {
+			tokenize = cmdparse.TokenizeText
+		}
+	}
+	parts, err := tokenize(m.Content)
+	if err != nil {
+		b.send(ctx, m.ChannelID, capitalize(err.Error())+".")
+		return
+	}
 	if len(parts) == 0 {
 		return
 	}
@@ -100,321 +354,341 @@ func (b *Bot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
 		"channel_id", m.ChannelID,
 		"args_count", len(args))
 
-	// Route to appropriate command handler
-	switch command {
-	case "ping":
-		b.handlePing(ctx, s, m)
-	case "ask":
-		b.handleAsk(ctx, s, m, args)
-	case "opinion":
-		b.handleOpinion(ctx, s, m, args)
-	case "who_won":
-		b.handleWhoWon(ctx, s, m, args)
-	case "user_opinion":
-		b.handleUserOpinion(ctx, s, m, args)
-	case "most":
-		b.handleMost(ctx, s, m, args)
-	case "image_opinion":
-		b.handleImageOpinion(ctx, s, m, args)
-	case "roast":
-		b.handleRoast(ctx, s, m, args)
-	default:
-		b.logger.InfoContext(ctx, "unknown command", "command", command)
-	}
+	b.dispatch(ctx, command, m, args)
 }
 
 // handlePing responds with "Pong!"
-func (b *Bot) handlePing(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate) {
-	_, err := s.ChannelMessageSend(m.ChannelID, "Pong!")
+func (b *Bot) handlePing(ctx context.Context, req *commandRequest) {
+	_, err := b.send(ctx, req.m.ChannelID, "Pong!")
 	if err != nil {
 		b.logger.ErrorContext(ctx, "failed to send ping response", "error", err)
 	}
 }
 
 // handleAsk handles the !ask command
-func (b *Bot) handleAsk(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	if len(args) == 0 {
-		s.ChannelMessageSend(m.ChannelID, "Usage: !ask [grok|openai] <question>")
+func (b *Bot) handleAsk(ctx context.Context, req *commandRequest) {
+	m := req.m
+	prompt := req.text("question")
+	if prompt == "" {
+		b.sendUsage(ctx, req, "")
 		return
 	}
 
-	provider, args := extractProviderAndArgs(args, ai.DefaultProvider)
-	prompt := strings.Join(args, " ")
-
+	provider := req.provider
 	model := ai.DefaultGrokModel
 	persona := ai.GrokPersona
 	if provider == ai.ProviderOpenAI {
 		model = ai.DefaultOpenAIModel
 		persona = ai.OpenAIPersona
 	}
+	model = req.modelOr(model)
 
-	b.sendThinkingMessage(ctx, s, m.ChannelID, provider, model)
+	b.sendThinkingMessage(ctx, m.ChannelID, provider, model)
 
-	response, err := b.aiClient.AskClient(ctx, prompt, persona, model, provider, ai.DefaultMaxTokens)
+	// Earlier turns from this user in this channel let follow-up questions keep their
+	// context, and remembered facts let the bot bring up what it knows about people
+	turns := append(b.factTurns(m, prompt), b.rememberedTurns(m, prompt)...)
+	response, err := b.aiClient.Chat(ctx, turns, persona, model, provider, ai.DefaultMaxTokens)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "AI request failed",
 			"command", "ask",
 			"provider", provider,
 			"error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
 		return
 	}
 
+	response = b.moderateResponse(ctx, m, "ask", response)
 	b.sendLongResponse(ctx, m.ChannelID, response)
+	b.remember(ctx, m, prompt, response, provider)
+	b.extractFacts(ctx, m, prompt, provider)
 }
 
 // handleOpinion handles the !opinion command
-func (b *Bot) handleOpinion(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	s.ChannelMessageSend(m.ChannelID, "Let me think about what everyone has been saying...")
+func (b *Bot) handleOpinion(ctx context.Context, req *commandRequest) {
+	m := req.m
+	target, ok := b.resolveHistoryTarget(ctx, req)
+	if !ok {
+		return
+	}
+	b.send(ctx, m.ChannelID, "Let me think about what everyone has been saying...")
 
-	provider, args := extractProviderAndArgs(args, ai.DefaultProvider)
+	provider := req.provider
 	model := ai.DefaultGrokModel
 	persona := ai.GrokPersona
 	if provider == ai.ProviderOpenAI {
 		model = ai.DefaultOpenAIModel
 		persona = ai.OpenAIPersona
 	}
+	model = req.modelOr(model)
 
-	numMessages := DefaultHistoryMessageCount
-	if len(args) > 0 {
-		if n, err := strconv.Atoi(args[0]); err == nil {
-			numMessages = n
-		}
-	}
+	numMessages := target.messageCount(req, DefaultHistoryMessageCount)
 
-	contextStr, err := b.formatChannelHistory(ctx, m.ChannelID, numMessages)
+	lines, err := b.formatChannelHistory(ctx, target.query(m, numMessages), m.GuildID)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "failed to fetch channel history", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error fetching messages: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error fetching messages: %v", err))
+		return
+	}
+	if len(lines) == 0 {
+		b.send(ctx, m.ChannelID, "There are no messages to read there.")
+		return
+	}
+	history, err := b.condenseHistory(ctx, m.ChannelID, provider, model, lines, "the topics, opinions and mood of the conversation")
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to summarize channel history", "command", "opinion", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error summarizing messages: %v", err))
 		return
 	}
 
-	systemMessage := fmt.Sprintf("%s\nHere are the last %d messages in this channel:\n%s\n"+
-		"Form an opinion or summary about the conversation.", persona, numMessages, contextStr)
+	systemMessage := buildSystemMessage(persona, fmt.Sprintf("The user turn contains %s. "+
+		"Form an opinion or summary about the conversation.", target.describe(m, numMessages))+history.note())
+	prompt := buildDataPrompt("What is your opinion on the recent conversation?",
+		untrustedBlock("channel history", history.text))
 
-	response, err := b.aiClient.AskClient(ctx, "What is your opinion on the recent conversation?",
-		systemMessage, model, provider, ai.DefaultMaxTokens)
+	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, model, provider, ai.DefaultMaxTokens)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "AI request failed", "command", "opinion", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
 		return
 	}
 
-	b.sendLongResponse(ctx, m.ChannelID, response)
+	b.sendLongResponse(ctx, m.ChannelID, b.moderateResponse(ctx, m, "opinion", response))
 }
 
 // handleWhoWon handles the !who_won command
-func (b *Bot) handleWhoWon(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	s.ChannelMessageSend(m.ChannelID, "Analyzing the last arguments...")
+func (b *Bot) handleWhoWon(ctx context.Context, req *commandRequest) {
+	m := req.m
+	target, ok := b.resolveHistoryTarget(ctx, req)
+	if !ok {
+		return
+	}
+	b.send(ctx, m.ChannelID, "Analyzing the last arguments...")
 
-	provider, args := extractProviderAndArgs(args, ai.DefaultProvider)
+	provider := req.provider
 	model := ai.DefaultGrokModel
 	persona := ai.GrokPersona
 	if provider == ai.ProviderOpenAI {
 		model = ai.DefaultOpenAIModel
 		persona = ai.OpenAIPersona
 	}
+	model = req.modelOr(model)
 
-	numMessages := DefaultWhoWonMessageCount
-	if len(args) > 0 {
-		if n, err := strconv.Atoi(args[0]); err == nil {
-			numMessages = n
-		}
-	}
+	numMessages := target.messageCount(req, DefaultWhoWonMessageCount)
 
-	contextStr, err := b.formatChannelHistory(ctx, m.ChannelID, numMessages)
+	lines, err := b.formatChannelHistory(ctx, target.query(m, numMessages), m.GuildID)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "failed to fetch channel history", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error fetching messages: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error fetching messages: %v", err))
+		return
+	}
+	if len(lines) == 0 {
+		b.send(ctx, m.ChannelID, "There are no messages to read there.")
+		return
+	}
+	history, err := b.condenseHistory(ctx, m.ChannelID, provider, model, lines,
+		"every argument: who took which side, the points each made and how it ended")
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to summarize channel history", "command", "who_won", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error summarizing messages: %v", err))
 		return
 	}
 
-	systemMessage := fmt.Sprintf("%s\nHere are the last %d messages in this channel:\n%s\n"+
+	systemMessage := buildSystemMessage(persona, fmt.Sprintf("The user turn contains %s. "+
 		"Based on the arguments and discussions, determine who won the arguments and why. "+
-		"Be specific and fair, and explain your reasoning.", persona, numMessages, contextStr)
+		"Be specific and fair, and explain your reasoning.", target.describe(m, numMessages))+history.note())
+	prompt := buildDataPrompt("Who won the arguments in the recent conversation?",
+		untrustedBlock("channel history", history.text))
 
-	response, err := b.aiClient.AskClient(ctx, "Who won the arguments in the recent conversation?",
-		systemMessage, model, provider, ai.DefaultMaxTokens)
+	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, model, provider, ai.DefaultMaxTokens)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "AI request failed", "command", "who_won", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
 		return
 	}
 
-	b.sendLongResponse(ctx, m.ChannelID, response)
+	b.sendLongResponse(ctx, m.ChannelID, b.moderateResponse(ctx, m, "who_won", response))
 }
 
 // handleUserOpinion handles the !user_opinion command
-func (b *Bot) handleUserOpinion(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	if len(args) == 0 {
-		s.ChannelMessageSend(m.ChannelID, "Usage: !user_opinion @user [grok|openai] [days] [max_messages]")
+func (b *Bot) handleUserOpinion(ctx context.Context, req *commandRequest) {
+	m := req.m
+	targetUser, ok := b.userArg(req, "user")
+	if !ok {
+		b.sendUsage(ctx, req, "")
 		return
 	}
 
-	// Parse mentioned user
-	if len(m.Mentions) == 0 {
-		s.ChannelMessageSend(m.ChannelID, "Please mention a user to analyze.")
+	if !b.canTarget(m.GuildID, targetUser.ID, settings.OptOutAnalysis) {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("%s has opted out of being analyzed.", targetUser.Username))
+		return
+	}
+	target, ok := b.resolveHistoryTarget(ctx, req)
+	if !ok {
 		return
 	}
-	targetUser := m.Mentions[0]
 
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Analyzing %s...", targetUser.Username))
+	targetName := b.displayName(m.GuildID, targetUser)
+	b.send(ctx, m.ChannelID, fmt.Sprintf("Analyzing %s...", targetName))
 
-	provider, days, maxMessages := parseUserOpinionArgs(args)
+	days := req.intValue("days", DefaultUserOpinionDays)
+	maxMessages := req.intValue("max_messages", DefaultUserOpinionMaxMessages)
 
 	// Fetch messages from the user
-	userMessages, err := b.fetchUserMessages(ctx, s, m.ChannelID, m.GuildID, targetUser, days, maxMessages)
+	q := target.query(m, maxMessages)
+	q.since = time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	userMessages, err := b.fetchUserMessages(ctx, q, m.GuildID, targetUser)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "failed to fetch user messages", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error fetching messages: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error fetching messages: %v", err))
 		return
 	}
 
 	if len(userMessages) == 0 {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("No messages found for %s in the last %d days.", targetUser.Username, days))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("No messages found for %s in the last %d days.", targetName, days))
 		return
 	}
 
-	contextStr := strings.Join(userMessages, "\n")
-	systemMessage := fmt.Sprintf("Here are all the messages sent by %s in the last %d days in this channel:\n%s\n",
-		targetUser.Username, days, contextStr)
-
-	response, err := b.aiClient.AskClient(ctx, fmt.Sprintf("What is your opinion of %s?", targetUser.Username),
-		systemMessage, ai.DefaultOpenAIModel, provider, ai.DefaultMaxTokens)
+	model := req.modelOr(ai.DefaultOpenAIModel)
+	history, err := b.condenseHistory(ctx, m.ChannelID, req.provider, model, userMessages,
+		"what they talk about, their opinions, tone and habits")
 	if err != nil {
-		b.logger.ErrorContext(ctx, "AI request failed", "command", "user_opinion", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error: %v", err))
+		b.logger.ErrorContext(ctx, "failed to summarize member messages", "command", "user_opinion", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error summarizing messages: %v", err))
 		return
 	}
 
-	b.sendLongResponse(ctx, m.ChannelID, response)
-}
+	systemMessage := buildSystemMessage(ai.OpenAIPersona, fmt.Sprintf("The user turn contains every message one member "+
+		"sent in %s over the last %d days. Form an opinion of that member based on them.", target.where(m), days)+history.note())
+	prompt := buildDataPrompt("What is your opinion of the member named in the target block?",
+		untrustedBlock("target", targetName),
+		untrustedBlock("member messages", history.text))
 
-// parseUserOpinionArgs parses arguments for the user_opinion command
-func parseUserOpinionArgs(args []string) (provider string, days int, maxMessages int) {
-	provider = "openai"
-	days = DefaultUserOpinionDays
-	maxMessages = DefaultUserOpinionMaxMessages
-
-	// Remove the mention from args
-	argsWithoutMention := []string{}
-	for _, arg := range args {
-		if !strings.HasPrefix(arg, "<@") {
-			argsWithoutMention = append(argsWithoutMention, arg)
-		}
-	}
-
-	provider, remainingArgs := extractProviderAndArgs(argsWithoutMention, "openai")
-
-	if len(remainingArgs) > 0 {
-		if n, err := strconv.Atoi(remainingArgs[0]); err == nil {
-			days = n
-			remainingArgs = remainingArgs[1:]
-		}
-	}
-
-	if len(remainingArgs) > 0 {
-		if n, err := strconv.Atoi(remainingArgs[0]); err == nil {
-			maxMessages = n
-		}
+	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, model, req.provider, ai.DefaultMaxTokens)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "AI request failed", "command", "user_opinion", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
+		return
 	}
 
-	return provider, days, maxMessages
+	b.sendLongResponse(ctx, m.ChannelID, b.moderateResponse(ctx, m, "user_opinion", response))
 }
 
-// fetchUserMessages fetches messages from a specific user within a time window
-func (b *Bot) fetchUserMessages(ctx context.Context, s *discordgo.Session, channelID, guildID string, targetUser *discordgo.User, days int, maxMessages int) ([]string, error) {
-	after := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
-	allMessages, err := s.ChannelMessages(channelID, maxMessages, "", "", "")
+// fetchUserMessages fetches the messages a specific user sent among those q selects
+func (b *Bot) fetchUserMessages(ctx context.Context, q historyQuery, guildID string, targetUser *discordgo.User) ([]string, error) {
+	allMessages, err := b.fetchHistory(ctx, q)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch channel messages: %w", err)
+		return nil, err
+	}
+
+	var gs settings.GuildSettings
+	if b.settings != nil {
+		gs = b.settings.Guild(guildID)
 	}
 
-	var userMessages []string
+	var userMessages []*discordgo.Message
 	for _, msg := range allMessages {
-		if msg.Author.ID == targetUser.ID && msg.Timestamp.After(after) {
-			member, err := s.GuildMember(guildID, msg.Author.ID)
-			displayName := msg.Author.Username
-			if err == nil && member.Nick != "" {
-				displayName = member.Nick
+		if msg.Author.ID == targetUser.ID {
+			var roles []string
+			if member, err := b.guildMember(guildID, msg.Author.ID); err == nil {
+				roles = member.Roles
 			}
-			userMessages = append(userMessages, fmt.Sprintf("%s: %s", displayName, msg.Content))
+			// Never collect messages from members who opted out of analysis
+			if !targetAllowed(gs, msg.Author.ID, roles, settings.OptOutAnalysis) {
+				continue
+			}
+			userMessages = append(userMessages, msg)
 		}
 	}
 
-	return userMessages, nil
+	return b.formatHistory(ctx, guildID, userMessages), nil
 }
 
 // handleMost handles the !most command
-func (b *Bot) handleMost(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
-	if len(args) == 0 {
-		s.ChannelMessageSend(m.ChannelID, "Usage: !most [grok|openai] <question>")
+func (b *Bot) handleMost(ctx context.Context, req *commandRequest) {
+	m := req.m
+	question := req.text("question")
+	if question == "" {
+		b.sendUsage(ctx, req, "")
+		return
+	}
+	target, ok := b.resolveHistoryTarget(ctx, req)
+	if !ok {
 		return
 	}
 
-	numMessages := DefaultMostMessageCount
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Analyzing: %s (last %d messages)...", strings.Join(args, " "), numMessages))
+	numMessages := target.messageCount(req, DefaultMostMessageCount)
+	if target.window != "" {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Analyzing: %s (messages sent %s)...", question, target.window))
+	} else {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Analyzing: %s (last %d messages)...", question, numMessages))
+	}
 
-	provider, args := extractProviderAndArgs(args, "openai")
-	question := strings.Join(args, " ")
+	provider := req.provider
 
-	messages, userCounts, err := b.fetchAndCountMessages(ctx, s, m.ChannelID, m.GuildID, numMessages)
+	messages, userCounts, err := b.fetchAndCountMessages(ctx, target.query(m, numMessages), m.GuildID)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "failed to fetch messages", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error fetching messages: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error fetching messages: %v", err))
+		return
+	}
+	if len(messages) == 0 {
+		b.send(ctx, m.ChannelID, "There are no messages to read there.")
 		return
 	}
 
 	activeUserNames := getTopActiveUsers(userCounts, TopActiveUsersCount)
-	contextStr := strings.Join(messages, "\n")
 
-	prompt := question
+	request := question
 	if len(strings.Fields(question)) == 1 {
-		prompt = fmt.Sprintf("Who is the most %s in the recent conversation?", question)
+		request = fmt.Sprintf("Who is the most %s in the recent conversation?", question)
 	}
 
-	systemMessage := fmt.Sprintf("%s\nHere are the last %d messages in this channel:\n%s\n"+
-		"Among the most active users (%s), answer the following question: %s. "+
-		"Explain your reasoning as Coonbot.", ai.OpenAIPersona, numMessages, contextStr,
-		strings.Join(activeUserNames, ", "), question)
+	model := req.modelOr(ai.DefaultOpenAIModel)
+	history, err := b.condenseHistory(ctx, m.ChannelID, provider, model, messages,
+		"how each person behaves, their tone and their notable moments, so the people talking can be compared")
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to summarize channel history", "command", "most", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error summarizing messages: %v", err))
+		return
+	}
 
-	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, ai.DefaultOpenAIModel, provider, ai.DefaultMaxTokens)
+	systemMessage := buildSystemMessage(ai.OpenAIPersona, fmt.Sprintf("The user turn contains %s "+
+		"and the names of the most active users. Among those users, answer the question that "+
+		"follows the data. Explain your reasoning as Coonbot.", target.describe(m, numMessages))+history.note())
+	prompt := buildDataPrompt(request,
+		untrustedBlock("channel history", history.text),
+		untrustedBlock("most active users", strings.Join(activeUserNames, ", ")))
+
+	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, model, provider, ai.DefaultMaxTokens)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "AI request failed", "command", "most", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
 		return
 	}
 
-	b.sendLongResponse(ctx, m.ChannelID, response)
+	b.sendLongResponse(ctx, m.ChannelID, b.moderateResponse(ctx, m, "most", response))
 }
 
-// fetchAndCountMessages fetches messages and counts them by user
-func (b *Bot) fetchAndCountMessages(ctx context.Context, s *discordgo.Session, channelID, guildID string, numMessages int) ([]string, map[string]int, error) {
-	allMessages, err := s.ChannelMessages(channelID, numMessages, "", "", "")
+// fetchAndCountMessages fetches the messages q selects and counts them by user
+func (b *Bot) fetchAndCountMessages(ctx context.Context, q historyQuery, guildID string) ([]string, map[string]int, error) {
+	allMessages, err := b.fetchHistory(ctx, q)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to fetch channel messages: %w", err)
+		return nil, nil, err
 	}
 
-	var messages []string
+	var messages []*discordgo.Message
 	userMessageCount := make(map[string]int)
 
-	for i := len(allMessages) - 1; i >= 0; i-- {
-		msg := allMessages[i]
+	for _, msg := range allMessages {
 		if msg.Author.Bot {
 			continue
 		}
-
-		member, err := s.GuildMember(guildID, msg.Author.ID)
-		displayName := msg.Author.Username
-		if err == nil && member.Nick != "" {
-			displayName = member.Nick
-		}
-
-		messages = append(messages, fmt.Sprintf("%s: %s", displayName, msg.Content))
-		userMessageCount[displayName]++
+		messages = append(messages, msg)
+		userMessageCount[b.displayName(guildID, msg.Author)]++
 	}
 
-	return messages, userMessageCount, nil
+	return b.formatHistory(ctx, guildID, messages), userMessageCount, nil
 }
 
 // getTopActiveUsers returns the top N most active users from a count map
@@ -447,56 +721,63 @@ func getTopActiveUsers(userCounts map[string]int, topN int) []string {
 }
 
 // handleImageOpinion handles the !image_opinion command
-func (b *Bot) handleImageOpinion(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
+func (b *Bot) handleImageOpinion(ctx context.Context, req *commandRequest) {
+	m, args := req.m, req.args
 	var imageURL string
 	var customPrompt *string
 
-	provider, args := extractProviderAndArgs(args, "openai")
-	visionModel := ai.DefaultOpenAIVisionModel
+	provider := req.provider
+	visionModel := req.modelOr(ai.DefaultOpenAIVisionModel)
 
-	// Check for attachment first
-	if len(m.Attachments) > 0 {
-		imageURL = m.Attachments[0].URL
-		if len(args) > 0 {
-			prompt := strings.Join(args, " ")
+	// Named --url and --prompt flags (and the slash command options) take precedence
+	if url, ok := req.value("url"); ok {
+		imageURL = url
+	}
+	if prompt, ok := req.value("prompt"); ok {
+		customPrompt = &prompt
+	}
+	setPrompt := func(words []string) {
+		if customPrompt == nil && len(words) > 0 {
+			prompt := strings.Join(words, " ")
 			customPrompt = &prompt
 		}
+	}
+
+	if imageURL != "" {
+		setPrompt(args)
+	} else if len(m.Attachments) > 0 {
+		// Check for attachment first
+		imageURL = m.Attachments[0].URL
+		setPrompt(args)
 	} else if m.MessageReference != nil {
 		// If replying to a message
-		refMsg, err := s.ChannelMessage(m.ChannelID, m.MessageReference.MessageID)
+		refMsg, err := b.session.ChannelMessage(m.ChannelID, m.MessageReference.MessageID)
 		if err != nil {
 			b.logger.ErrorContext(ctx, "failed to fetch referenced message", "error", err)
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Could not fetch replied message: %v", err))
+			b.send(ctx, m.ChannelID, fmt.Sprintf("Could not fetch replied message: %v", err))
 			return
 		}
 		if len(refMsg.Attachments) > 0 {
 			imageURL = refMsg.Attachments[0].URL
 		}
-		if len(args) > 0 {
-			prompt := strings.Join(args, " ")
-			customPrompt = &prompt
-		}
+		setPrompt(args)
 	} else if len(args) > 0 {
 		// Check for image URL in args
 		possibleURL := args[0]
 		if strings.HasPrefix(possibleURL, "http://") || strings.HasPrefix(possibleURL, "https://") {
 			imageURL = possibleURL
-			if len(args) > 1 {
-				prompt := strings.Join(args[1:], " ")
-				customPrompt = &prompt
-			}
+			setPrompt(args[1:])
 		} else {
-			prompt := strings.Join(args, " ")
-			customPrompt = &prompt
+			setPrompt(args)
 		}
 	}
 
 	if imageURL == "" {
-		s.ChannelMessageSend(m.ChannelID, "Please attach an image, provide a valid image URL (starting with http/https), or reply to a message with an image.")
+		b.send(ctx, m.ChannelID, "Please attach an image, provide a valid image URL (starting with http/https), or reply to a message with an image.")
 		return
 	}
 
-	s.ChannelMessageSend(m.ChannelID, "Analyzing image, one sec...")
+	b.send(ctx, m.ChannelID, "Analyzing image, one sec...")
 
 	var opinion string
 	var err error
@@ -504,77 +785,200 @@ func (b *Bot) handleImageOpinion(ctx context.Context, s *discordgo.Session, m *d
 	if provider == "grok" {
 		opinion, err = b.aiClient.ImageOpinionGrok(ctx, imageURL, ai.OpenAIPersona, customPrompt)
 	} else {
-		b.sendThinkingMessage(ctx, s, m.ChannelID, provider, visionModel)
+		b.sendThinkingMessage(ctx, m.ChannelID, provider, visionModel)
 		opinion, err = b.aiClient.ImageOpinionOpenAI(ctx, imageURL, ai.OpenAIPersona, visionModel, ai.DefaultMaxTokens, customPrompt)
 	}
 
 	if err != nil {
 		b.logger.ErrorContext(ctx, "image analysis failed", "command", "image_opinion", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error analyzing image: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error analyzing image: %v", err))
 		return
 	}
 
-	b.sendLongResponse(ctx, m.ChannelID, opinion)
+	b.sendLongResponse(ctx, m.ChannelID, b.moderateResponse(ctx, m, "image_opinion", opinion))
 }
 
 // handleRoast handles the !roast command
-func (b *Bot) handleRoast(ctx context.Context, s *discordgo.Session, m *discordgo.MessageCreate, args []string) {
+func (b *Bot) handleRoast(ctx context.Context, req *commandRequest) {
+	m := req.m
 	var targetName string
 	var roastMessage string
 	var systemMessage string
 	var prompt string
 
+	requested, _ := req.value("intensity")
+	intensity, intensityNote := b.resolveRoastIntensity(m, requested)
+
+	_, linked := req.value("message")
+
 	// If user is mentioned
-	if len(m.Mentions) > 0 {
-		targetUser := m.Mentions[0]
-		member, err := s.GuildMember(m.GuildID, targetUser.ID)
-		targetName = targetUser.Username
-		if err == nil && member.Nick != "" {
-			targetName = member.Nick
+	if targetUser, ok := b.userArg(req, "user"); ok {
+		if !b.canTarget(m.GuildID, targetUser.ID, settings.OptOutRoast) {
+			b.send(ctx, m.ChannelID, fmt.Sprintf("%s has opted out of roasts.", targetUser.Username))
+			return
 		}
 
-		systemMessage = fmt.Sprintf("%s\nRoast %s as if you were a boston comedian who grew up in the bronx. "+
-			"Dont be afraid to attack sexuality, its a joke and everyone gets it. Be really really mean.", ai.OpenAIPersona, targetName)
-		prompt = fmt.Sprintf("Roast %s.", targetName)
-	} else if m.MessageReference != nil {
-		// If command is a reply to a message
-		refMsg, err := s.ChannelMessage(m.ChannelID, m.MessageReference.MessageID)
-		if err != nil {
-			b.logger.ErrorContext(ctx, "failed to fetch referenced message", "error", err)
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Could not fetch replied message: %v", err))
+		targetName = b.displayName(m.GuildID, targetUser)
+
+		systemMessage = buildSystemMessage(ai.OpenAIPersona,
+			"Roast the member named in the target block. "+intensity.instructions())
+		prompt = buildDataPrompt("Roast them.", untrustedBlock("target", targetName))
+	} else if linked || m.MessageReference != nil {
+		// If command links to or replies to a message
+		refMsg, ok := b.targetMessage(ctx, req)
+		if !ok {
 			return
 		}
 
-		member, err := s.GuildMember(m.GuildID, refMsg.Author.ID)
-		targetName = refMsg.Author.Username
-		if err == nil && member.Nick != "" {
-			targetName = member.Nick
+		if !b.canTarget(m.GuildID, refMsg.Author.ID, settings.OptOutRoast) {
+			b.send(ctx, m.ChannelID, fmt.Sprintf("%s has opted out of roasts.", refMsg.Author.Username))
+			return
 		}
-		roastMessage = refMsg.Content
 
-		systemMessage = fmt.Sprintf("%s\nRoast %s based on this message: '%s'. "+
-			"Be a boston comedian from the bronx, don't be afraid to attack sexuality, it's a joke and everyone gets it.",
-			ai.OpenAIPersona, targetName, roastMessage)
-		prompt = fmt.Sprintf("Roast %s for saying: %s", targetName, roastMessage)
+		targetName = b.displayName(m.GuildID, refMsg.Author)
+		roastMessage = b.redactPII(ctx, m.GuildID, "roast_message", []string{refMsg.Content})[0]
+
+		systemMessage = buildSystemMessage(ai.OpenAIPersona,
+			"Roast the member named in the target block based on the message they sent. "+intensity.instructions())
+		prompt = buildDataPrompt("Roast them for saying that.",
+			untrustedBlock("target", targetName),
+			untrustedBlock("their message", roastMessage))
 	} else {
-		s.ChannelMessageSend(m.ChannelID, "Please mention a user or reply to a message to roast.")
+		b.send(ctx, m.ChannelID, "Please mention a user, link a message or reply to one to roast.")
 		return
 	}
 
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Cooking up a roast for %s...", targetName))
+	if intensityNote != "" {
+		b.send(ctx, m.ChannelID, intensityNote)
+	}
+
+	b.logger.InfoContext(ctx, "roast intensity resolved",
+		"guild_id", m.GuildID,
+		"channel_id", m.ChannelID,
+		"intensity", intensity.String())
+
+	b.send(ctx, m.ChannelID, fmt.Sprintf("Cooking up a %s roast for %s...", intensity, targetName))
 
 	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, ai.DefaultOpenAIModel, "openai", ai.DefaultMaxTokens)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "AI request failed", "command", "roast", "error", err)
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Error: %v", err))
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
 		return
 	}
 
-	b.sendLongResponse(ctx, m.ChannelID, response)
+	b.sendLongResponse(ctx, m.ChannelID, b.moderateResponse(ctx, m, "roast", response))
+}
+
+// targetMessage fetches the message a command links to, or else the one it replies
+// to. Linked messages must be in a channel the invoker can read.
+func (b *Bot) targetMessage(ctx context.Context, req *commandRequest) (*discordgo.Message, bool) {
+	m := req.m
+	if _, linked := req.value("message"); linked {
+		target, ok := b.resolveHistoryTarget(ctx, req)
+		if !ok {
+			return nil, false
+		}
+		msg, err := b.session.ChannelMessage(target.channelID, target.aroundID)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to fetch linked message", "error", err)
+			b.send(ctx, m.ChannelID, fmt.Sprintf("Could not fetch the linked message: %v", err))
+			return nil, false
+		}
+		return msg, true
+	}
+
+	msg, err := b.session.ChannelMessage(m.ChannelID, m.MessageReference.MessageID)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to fetch referenced message", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Could not fetch replied message: %v", err))
+		return nil, false
+	}
+	return msg, true
+}
+
+// handleTLDR handles the !tldr command
+func (b *Bot) handleTLDR(ctx context.Context, req *commandRequest) {
+	m := req.m
+	provider := req.provider
+	model := ai.DefaultGrokModel
+	persona := ai.GrokPersona
+	if provider == ai.ProviderOpenAI {
+		model = ai.DefaultOpenAIModel
+		persona = ai.OpenAIPersona
+	}
+	model = req.modelOr(model)
+
+	// Prefer a link in the command itself, then fall back to the replied-to message
+	link := extractFirstURL(req.text("url"))
+	if link == "" && m.MessageReference != nil {
+		refMsg, err := b.session.ChannelMessage(m.ChannelID, m.MessageReference.MessageID)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to fetch referenced message", "error", err)
+			b.send(ctx, m.ChannelID, fmt.Sprintf("Could not fetch replied message: %v", err))
+			return
+		}
+		link = extractFirstURL(refMsg.Content)
+	}
+
+	if link == "" {
+		b.sendUsage(ctx, req, "(or reply to a message containing a link)")
+		return
+	}
+
+	b.send(ctx, m.ChannelID, "Reading that so you don't have to...")
+
+	page, err := b.fetcher.Fetch(ctx, link)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to fetch link", "command", "tldr", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error fetching link: %v", err))
+		return
+	}
+
+	if !isHTMLContentType(page.ContentType) {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("That link isn't a web page (%s), can't summarize it.", page.ContentType))
+		return
+	}
+
+	article, err := webfetch.ExtractArticle(page.Body)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to parse page", "command", "tldr", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error reading page: %v", err))
+		return
+	}
+
+	if article.Text == "" {
+		b.send(ctx, m.ChannelID, "Couldn't find any readable text on that page.")
+		return
+	}
+
+	text, truncated := ai.TruncateToTokens(article.Text, TLDRMaxArticleTokens)
+	b.logger.InfoContext(ctx, "extracted article",
+		"command", "tldr",
+		"text_length", len(article.Text),
+		"truncated", truncated)
+
+	task := "Someone in the server dropped a link. Summarize the article in a few " +
+		"short bullet points, then give your own hot take on it in a sentence or two."
+	if truncated {
+		task += " The article was cut off for length, so only summarize what you were given."
+	}
+	systemMessage := buildSystemMessage(persona, task)
+
+	prompt := buildDataPrompt("Summarize this article and give your take.",
+		untrustedBlock("web page", fmt.Sprintf("Title: %s\nURL: %s\n\n%s", article.Title, page.URL, text)))
+
+	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, model, provider, ai.DefaultMaxTokens)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "AI request failed", "command", "tldr", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+
+	b.sendLongResponse(ctx, m.ChannelID, b.moderateResponse(ctx, m, "tldr", response))
 }
 
 // sendThinkingMessage sends a "thinking" message to indicate processing
-func (b *Bot) sendThinkingMessage(ctx context.Context, s *discordgo.Session, channelID, provider, model string) {
+func (b *Bot) sendThinkingMessage(ctx context.Context, channelID, provider, model string) {
 	providerName := providerDisplayName(provider)
 	version := getModelVersion(provider, model)
 	modelLabel := model
@@ -586,7 +990,7 @@ func (b *Bot) sendThinkingMessage(ctx context.Context, s *discordgo.Session, cha
 		"model", model,
 		"version", version)
 
-	s.ChannelMessageSend(channelID, message)
+	b.send(ctx, channelID, message)
 }
 
 // getModelVersion returns the version string for a provider and model
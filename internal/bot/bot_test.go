@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// newShutdownTestBot returns a bot with lifetime and background contexts, as NewBot sets up
+func newShutdownTestBot(t *testing.T) (*Bot, *mockDiscordSession) {
+	t.Helper()
+	background, stopBackground := context.WithCancel(context.Background())
+	lifetime, stopHandlers := context.WithCancel(context.Background())
+	t.Cleanup(stopBackground)
+	t.Cleanup(stopHandlers)
+	session := &mockDiscordSession{}
+	return &Bot{
+		session:        session,
+		aiClient:       &mockAIClient{},
+		commands:       mustCommandRegistry(t),
+		cooldowns:      newCooldownTracker(),
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		background:     background,
+		stopBackground: stopBackground,
+		lifetime:       lifetime,
+		stopHandlers:   stopHandlers,
+	}, session
+}
+
+func TestCloseDrainsHandlers(t *testing.T) {
+	tests := []struct {
+		name string
+		// work is how long the in-flight handler takes unless its context is cancelled
+		work        time.Duration
+		timeout     time.Duration
+		wantAborted bool
+	}{
+		{name: "finishes within the timeout", work: 50 * time.Millisecond, timeout: ShutdownCancelGrace + 5*time.Second},
+		{name: "cancelled at the timeout", work: time.Minute, timeout: 50 * time.Millisecond, wantAborted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, _ := newShutdownTestBot(t)
+			if !bot.beginHandler() {
+				t.Fatal("beginHandler() = false before Close")
+			}
+
+			aborted := make(chan bool, 1)
+			go func() {
+				defer bot.handlers.Done()
+				// Jobs run under the background context, so it stays live while handlers drain
+				select {
+				case <-time.After(tt.work):
+					aborted <- false
+				case <-bot.handlerContext().Done():
+					aborted <- true
+				case <-bot.background.Done():
+					aborted <- true
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := bot.Close(ctx); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			select {
+			case got := <-aborted:
+				if got != tt.wantAborted {
+					t.Errorf("handler aborted = %v, want %v", got, tt.wantAborted)
+				}
+			default:
+				t.Fatal("Close() returned before the handler finished")
+			}
+			if bot.background.Err() == nil {
+				t.Error("background context still live after Close()")
+			}
+			if bot.beginHandler() {
+				t.Error("beginHandler() = true after Close()")
+			}
+		})
+	}
+}
+
+func TestCloseKeepsToTheDeadline(t *testing.T) {
+	bot, _ := newShutdownTestBot(t)
+	if !bot.beginHandler() {
+		t.Fatal("beginHandler() = false before Close")
+	}
+	// A handler that ignores cancellation can't hold up Close past its deadline
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		defer bot.handlers.Done()
+		<-release
+	}()
+
+	timeout := 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	if err := bot.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > timeout+100*time.Millisecond {
+		t.Errorf("Close() took %v, want it done within the %v deadline", elapsed, timeout)
+	}
+}
+
+func TestSlashCommandsRejectedWhileClosing(t *testing.T) {
+	bot, session := newShutdownTestBot(t)
+	if err := bot.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: "channel",
+		User:      &discordgo.User{ID: "alice", Username: "alice"},
+		Data:      discordgo.ApplicationCommandInteractionData{Name: "ping"},
+	}}
+	bot.interactionHandler(nil, i)
+
+	if len(session.responses) != 1 {
+		t.Fatalf("got %d interaction responses, want 1", len(session.responses))
+	}
+	resp := session.responses[0]
+	if resp.Type != discordgo.InteractionResponseChannelMessageWithSource || resp.Data == nil ||
+		resp.Data.Content != "I'm restarting. Try again in a minute." || resp.Data.Flags != discordgo.MessageFlagsEphemeral {
+		t.Errorf("response = %+v, want an ephemeral restart notice", resp)
+	}
+	if len(session.sentMessages)+len(session.followups) != 0 {
+		t.Errorf("command ran while closing: sent %q, followups %q", session.sentMessages, session.followups)
+	}
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+)
+
+// handleCatchup handles the !catchup command. The summary is private: slash commands
+// answer ephemerally and prefix commands answer by DM, so catching up doesn't add to
+// the backlog everyone else has to scroll past.
+func (b *Bot) handleCatchup(ctx context.Context, req *commandRequest) {
+	m := req.m
+	provider := req.provider
+	model := ai.DefaultGrokModel
+	persona := ai.GrokPersona
+	if provider == ai.ProviderOpenAI {
+		model = ai.DefaultOpenAIModel
+		persona = ai.OpenAIPersona
+	}
+	model = req.modelOr(model)
+
+	replyChannelID := m.ChannelID
+	if interactionFromContext(ctx) == nil {
+		dm, err := b.session.UserChannelCreate(m.Author.ID)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to open DM channel", "user_id", m.Author.ID, "error", err)
+			b.send(ctx, m.ChannelID, "I couldn't DM you. Allow direct messages from server members, or use /catchup instead.")
+			return
+		}
+		replyChannelID = dm.ID
+	}
+
+	history, err := b.fetchHistory(ctx, historyQuery{channelID: m.ChannelID, limit: CatchupMaxMessages})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to fetch channel history", "error", err)
+		b.send(ctx, replyChannelID, fmt.Sprintf("Error fetching messages: %v", err))
+		return
+	}
+
+	missed, found := missedMessages(history, m)
+	if len(missed) == 0 {
+		b.send(ctx, replyChannelID, fmt.Sprintf("Nothing new in <#%s> since your last message.", m.ChannelID))
+		return
+	}
+
+	name := b.displayName(m.GuildID, m.Author)
+	scope := fmt.Sprintf("every message sent in a channel since the member named in the target block last spoke there (%d messages)", len(missed))
+	if !found {
+		scope = fmt.Sprintf("the last %d messages in a channel; the member named in the target block hasn't spoken in any of them", len(missed))
+	}
+	systemMessage := buildSystemMessage(persona, fmt.Sprintf("The user turn contains %s. "+
+		"Catch that member up on what they missed, addressing them as \"you\". Lead with anything that mentions them, "+
+		"replies to them or asks them something, then decisions made and plans agreed, then a short summary of the rest. "+
+		"Keep it brief and skimmable and leave out sections with nothing in them.", scope))
+
+	condensed, err := b.condenseHistory(ctx, replyChannelID, provider, model, b.formatHistory(ctx, m.GuildID, missed),
+		"every mention of or question to someone, decisions made and plans agreed")
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to summarize channel history", "command", "catchup", "error", err)
+		b.send(ctx, replyChannelID, fmt.Sprintf("Error summarizing messages: %v", err))
+		return
+	}
+	systemMessage += condensed.note()
+
+	blocks := []string{
+		untrustedBlock("target", name),
+		untrustedBlock("channel history", condensed.text),
+	}
+	if mentions := b.mentioning(ctx, history, missed, m.Author.ID); len(mentions) > 0 {
+		blocks = append(blocks, untrustedBlock("messages that mention or reply to them",
+			strings.Join(b.formatHistory(ctx, m.GuildID, mentions), "\n")))
+	}
+	prompt := buildDataPrompt("Catch me up on what I missed.", blocks...)
+
+	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, model, provider, ai.DefaultMaxTokens)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "AI request failed", "command", "catchup", "error", err)
+		b.send(ctx, replyChannelID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+
+	b.send(ctx, replyChannelID, fmt.Sprintf("**Catching up on <#%s>** (%d messages)", m.ChannelID, len(missed)))
+	b.sendLongResponse(ctx, replyChannelID, b.moderateResponse(ctx, m, "catchup", response))
+	if replyChannelID != m.ChannelID {
+		b.send(ctx, m.ChannelID, "Sent you a catch-up in DMs.")
+	}
+}
+
+// missedMessages returns the messages sent after the invoker's last message, newest
+// first, and whether that message was found. The command message itself doesn't count.
+// If the invoker hasn't spoken in the history read, all of it is returned.
+func missedMessages(history []*discordgo.Message, m *discordgo.MessageCreate) ([]*discordgo.Message, bool) {
+	var missed []*discordgo.Message
+	for _, msg := range history {
+		if msg.ID == m.ID {
+			continue
+		}
+		if msg.Author != nil && msg.Author.ID == m.Author.ID {
+			return missed, true
+		}
+		missed = append(missed, msg)
+	}
+	return missed, false
+}
+
+// mentioning returns the messages that mention a user or reply to one of their messages.
+// Archived replies only keep the reference, so their targets are looked up in the history
+// read, then in the archive.
+func (b *Bot) mentioning(ctx context.Context, history, messages []*discordgo.Message, userID string) []*discordgo.Message {
+	byID := make(map[string]*discordgo.Message, len(history))
+	for _, msg := range history {
+		byID[msg.ID] = msg
+	}
+
+	var matches []*discordgo.Message
+	for _, msg := range messages {
+		target := b.repliedTo(ctx, msg, byID)
+		replied := target != nil && target.Author != nil && target.Author.ID == userID
+		mentioned := false
+		for _, user := range msg.Mentions {
+			mentioned = mentioned || user.ID == userID
+		}
+		if replied || mentioned {
+			matches = append(matches, msg)
+		}
+	}
+	return matches
+}
+
+// repliedTo returns the message msg replies to without asking Discord: the one sent
+// with it, one of byID, or an archived one. It returns nil for other messages.
+func (b *Bot) repliedTo(ctx context.Context, msg *discordgo.Message, byID map[string]*discordgo.Message) *discordgo.Message {
+	if msg.ReferencedMessage != nil {
+		return msg.ReferencedMessage
+	}
+	ref := msg.MessageReference
+	if ref == nil || ref.MessageID == "" || msg.Type != discordgo.MessageTypeReply {
+		return nil
+	}
+	if target, ok := byID[ref.MessageID]; ok {
+		return target
+	}
+	if b.archive == nil {
+		return nil
+	}
+
+	channelID := ref.ChannelID
+	if channelID == "" {
+		channelID = msg.ChannelID
+	}
+	target, err := b.archive.Message(channelID, ref.MessageID)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to read message archive", "channel_id", channelID, "error", err)
+		return nil
+	}
+	return target
+}
@@ -0,0 +1,189 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+)
+
+// catchupHistory is a channel where alice spoke before three newer messages, one of
+// them mentioning her
+func catchupHistory() []*discordgo.Message {
+	alice := &discordgo.User{ID: "alice", Username: "alice"}
+	bob := &discordgo.User{ID: "bob", Username: "bob"}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []*discordgo.Message{
+		{ID: "5", Author: bob, Content: "@alice are you coming friday?", Mentions: []*discordgo.User{alice}, Timestamp: at.Add(4 * time.Minute)},
+		{ID: "4", Author: bob, Content: "we settled on the cabin", Timestamp: at.Add(3 * time.Minute)},
+		{ID: "3", Author: bob, Content: "cabin or beach?", Timestamp: at.Add(2 * time.Minute)},
+		{ID: "2", Author: alice, Content: "brb", Timestamp: at.Add(time.Minute)},
+		{ID: "1", Author: bob, Content: "old news", Timestamp: at},
+	}
+}
+
+func TestCatchup(t *testing.T) {
+	tests := []struct {
+		name         string
+		history      []*discordgo.Message
+		dmClosed     bool
+		wantPrompts  int
+		wantChannels []string
+		wantReply    string
+		wantPrompt   []string
+		notInPrompt  []string
+	}{
+		{
+			name:         "sent by DM",
+			history:      catchupHistory(),
+			wantPrompts:  1,
+			wantChannels: []string{"dm-alice", "dm-alice", "channel"},
+			wantReply:    "Sent you a catch-up in DMs.",
+			wantPrompt:   []string{"cabin or beach?", "we settled on the cabin", "messages that mention or reply to them"},
+			notInPrompt:  []string{"old news", "brb"},
+		},
+		{
+			name:         "nothing missed",
+			history:      catchupHistory()[3:],
+			wantChannels: []string{"dm-alice"},
+			wantReply:    "Nothing new in <#channel> since your last message.",
+		},
+		{
+			name:         "DMs closed",
+			history:      catchupHistory(),
+			dmClosed:     true,
+			wantChannels: []string{"channel"},
+			wantReply:    "I couldn't DM you. Allow direct messages from server members, or use /catchup instead.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockDiscordSession{history: tt.history, dmClosed: tt.dmClosed}
+			mockAI := &mockAIClient{}
+			bot := &Bot{
+				session:   session,
+				aiClient:  mockAI,
+				commands:  mustCommandRegistry(t),
+				cooldowns: newCooldownTracker(),
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			m := &discordgo.MessageCreate{Message: &discordgo.Message{
+				ID:        "6",
+				GuildID:   "guild",
+				ChannelID: "channel",
+				Author:    &discordgo.User{ID: "alice", Username: "alice"},
+			}}
+
+			bot.dispatch(context.Background(), "catchup", m, nil)
+
+			if len(mockAI.prompts) != tt.wantPrompts {
+				t.Fatalf("AskClient called %d times, want %d", len(mockAI.prompts), tt.wantPrompts)
+			}
+			if !slices.Equal(session.sentChannels, tt.wantChannels) {
+				t.Errorf("sent to %q, want %q", session.sentChannels, tt.wantChannels)
+			}
+			if last := session.sentMessages[len(session.sentMessages)-1]; last != tt.wantReply {
+				t.Errorf("last reply = %q, want %q", last, tt.wantReply)
+			}
+			if tt.wantPrompts == 0 {
+				return
+			}
+			prompt := mockAI.prompts[0]
+			for _, want := range tt.wantPrompt {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt is missing %q", want)
+				}
+			}
+			for _, unwanted := range tt.notInPrompt {
+				if strings.Contains(prompt, unwanted) {
+					t.Errorf("prompt contains %q from before the last message", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestCatchupFindsArchivedReplies(t *testing.T) {
+	bot, session := newArchiveTestBot(t, &config.Config{})
+	mockAI := &mockAIClient{}
+	bot.aiClient, bot.commands, bot.cooldowns = mockAI, mustCommandRegistry(t), newCooldownTracker()
+
+	alice := &discordgo.User{ID: "alice", Username: "alice"}
+	bob := &discordgo.User{ID: "bob", Username: "bob"}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// Archived messages keep only the reference to the message they reply to
+	reply := func(id, targetID, content string) *discordgo.Message {
+		return &discordgo.Message{
+			ID: id, ChannelID: "channel", Author: bob, Content: content, Type: discordgo.MessageTypeReply,
+			MessageReference: &discordgo.MessageReference{MessageID: targetID, ChannelID: "channel"},
+		}
+	}
+	older := &discordgo.Message{ID: "10", GuildID: "guild", ChannelID: "channel", Author: alice, Content: "who's driving?", Timestamp: at}
+	if err := bot.archive.Put(older); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	session.history = []*discordgo.Message{
+		reply("50", "10", "I can drive"),
+		reply("40", "20", "welcome back"),
+		reply("30", "99", "agreed"),
+		{ID: "20", ChannelID: "channel", Author: alice, Content: "back", Timestamp: at.Add(time.Minute)},
+	}
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{ID: "60", GuildID: "guild", ChannelID: "channel", Author: alice}}
+
+	bot.dispatch(context.Background(), "catchup", m, nil)
+
+	if len(mockAI.prompts) != 1 {
+		t.Fatalf("AskClient called %d times, want 1", len(mockAI.prompts))
+	}
+	_, mentions, found := strings.Cut(mockAI.prompts[0], "messages that mention or reply to them")
+	if !found {
+		t.Fatal("prompt has no block of replies to alice")
+	}
+	for _, want := range []string{"I can drive", "welcome back"} {
+		if !strings.Contains(mentions, want) {
+			t.Errorf("replies to alice are missing %q:\n%s", want, mentions)
+		}
+	}
+	if strings.Contains(mentions, "agreed") {
+		t.Errorf("replies to alice include a reply to an unknown message:\n%s", mentions)
+	}
+}
+
+func TestCatchupSlashIsEphemeral(t *testing.T) {
+	session := &mockDiscordSession{history: catchupHistory()}
+	bot := &Bot{
+		session:   session,
+		aiClient:  &mockAIClient{},
+		commands:  mustCommandRegistry(t),
+		cooldowns: newCooldownTracker(),
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        "6",
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   "guild",
+		ChannelID: "channel",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "alice", Username: "alice"}},
+		Data:      discordgo.ApplicationCommandInteractionData{Name: "catchup"},
+	}}
+
+	bot.interactionHandler(nil, i)
+
+	if len(session.responses) != 1 {
+		t.Fatalf("got %d interaction responses, want 1", len(session.responses))
+	}
+	if data := session.responses[0].Data; data == nil || data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Error("catchup response was not deferred as ephemeral")
+	}
+	if slices.Contains(session.sentChannels, "dm-alice") {
+		t.Error("slash catchup was sent by DM instead of in the ephemeral response")
+	}
+}
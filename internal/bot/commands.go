@@ -0,0 +1,628 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+)
+
+// argKind describes how a command argument is typed in slash commands and usage text
+type argKind int
+
+// Argument kinds
+const (
+	argString argKind = iota
+	argInteger
+	argUser
+	argRole
+	argChannel
+	argDuration
+	argAttachment
+	// argMessage is a message jump link, typed as a string in slash commands
+	argMessage
+	// argTime is a time of day or date, typed as a string in slash commands
+	argTime
+)
+
+// commandArg declares one positional argument of a command
+type commandArg struct {
+	name        string
+	description string
+	kind        argKind
+	required    bool
+	choices     []string
+	// flagAliases are extra names accepted as --flags, e.g. "n" for messages
+	flagAliases []string
+}
+
+// commandHandler runs a command. Method expressions such as (*Bot).handleAsk satisfy it.
+type commandHandler func(b *Bot, ctx context.Context, req *commandRequest)
+
+// command is the single definition of a bot command. Routing, usage text, help
+// and slash command registration are all generated from it.
+type command struct {
+	name    string
+	aliases []string
+	// category groups the command in the !help overview
+	category    string
+	description string
+	args        []commandArg
+	// defaultProvider is the AI provider used when none is given; empty means the
+	// command doesn't accept a provider override
+	defaultProvider string
+	// cooldown is the per-user delay between invocations; zero disables it
+	cooldown time.Duration
+	// permission is required to run the command at all; zero means anyone can
+	permission int64
+	guildOnly  bool
+	// ephemeral makes slash command responses visible only to the invoker
+	ephemeral bool
+	// background runs the command as a job that can be listed and cancelled, for
+	// commands that may read and summarize a lot of history
+	background bool
+	examples   []string
+	handler    commandHandler
+}
+
+// Command categories, in the order the !help overview lists them
+const (
+	categoryGeneral  = "General"
+	categoryMemory   = "Memory"
+	categoryAnalysis = "Analysis"
+	categorySearch   = "Search"
+	categorySettings = "Server settings"
+)
+
+// commandRequest is a parsed invocation passed to a command handler
+type commandRequest struct {
+	cmd *command
+	m   *discordgo.MessageCreate
+	// args are the words that didn't bind to a typed argument
+	args     []string
+	values   map[string]string
+	provider string
+	model    string
+}
+
+// usage renders the command's argument syntax, e.g. "!ask [grok|openai] <question>"
+func (c *command) usage() string {
+	parts := []string{"!" + c.name}
+	if c.defaultProvider != "" {
+		parts = append(parts, "["+ai.ProviderGrok+"|"+ai.ProviderOpenAI+"]")
+	}
+	for _, arg := range c.args {
+		label := arg.name
+		if len(arg.choices) > 0 {
+			label = strings.Join(arg.choices, "|")
+		}
+		if arg.required {
+			parts = append(parts, "<"+label+">")
+		} else {
+			parts = append(parts, "["+label+"]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// applicationCommand builds the slash command definition for the command
+func (c *command) applicationCommand() *discordgo.ApplicationCommand {
+	appCmd := &discordgo.ApplicationCommand{
+		Name:        c.name,
+		Description: c.description,
+	}
+
+	for _, arg := range c.args {
+		opt := &discordgo.ApplicationCommandOption{
+			Name:        arg.name,
+			Description: arg.description,
+			Required:    arg.required,
+			Choices:     choices(arg.choices...),
+		}
+		switch arg.kind {
+		case argInteger:
+			opt.Type = discordgo.ApplicationCommandOptionInteger
+			minValue := 1.0
+			opt.MinValue = &minValue
+		case argUser:
+			opt.Type = discordgo.ApplicationCommandOptionUser
+		case argRole:
+			opt.Type = discordgo.ApplicationCommandOptionRole
+		case argChannel:
+			opt.Type = discordgo.ApplicationCommandOptionChannel
+		case argAttachment:
+			opt.Type = discordgo.ApplicationCommandOptionAttachment
+		default:
+			opt.Type = discordgo.ApplicationCommandOptionString
+		}
+		appCmd.Options = append(appCmd.Options, opt)
+	}
+
+	if c.defaultProvider != "" {
+		appCmd.Options = append(appCmd.Options, providerOption)
+	}
+
+	if c.permission != 0 {
+		permission := c.permission
+		appCmd.DefaultMemberPermissions = &permission
+	}
+	if c.guildOnly {
+		dmPermission := false
+		appCmd.DMPermission = &dmPermission
+	}
+
+	return appCmd
+}
+
+// commandRegistry indexes commands by name and alias
+type commandRegistry struct {
+	commands []*command
+	byName   map[string]*command
+}
+
+// newCommandRegistry builds a registry, rejecting duplicate names or aliases
+func newCommandRegistry(commands []*command) (*commandRegistry, error) {
+	r := &commandRegistry{byName: make(map[string]*command)}
+	for _, cmd := range commands {
+		for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+			if _, exists := r.byName[name]; exists {
+				return nil, fmt.Errorf("duplicate command name or alias %q", name)
+			}
+			r.byName[name] = cmd
+		}
+		r.commands = append(r.commands, cmd)
+	}
+	return r, nil
+}
+
+// lookup finds a command by name or alias, ignoring case
+func (r *commandRegistry) lookup(name string) (*command, bool) {
+	cmd, ok := r.byName[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// applicationCommands returns the slash command definitions for every command
+func (r *commandRegistry) applicationCommands() []*discordgo.ApplicationCommand {
+	defs := make([]*discordgo.ApplicationCommand, len(r.commands))
+	for i, cmd := range r.commands {
+		defs[i] = cmd.applicationCommand()
+	}
+	return defs
+}
+
+// cooldownTracker remembers when each user last ran each command
+type cooldownTracker struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// newCooldownTracker creates an empty tracker
+func newCooldownTracker() *cooldownTracker {
+	return &cooldownTracker{last: make(map[string]time.Time)}
+}
+
+// allow records an invocation and reports whether it is permitted. When it isn't,
+// the remaining wait is returned and the earlier invocation time is kept.
+func (t *cooldownTracker) allow(cmd *command, userID string, now time.Time) (bool, time.Duration) {
+	if cmd.cooldown <= 0 {
+		return true, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := cmd.name + ":" + userID
+	if last, ok := t.last[key]; ok {
+		if remaining := cmd.cooldown - now.Sub(last); remaining > 0 {
+			return false, remaining
+		}
+	}
+	t.last[key] = now
+	return true, 0
+}
+
+// permissionNames gives the user-facing names of permissions commands can require
+var permissionNames = map[int64]string{
+	discordgo.PermissionManageGuild:    "Manage Server",
+	discordgo.PermissionManageMessages: "Manage Messages",
+}
+
+// defaultCommands returns the bot's command definitions in the order they are listed to users
+func defaultCommands() []*command {
+	historyCount := func(defaultCount int) commandArg {
+		return commandArg{
+			name:        "messages",
+			description: fmt.Sprintf("How many recent messages to read (default %d, up to %d)", defaultCount, MaxHistoryMessages),
+			kind:        argInteger,
+			flagAliases: []string{"n"},
+		}
+	}
+	channelArg := commandArg{name: "channel", description: "Channel to read instead of this one", kind: argChannel}
+	messageArg := commandArg{name: "message", description: "Link to a message to read around", kind: argMessage}
+	timeWindow := []commandArg{
+		{name: "since", description: "Read messages from this far back, e.g. 2h", kind: argDuration},
+		{name: "from", description: "Read messages sent after this time, e.g. 18:00 or 2024-05-01", kind: argTime},
+		{name: "to", description: "Read messages sent before this time", kind: argTime},
+	}
+
+	return []*command{
+		{
+			name:        "help",
+			category:    categoryGeneral,
+			aliases:     []string{"commands"},
+			description: "List commands or explain one",
+			args:        []commandArg{{name: "command", description: "Command to explain"}},
+			examples:    []string{"!help", "!help roast"},
+			handler:     (*Bot).handleHelp,
+		},
+		{
+			name:        "ping",
+			category:    categoryGeneral,
+			description: "Check if the bot is online",
+			examples:    []string{"!ping"},
+			handler:     (*Bot).handlePing,
+		},
+		{
+			name:            "ask",
+			category:        categoryGeneral,
+			description:     "Ask Coonbot anything",
+			args:            []commandArg{{name: "question", description: "Your question", required: true}},
+			defaultProvider: ai.DefaultProvider,
+			cooldown:        5 * time.Second,
+			examples:        []string{"!ask What do you think about Boston politics?", "!ask openai Who would win, Batman or Superman?"},
+			handler:         (*Bot).handleAsk,
+		},
+		{
+			name:        "reset",
+			category:    categoryGeneral,
+			description: "Make Coonbot forget your recent !ask conversation in this channel",
+			args: []commandArg{
+				{name: "scope", description: "me, or channel to clear everyone's (needs Manage Messages)", choices: []string{"me", "channel"}},
+			},
+			examples: []string{"!reset", "!reset channel"},
+			handler:  (*Bot).handleReset,
+		},
+		{
+			name:        "remember",
+			category:    categoryMemory,
+			description: "Teach Coonbot a fact about a member, yourself or the server",
+			args: []commandArg{
+				{name: "fact", description: "The fact, e.g. @Dave is a Jets fan", required: true},
+			},
+			cooldown:  5 * time.Second,
+			guildOnly: true,
+			examples:  []string{"!remember @Dave is a Jets fan", "!remember I'm allergic to cilantro", "!remember The server was founded in 2019"},
+			handler:   (*Bot).handleRemember,
+		},
+		{
+			name:        "forget",
+			category:    categoryMemory,
+			description: "Make Coonbot forget a fact you added or that is about you",
+			args: []commandArg{
+				{name: "id", description: "Fact ID from !facts", kind: argInteger, required: true},
+			},
+			guildOnly: true,
+			examples:  []string{"!forget 12"},
+			handler:   (*Bot).handleForget,
+		},
+		{
+			name:        "facts",
+			category:    categoryMemory,
+			description: "List what Coonbot remembers, optionally about one member",
+			args: []commandArg{
+				{name: "user", description: "Only facts about this member", kind: argUser},
+			},
+			guildOnly: true,
+			examples:  []string{"!facts", "!facts @Dave"},
+			handler:   (*Bot).handleFacts,
+		},
+		{
+			name:        "review",
+			category:    categoryMemory,
+			description: "Review facts waiting for approval",
+			args: []commandArg{
+				{name: "action", description: "What to do with the fact", choices: []string{"approve", "reject"}},
+				{name: "id", description: "Fact ID", kind: argInteger},
+			},
+			permission: discordgo.PermissionManageMessages,
+			guildOnly:  true,
+			examples:   []string{"!review", "!review approve 12", "!review reject 13"},
+			handler:    (*Bot).handleReview,
+		},
+		{
+			name:            "opinion",
+			category:        categoryAnalysis,
+			description:     "Get Coonbot's take on the recent conversation",
+			args:            append([]commandArg{historyCount(DefaultHistoryMessageCount), channelArg, messageArg}, timeWindow...),
+			defaultProvider: ai.DefaultProvider,
+			cooldown:        15 * time.Second,
+			examples: []string{"!opinion", "!opinion 20", "!opinion openai 50", "!opinion #general 50",
+				"!opinion --since 2h", "!opinion --n=50 --model=gpt-4o"},
+			background: true,
+			handler:    (*Bot).handleOpinion,
+		},
+		{
+			name:            "who_won",
+			category:        categoryAnalysis,
+			aliases:         []string{"whowon"},
+			description:     "Decide who won the recent arguments",
+			args:            append([]commandArg{historyCount(DefaultWhoWonMessageCount), channelArg, messageArg}, timeWindow...),
+			defaultProvider: ai.DefaultProvider,
+			cooldown:        15 * time.Second,
+			examples: []string{"!who_won", "!who_won 50", "!who_won https://discord.com/channels/1/2/3",
+				"!who_won 2h", "!who_won --from 18:00 --to 20:00"},
+			background: true,
+			handler:    (*Bot).handleWhoWon,
+		},
+		{
+			name:        "user_opinion",
+			category:    categoryAnalysis,
+			aliases:     []string{"useropinion"},
+			description: "Get Coonbot's opinion of a member",
+			args: []commandArg{
+				{name: "user", description: "Member to analyze", kind: argUser, required: true},
+				{name: "days", description: fmt.Sprintf("How many days back to look (default %d)", DefaultUserOpinionDays),
+					kind: argInteger},
+				{name: "max_messages", description: fmt.Sprintf("Maximum messages to scan (default %d, up to %d)", DefaultUserOpinionMaxMessages, MaxHistoryMessages),
+					kind: argInteger, flagAliases: []string{"n", "max"}},
+				channelArg,
+			},
+			defaultProvider: ai.ProviderOpenAI,
+			cooldown:        15 * time.Second,
+			guildOnly:       true,
+			examples:        []string{"!user_opinion @Alice", "!user_opinion @Bob 5 100", "!user_opinion @Bob --days=7 --provider=grok"},
+			background:      true,
+			handler:         (*Bot).handleUserOpinion,
+		},
+		{
+			name:        "most",
+			category:    categoryAnalysis,
+			description: "Ask who is the most X in the chat",
+			args: append([]commandArg{
+				{name: "question", description: "e.g. helpful, or a full question", required: true},
+				channelArg,
+			}, timeWindow...),
+			defaultProvider: ai.ProviderOpenAI,
+			cooldown:        15 * time.Second,
+			examples: []string{"!most helpful", "!most Who is most likely to start an argument?", "!most #general funniest",
+				"!most funniest --since 1d"},
+			background: true,
+			handler:    (*Bot).handleMost,
+		},
+		{
+			name:        "image_opinion",
+			category:    categoryAnalysis,
+			aliases:     []string{"img"},
+			description: "Get Coonbot's opinion on an image (attach, link or reply to one)",
+			args: []commandArg{
+				{name: "image", description: "Image to judge", kind: argAttachment},
+				{name: "url", description: "Image URL, if not attaching"},
+				{name: "prompt", description: "Custom prompt"},
+			},
+			defaultProvider: ai.ProviderOpenAI,
+			cooldown:        10 * time.Second,
+			examples:        []string{"!image_opinion https://example.com/cat.jpg", "(attach an image) !image_opinion Give a funny take on this", "(reply to an image) !image_opinion grok"},
+			handler:         (*Bot).handleImageOpinion,
+		},
+		{
+			name:        "roast",
+			category:    categoryAnalysis,
+			description: "Roast a member (mention them, link their message or reply to it)",
+			args: []commandArg{
+				{name: "user", description: "Member to roast", kind: argUser},
+				{name: "message", description: "Link to a message to roast its author for", kind: argMessage},
+				{name: "intensity", description: "How harsh", choices: roastIntensityNames},
+			},
+			cooldown:  10 * time.Second,
+			guildOnly: true,
+			examples: []string{"!roast @Alice", "!roast @Alice mild", "!roast @Alice --intensity=savage",
+				"!roast https://discord.com/channels/1/2/3", "(reply to a message) !roast savage"},
+			handler: (*Bot).handleRoast,
+		},
+		{
+			name:            "recall",
+			category:        categorySearch,
+			description:     "Ask Coonbot about something said in this server, with links to the messages",
+			args:            []commandArg{{name: "question", description: "What to look for", required: true}},
+			defaultProvider: ai.DefaultProvider,
+			guildOnly:       true,
+			cooldown:        15 * time.Second,
+			examples:        []string{"!recall when did we decide on the Vegas trip?", "!recall openai who recommended that ramen place?"},
+			handler:         (*Bot).handleRecall,
+		},
+		{
+			name:        "search",
+			category:    categorySearch,
+			description: "Search archived messages by keyword, with from:, in:, before: and after: filters",
+			args: []commandArg{
+				{name: "query", description: "Words to find, e.g. vegas trip from:@Dave in:#general after:2024-05-01", required: true},
+			},
+			guildOnly: true,
+			cooldown:  3 * time.Second,
+			examples:  []string{"!search vegas trip", "!search hotel from:@Dave after:7d", "!search ramen in:#food before:2024-06-01"},
+			handler:   (*Bot).handleSearch,
+		},
+		{
+			name:            "catchup",
+			category:        categoryGeneral,
+			description:     "Summarize what you missed here since your last message, privately",
+			defaultProvider: ai.DefaultProvider,
+			guildOnly:       true,
+			ephemeral:       true,
+			cooldown:        15 * time.Second,
+			examples:        []string{"!catchup", "!catchup --provider openai"},
+			handler:         (*Bot).handleCatchup,
+		},
+		{
+			name:            "tldr",
+			category:        categoryGeneral,
+			aliases:         []string{"summarize"},
+			description:     "Summarize a linked article (or reply to a message with a link)",
+			args:            []commandArg{{name: "url", description: "Link to summarize", required: true}},
+			defaultProvider: ai.DefaultProvider,
+			cooldown:        15 * time.Second,
+			examples:        []string{"!tldr https://example.com/news/story", "(reply to a message with a link) !tldr"},
+			handler:         (*Bot).handleTLDR,
+		},
+		{
+			name:        "jobs",
+			category:    categoryGeneral,
+			description: "List the long-running commands in progress on this server",
+			guildOnly:   true,
+			examples:    []string{"!jobs"},
+			handler:     (*Bot).handleJobs,
+		},
+		{
+			name:        "cancel",
+			category:    categoryGeneral,
+			description: "Stop a long-running command you started",
+			args: []commandArg{
+				{name: "id", description: "Job ID from !jobs", kind: argInteger, required: true},
+			},
+			guildOnly: true,
+			examples:  []string{"!cancel 7"},
+			handler:   (*Bot).handleCancel,
+		},
+		{
+			name:        "moderation",
+			category:    categorySettings,
+			description: "Show or set this server's output moderation level",
+			args:        []commandArg{{name: "level", description: "New level", choices: []string{"off", "relaxed", "standard", "strict"}}},
+			guildOnly:   true,
+			examples:    []string{"!moderation", "!moderation strict"},
+			handler:     (*Bot).handleModeration,
+		},
+		{
+			name:        "optout",
+			category:    categorySettings,
+			description: "Opt out of roasts or analysis",
+			args:        []commandArg{{name: "category", description: "What to opt out of", choices: []string{"roast", "analysis", "all"}}},
+			guildOnly:   true,
+			examples:    []string{"!optout roast", "!optout all"},
+			handler:     (*Bot).handleOptOut,
+		},
+		{
+			name:        "optin",
+			category:    categorySettings,
+			description: "Opt back in to roasts or analysis",
+			args:        []commandArg{{name: "category", description: "What to opt back in to", choices: []string{"roast", "analysis", "all"}}},
+			guildOnly:   true,
+			examples:    []string{"!optin analysis"},
+			handler:     (*Bot).handleOptIn,
+		},
+		{
+			name:        "redaction",
+			category:    categorySettings,
+			description: "Show or toggle PII redaction for chat history",
+			args:        []commandArg{{name: "state", description: "Turn redaction on or off", choices: []string{"on", "off"}}},
+			guildOnly:   true,
+			examples:    []string{"!redaction", "!redaction off"},
+			handler:     (*Bot).handleRedaction,
+		},
+		{
+			name:        "roast_cap",
+			category:    categorySettings,
+			description: "Show or set the maximum roast intensity",
+			args:        []commandArg{{name: "level", description: "Maximum intensity", choices: roastIntensityNames}},
+			guildOnly:   true,
+			examples:    []string{"!roast_cap", "!roast_cap mild"},
+			handler:     (*Bot).handleRoastCap,
+		},
+		{
+			name:        "protect_role",
+			category:    categorySettings,
+			description: "Make a role off-limits for roasts and analysis",
+			args:        []commandArg{{name: "role", description: "Role to protect", kind: argRole, required: true}},
+			permission:  discordgo.PermissionManageGuild,
+			guildOnly:   true,
+			examples:    []string{"!protect_role @Moderators"},
+			handler:     (*Bot).handleProtectRole,
+		},
+		{
+			name:        "unprotect_role",
+			category:    categorySettings,
+			description: "Remove a role's protection",
+			args:        []commandArg{{name: "role", description: "Role to unprotect", kind: argRole, required: true}},
+			permission:  discordgo.PermissionManageGuild,
+			guildOnly:   true,
+			examples:    []string{"!unprotect_role @Moderators"},
+			handler:     (*Bot).handleUnprotectRole,
+		},
+	}
+}
+
+// dispatch routes a command to its handler after applying the definition's guild,
+// permission and cooldown rules. Prefix and slash commands both end up here.
+func (b *Bot) dispatch(ctx context.Context, name string, m *discordgo.MessageCreate, args []string) {
+	cmd, ok := b.commands.lookup(name)
+	if !ok {
+		b.logger.InfoContext(ctx, "unknown command", "command", name)
+		// Only answer likely typos, so other bots' ! commands don't get a reply
+		if suggestions := b.commands.suggest(name); len(suggestions) > 0 {
+			b.send(ctx, m.ChannelID, unknownCommandMessage(name, suggestions))
+		}
+		return
+	}
+
+	if cmd.guildOnly && m.GuildID == "" {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("!%s only works in servers.", cmd.name))
+		return
+	}
+
+	if cmd.permission != 0 && !b.hasPermission(m, cmd.permission) {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("You need the %s permission to use !%s.", permissionName(cmd.permission), cmd.name))
+		return
+	}
+
+	// Parse before the cooldown so a typo doesn't cost the user their turn
+	parsed, err := parseCommandArgs(cmd, args)
+	if err != nil {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("%s. Usage: %s (see `!help %s`)", capitalize(err.Error()), cmd.usage(), cmd.name))
+		return
+	}
+
+	if allowed, remaining := b.cooldowns.allow(cmd, m.Author.ID, time.Now()); !allowed {
+		b.logger.InfoContext(ctx, "command on cooldown",
+			"command", cmd.name,
+			"user_id", m.Author.ID,
+			"remaining", remaining)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Slow down! You can use !%s again in %s.", cmd.name, remaining.Round(time.Second)))
+		return
+	}
+
+	req := &commandRequest{
+		cmd:      cmd,
+		m:        m,
+		args:     parsed.rest,
+		values:   parsed.values,
+		provider: parsed.provider,
+		model:    parsed.model,
+	}
+	if cmd.background && b.jobs != nil {
+		b.startJob(ctx, req, args)
+		return
+	}
+	cmd.handler(b, ctx, req)
+}
+
+// sendUsage replies with the command's generated usage line
+func (b *Bot) sendUsage(ctx context.Context, req *commandRequest, hint string) {
+	usage := "Usage: " + req.cmd.usage()
+	if hint != "" {
+		usage += " " + hint
+	}
+	b.send(ctx, req.m.ChannelID, fmt.Sprintf("%s. See `!help %s` for examples.", usage, req.cmd.name))
+}
+
+// permissionName returns a readable name for a permission bit
+func permissionName(permission int64) string {
+	if name, ok := permissionNames[permission]; ok {
+		return name
+	}
+	return "required"
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func mustCommandRegistry(t *testing.T) *commandRegistry {
+	t.Helper()
+	registry, err := newCommandRegistry(defaultCommands())
+	if err != nil {
+		t.Fatalf("newCommandRegistry() error = %v", err)
+	}
+	return registry
+}
+
+func TestNewCommandRegistryRejectsDuplicates(t *testing.T) {
+	noop := func(b *Bot, ctx context.Context, req *commandRequest) {}
+
+	tests := []struct {
+		name     string
+		commands []*command
+		wantErr  bool
+	}{
+		{
+			name:     "distinct names",
+			commands: []*command{{name: "a", handler: noop}, {name: "b", aliases: []string{"bee"}, handler: noop}},
+		},
+		{
+			name:     "duplicate name",
+			commands: []*command{{name: "a", handler: noop}, {name: "a", handler: noop}},
+			wantErr:  true,
+		},
+		{
+			name:     "alias shadows a name",
+			commands: []*command{{name: "a", handler: noop}, {name: "b", aliases: []string{"a"}, handler: noop}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newCommandRegistry(tt.commands)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCommandRegistry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommandRegistryLookup(t *testing.T) {
+	registry := mustCommandRegistry(t)
+
+	tests := []struct {
+		name     string
+		wantName string
+		wantOK   bool
+	}{
+		{name: "who_won", wantName: "who_won", wantOK: true},
+		{name: "whowon", wantName: "who_won", wantOK: true},
+		{name: "ASK", wantName: "ask", wantOK: true},
+		{name: "nope", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, ok := registry.lookup(tt.name)
+			if ok != tt.wantOK {
+				t.Fatalf("lookup(%q) ok = %v, want %v", tt.name, ok, tt.wantOK)
+			}
+			if ok && cmd.name != tt.wantName {
+				t.Errorf("lookup(%q) = %s, want %s", tt.name, cmd.name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	registry := mustCommandRegistry(t)
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{command: "ping", want: "!ping"},
+		{command: "ask", want: "!ask [grok|openai] <question>"},
+		{command: "user_opinion", want: "!user_opinion [grok|openai] <user> [days] [max_messages] [channel]"},
+		{command: "roast", want: "!roast [user] [message] [mild|spicy|savage]"},
+		{command: "opinion", want: "!opinion [grok|openai] [messages] [channel] [message] [since] [from] [to]"},
+		{command: "moderation", want: "!moderation [off|relaxed|standard|strict]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			cmd, _ := registry.lookup(tt.command)
+			if got := cmd.usage(); got != tt.want {
+				t.Errorf("usage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplicationCommandsMatchDefinitions(t *testing.T) {
+	registry := mustCommandRegistry(t)
+
+	for _, appCmd := range registry.applicationCommands() {
+		cmd, ok := registry.lookup(appCmd.Name)
+		if !ok {
+			t.Fatalf("slash command %q has no definition", appCmd.Name)
+		}
+
+		wantOptions := len(cmd.args)
+		if cmd.defaultProvider != "" {
+			wantOptions++
+		}
+		if len(appCmd.Options) != wantOptions {
+			t.Errorf("%s: %d options, want %d", appCmd.Name, len(appCmd.Options), wantOptions)
+		}
+
+		// Discord rejects required options that follow optional ones
+		seenOptional := false
+		for _, opt := range appCmd.Options {
+			if !opt.Required {
+				seenOptional = true
+			} else if seenOptional {
+				t.Errorf("%s: required option %q follows an optional one", appCmd.Name, opt.Name)
+			}
+		}
+
+		if cmd.permission != 0 && (appCmd.DefaultMemberPermissions == nil || *appCmd.DefaultMemberPermissions != cmd.permission) {
+			t.Errorf("%s: default member permissions not set", appCmd.Name)
+		}
+	}
+}
+
+func TestCooldownTracker(t *testing.T) {
+	tracker := newCooldownTracker()
+	cmd := &command{name: "ask", cooldown: 10 * time.Second}
+	start := time.Now()
+
+	if ok, _ := tracker.allow(cmd, "u1", start); !ok {
+		t.Fatal("first invocation should be allowed")
+	}
+	if ok, remaining := tracker.allow(cmd, "u1", start.Add(4*time.Second)); ok || remaining != 6*time.Second {
+		t.Errorf("second invocation = (%v, %v), want (false, 6s)", ok, remaining)
+	}
+	if ok, _ := tracker.allow(cmd, "u2", start.Add(4*time.Second)); !ok {
+		t.Error("cooldowns should be per user")
+	}
+	if ok, _ := tracker.allow(cmd, "u1", start.Add(10*time.Second)); !ok {
+		t.Error("invocation after the cooldown should be allowed")
+	}
+	if ok, _ := tracker.allow(&command{name: "ping"}, "u1", start); !ok {
+		t.Error("commands without a cooldown should always be allowed")
+	}
+}
+
+func TestDispatchEnforcesDefinitionRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		guildID     string
+		repeat      int
+		wantCalls   int
+		wantMessage string
+	}{
+		{name: "runs handler", command: "probe", guildID: "guild", repeat: 1, wantCalls: 1},
+		{name: "guild only", command: "probe", repeat: 1, wantCalls: 0, wantMessage: "only works in servers"},
+		{name: "cooldown", command: "probe", guildID: "guild", repeat: 2, wantCalls: 1, wantMessage: "Slow down"},
+		{name: "permission", command: "admin", guildID: "guild", repeat: 1, wantCalls: 0, wantMessage: "Manage Server"},
+		{name: "unknown", command: "missing", guildID: "guild", repeat: 1, wantCalls: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			var gotProvider string
+			handler := func(b *Bot, ctx context.Context, req *commandRequest) {
+				calls++
+				gotProvider = req.provider
+			}
+			registry, err := newCommandRegistry([]*command{
+				{name: "probe", defaultProvider: "openai", cooldown: time.Minute, guildOnly: true, handler: handler},
+				{name: "admin", permission: discordgo.PermissionManageGuild, handler: handler},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			session := &mockDiscordSession{}
+			bot := &Bot{
+				session:   session,
+				commands:  registry,
+				cooldowns: newCooldownTracker(),
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			m := &discordgo.MessageCreate{Message: &discordgo.Message{
+				GuildID:   tt.guildID,
+				ChannelID: "channel",
+				Author:    &discordgo.User{ID: "user"},
+			}}
+
+			for range tt.repeat {
+				bot.dispatch(context.Background(), tt.command, m, []string{"grok"})
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if calls > 0 && gotProvider != "grok" {
+				t.Errorf("provider = %q, want grok", gotProvider)
+			}
+			if tt.wantMessage != "" && !strings.Contains(strings.Join(session.sentMessages, "\n"), tt.wantMessage) {
+				t.Errorf("sent %q, want a message containing %q", session.sentMessages, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+)
+
+// condensedHistory is history ready for a single prompt: the lines themselves, or
+// summaries of them when they were too long to send at once
+type condensedHistory struct {
+	text       string
+	summarized bool
+}
+
+// note explains summarized history to the model, for appending to a system message
+func (h condensedHistory) note() string {
+	if !h.summarized {
+		return ""
+	}
+	return " There were too many messages to read at once, so the history block holds summaries " +
+		"of consecutive parts of the conversation, oldest first, instead of the messages themselves."
+}
+
+// condenseHistory fits history lines into one prompt. Lines within HistoryPromptTokens
+// are passed through; longer histories are summarized part by part, keeping what focus
+// describes, and statusChannelID is told how much of the history the summaries cover.
+// focus goes into the system message, so it must never contain member-supplied text.
+func (b *Bot) condenseHistory(ctx context.Context, statusChannelID, provider, model string, lines []string, focus string) (condensedHistory, error) {
+	text := strings.Join(lines, "\n")
+	if ai.EstimateTokens(text) <= HistoryPromptTokens {
+		return condensedHistory{text: text}, nil
+	}
+
+	progress := &summaryProgress{b: b, channelID: statusChannelID}
+	progress.update(ctx, 0, len(ai.ChunkLines(lines, SummaryChunkTokens)))
+	mr := &ai.MapReduce{
+		Client:        b.aiClient,
+		Provider:      provider,
+		Model:         model,
+		ChunkTokens:   SummaryChunkTokens,
+		SummaryTokens: SummaryMaxTokens,
+		Concurrency:   SummaryConcurrency,
+		System: buildSystemMessage("You take concise, neutral notes on chat conversations.", fmt.Sprintf(
+			"The user turn contains one part of a longer conversation. Summarize it in a few short bullet "+
+				"points for someone who will only read your notes. Keep who said what, using their names, "+
+				"quote the most telling lines verbatim, and keep %s.", focus)),
+		Prompt: func(chunk string) string {
+			return buildDataPrompt("Summarize this part of the conversation.", untrustedBlock("channel history", chunk))
+		},
+		Progress: func(done, total int) { progress.update(ctx, done, total) },
+	}
+
+	summaries, coverage, err := mr.Summarize(ctx, lines)
+	if err != nil {
+		return condensedHistory{}, err
+	}
+	progress.finish(ctx, coverage)
+
+	parts := make([]string, len(summaries))
+	for i, summary := range summaries {
+		parts[i] = fmt.Sprintf("Part %d:\n%s", i+1, summary)
+	}
+	return condensedHistory{text: strings.Join(parts, "\n\n"), summarized: true}, nil
+}
+
+// summaryProgress keeps one status message up to date while history is summarized.
+// Chunks finish concurrently, so updates are serialized.
+type summaryProgress struct {
+	b         *Bot
+	channelID string
+
+	mu      sync.Mutex
+	status  *discordgo.Message
+	updated time.Time
+}
+
+// update reports how many parts have been summarized, at most once per
+// HistoryProgressInterval
+func (p *summaryProgress) update(ctx context.Context, done, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.updated.IsZero() && time.Since(p.updated) < HistoryProgressInterval {
+		return
+	}
+	p.updated = time.Now()
+	p.show(ctx, fmt.Sprintf("That's a lot of history, summarizing it in parts first... %d/%d", done, total))
+}
+
+// finish replaces the status message with what the summaries cover
+func (p *summaryProgress) finish(ctx context.Context, coverage ai.Coverage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	content := fmt.Sprintf("Summarized %d messages in %d parts.", coverage.Lines, coverage.Chunks)
+	if !coverage.Complete() {
+		content = fmt.Sprintf("Summarized %d of %d messages; %d of %d parts failed, so the answer leaves them out.",
+			coverage.LinesSummarized, coverage.Lines, coverage.Chunks-coverage.Summarized, coverage.Chunks)
+	}
+	p.show(ctx, content)
+}
+
+// show posts the status message, or edits it once it exists. Background jobs show
+// progress in their own status message instead.
+func (p *summaryProgress) show(ctx context.Context, content string) {
+	if p.b.reportJobProgress(ctx, content) {
+		return
+	}
+	if p.status == nil {
+		status, err := p.b.send(ctx, p.channelID, content)
+		if err != nil {
+			p.b.logger.ErrorContext(ctx, "failed to send summary progress", "error", err)
+			return
+		}
+		p.status = status
+		return
+	}
+	if _, err := p.b.edit(ctx, p.channelID, p.status.ID, content); err != nil {
+		p.b.logger.ErrorContext(ctx, "failed to update summary progress", "error", err)
+	}
+}
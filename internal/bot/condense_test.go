@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestCondenseHistory(t *testing.T) {
+	long := make([]string, 100)
+	for i := range long {
+		long[i] = "alice: " + strings.Repeat("word ", 120)
+	}
+
+	tests := []struct {
+		name           string
+		lines          []string
+		wantSummarized bool
+		wantPrompts    int
+		wantStatus     string
+	}{
+		{
+			name:        "short history is sent as is",
+			lines:       []string{"alice: hi", "bob: hey"},
+			wantPrompts: 0,
+		},
+		{
+			name:           "long history is summarized in parts",
+			lines:          long,
+			wantSummarized: true,
+			wantPrompts:    3,
+			wantStatus:     "Summarized 100 messages in 3 parts.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockDiscordSession{}
+			mockAI := &mockAIClient{}
+			bot := &Bot{
+				session:  session,
+				aiClient: mockAI,
+				logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			got, err := bot.condenseHistory(context.Background(), "channel", "openai", "model", tt.lines, "the topics")
+			if err != nil {
+				t.Fatalf("condenseHistory() error = %v", err)
+			}
+			if got.summarized != tt.wantSummarized {
+				t.Errorf("summarized = %v, want %v", got.summarized, tt.wantSummarized)
+			}
+			if len(mockAI.prompts) != tt.wantPrompts {
+				t.Errorf("AskClient called %d times, want %d", len(mockAI.prompts), tt.wantPrompts)
+			}
+
+			if !tt.wantSummarized {
+				if got.text != strings.Join(tt.lines, "\n") || got.note() != "" {
+					t.Errorf("condenseHistory() = %+v, want the lines unchanged", got)
+				}
+				if len(session.sentMessages) > 0 {
+					t.Errorf("sent %q, want no status for short history", session.sentMessages)
+				}
+				return
+			}
+			if !strings.HasPrefix(got.text, "Part 1:\nmock response") || !strings.Contains(got.text, "Part 3:") {
+				t.Errorf("condenseHistory() text = %q, want numbered part summaries", got.text)
+			}
+			if got.note() == "" {
+				t.Error("summarized history has no note for the system message")
+			}
+			for _, prompt := range mockAI.prompts {
+				if !strings.Contains(prompt, "<untrusted_data") {
+					t.Error("part prompt doesn't wrap the history in an untrusted block")
+				}
+			}
+			if last := session.edits[len(session.edits)-1]; last != tt.wantStatus {
+				t.Errorf("final status = %q, want %q", last, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
+)
+
+// targetAllowed reports whether a member may be targeted by commands in a category,
+// given their opt-outs and the guild's protected roles
+func targetAllowed(gs settings.GuildSettings, userID string, roles []string, category string) bool {
+	return !gs.IsOptedOut(userID, category) && !gs.HasProtectedRole(roles)
+}
+
+// canTarget checks a member's consent for a category, fetching their roles when the
+// guild protects any. If the roles can't be fetched the member is treated as protected.
+func (b *Bot) canTarget(guildID, userID, category string) bool {
+	allowed, _ := b.checkTarget(guildID, userID, category)
+	return allowed
+}
+
+// checkTarget is canTarget for callers that retry later: it also returns the error when
+// a member's roles couldn't be fetched. Members who have left the server can't have
+// their roles checked again, so they are reported as protected without an error.
+func (b *Bot) checkTarget(guildID, userID, category string) (bool, error) {
+	if b.settings == nil || guildID == "" {
+		return true, nil
+	}
+
+	gs := b.settings.Guild(guildID)
+	if len(gs.ProtectedRoles) == 0 {
+		return targetAllowed(gs, userID, nil, category), nil
+	}
+
+	member, err := b.guildMember(guildID, userID)
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMember {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return targetAllowed(gs, userID, member.Roles, category), nil
+}
+
+// handleOptOut handles the !optout command
+func (b *Bot) handleOptOut(ctx context.Context, req *commandRequest) {
+	b.updateOptOut(ctx, req, true)
+}
+
+// handleOptIn handles the !optin command
+func (b *Bot) handleOptIn(ctx context.Context, req *commandRequest) {
+	b.updateOptOut(ctx, req, false)
+}
+
+// updateOptOut records the author's consent choice, or reports their status when no category is given
+func (b *Bot) updateOptOut(ctx context.Context, req *commandRequest, optOut bool) {
+	m := req.m
+	value, given := req.value("category")
+
+	if !given {
+		gs := b.settings.Guild(m.GuildID)
+		var optedOut []string
+		for _, category := range settings.OptOutCategories {
+			if gs.IsOptedOut(m.Author.ID, category) {
+				optedOut = append(optedOut, category)
+			}
+		}
+		status := "You haven't opted out of anything."
+		if len(optedOut) > 0 {
+			status = fmt.Sprintf("You're opted out of: %s.", strings.Join(optedOut, ", "))
+		}
+		b.send(ctx, m.ChannelID, status+" Usage: "+req.cmd.usage())
+		return
+	}
+
+	categories, err := settings.ParseOptOutCategories(value)
+	if err != nil {
+		b.send(ctx, m.ChannelID, err.Error())
+		return
+	}
+
+	err = b.settings.UpdateGuild(m.GuildID, func(gs *settings.GuildSettings) {
+		for _, category := range categories {
+			gs.SetOptOut(m.Author.ID, category, optOut)
+		}
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to save guild settings", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error saving settings: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "opt-out updated",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"categories", categories,
+		"opted_out", optOut)
+
+	if optOut {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Got it, you're opted out of %s. I'll leave you alone.", strings.Join(categories, " and ")))
+	} else {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("You're back in for %s. You asked for it.", strings.Join(categories, " and ")))
+	}
+}
+
+// handleProtectRole handles the !protect_role command
+func (b *Bot) handleProtectRole(ctx context.Context, req *commandRequest) {
+	b.updateProtectedRoles(ctx, req, true)
+}
+
+// handleUnprotectRole handles the !unprotect_role command
+func (b *Bot) handleUnprotectRole(ctx context.Context, req *commandRequest) {
+	b.updateProtectedRoles(ctx, req, false)
+}
+
+// updateProtectedRoles adds or removes the role argument and any other mentioned roles
+// from the guild's protected list. A role given as --role=<id> isn't a mention, so the
+// parsed argument is read first.
+func (b *Bot) updateProtectedRoles(ctx context.Context, req *commandRequest, protect bool) {
+	m := req.m
+	roleIDs := slices.Clone(m.MentionRoles)
+	if roleID, ok := req.value("role"); ok && !slices.Contains(roleIDs, roleID) {
+		roleIDs = append([]string{roleID}, roleIDs...)
+	}
+	if len(roleIDs) == 0 {
+		b.sendUsage(ctx, req, "")
+		return
+	}
+
+	err := b.settings.UpdateGuild(m.GuildID, func(gs *settings.GuildSettings) {
+		for _, roleID := range roleIDs {
+			gs.SetProtectedRole(roleID, protect)
+		}
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to save guild settings", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error saving settings: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "protected roles updated",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"role_ids", roleIDs,
+		"protected", protect)
+
+	if protect {
+		b.send(ctx, m.ChannelID, "Done. Members with those roles are off-limits for roasts and analysis.")
+	} else {
+		b.send(ctx, m.ChannelID, "Done. Those roles are no longer protected.")
+	}
+}
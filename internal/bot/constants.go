@@ -13,6 +13,177 @@ const (
 	TopActiveUsersCount           = 5
 )
 
+// Persistent data files, relative to the configured data directory
+const (
+	GuildSettingsFile = "guild_settings.json"
+	// MemoryFile holds short-term conversation memory when MEMORY_PERSIST is on
+	MemoryFile = "conversation_memory.json"
+	FactsFile  = "facts.json"
+	// ArchiveFile is the embedded database of archived channel messages
+	ArchiveFile = "messages.db"
+	// VectorsFile is the embedded database of message embeddings used by !recall
+	VectorsFile = "vectors.db"
+	// SearchFile is the embedded inverted index used by !search
+	SearchFile = "search.db"
+	// JobsFile holds background job records, so jobs can resume after a restart
+	JobsFile = "jobs.json"
+)
+
+// Link summarization limits
+const (
+	// TLDRMaxArticleTokens caps how much of a fetched page is sent to the model
+	TLDRMaxArticleTokens = 6000
+	// TLDRMaxPageBytes caps the size of a fetched page
+	TLDRMaxPageBytes = 5 << 20
+	// TLDRFetchTimeout bounds the time spent downloading a page
+	TLDRFetchTimeout = 20 * time.Second
+)
+
+// Help and command suggestion settings
+const (
+	// HelpEmbedColor is the accent color of help embeds
+	HelpEmbedColor = 0x5865F2
+	// MaxCommandSuggestions caps the "did you mean" list
+	MaxCommandSuggestions = 3
+	// MaxSuggestionDistance is the largest edit distance still offered as a suggestion
+	MaxSuggestionDistance = 2
+)
+
+// Conversation settings for replies to mentions and reply chains
+const (
+	// MaxConversationMessages caps how many messages of a reply chain are sent as context
+	MaxConversationMessages = 20
+	// MaxConversationTokens caps the estimated size of the conversation sent to the model
+	MaxConversationTokens = 4000
+	// ConversationCooldown is the per-user delay between conversational replies
+	ConversationCooldown = 5 * time.Second
+)
+
+// Short-term conversation memory for !ask
+const (
+	// MemoryMaxTokens bounds each remembered conversation; older turns are summarized
+	MemoryMaxTokens = 2000
+	// MemoryIdleTimeout is how long a conversation is remembered without new messages
+	MemoryIdleTimeout = 2 * time.Hour
+)
+
+// Long-term facts about members and server lore
+const (
+	// MaxFactsPerGuild caps stored facts, pending ones included
+	MaxFactsPerGuild = 500
+	// MaxFactLength caps the length of a single fact
+	MaxFactLength = 300
+	// MaxRelevantFacts caps how many facts are added to a prompt
+	MaxRelevantFacts = 5
+	// MinFactExtractionLength skips extraction for messages too short to state a fact
+	MinFactExtractionLength = 20
+	// MaxExtractedFacts caps the facts extracted from a single message
+	MaxExtractedFacts = 3
+)
+
+// Local message archive
+const (
+	// ArchivePruneInterval is how often messages past the retention period are deleted
+	ArchivePruneInterval = time.Hour
+	// IndexInterval is how often newly archived messages are added to the search and
+	// recall indexes
+	IndexInterval = 5 * time.Minute
+)
+
+// Reading channel history
+const (
+	// MaxMessagesPerRequest is Discord's cap on messages returned by one history request
+	MaxMessagesPerRequest = 100
+	// MaxHistoryMessages is the hard ceiling on messages read for one command
+	MaxHistoryMessages = 2000
+	// HistoryProgressInterval is the minimum time between progress updates on long reads
+	HistoryProgressInterval = 3 * time.Second
+)
+
+// Semantic search over archived messages with !recall
+const (
+	// RecallTopUpMessages caps the messages per channel embedded when !recall runs, so
+	// the most recent messages can be found before the next indexing pass
+	RecallTopUpMessages = 200
+	// MinRecallWords skips messages too short to be worth recalling
+	MinRecallWords = 3
+	// MaxRecallMatches caps the messages sent to the model as sources
+	MaxRecallMatches = 8
+	// MinRecallScore is the lowest similarity still treated as a match
+	MinRecallScore = 0.2
+)
+
+// Keyword search over archived messages with !search
+const (
+	// MaxSearchResults caps the matches listed in one search
+	MaxSearchResults = 10
+	// SearchSnippetLength caps the characters of each match shown
+	SearchSnippetLength = 150
+	// SearchEmbedColor is the accent color of search results
+	SearchEmbedColor = 0x5865F2
+)
+
+// History transcripts sent to the model
+const (
+	// ReplyQuoteLength caps the quoted start of a replied-to message
+	ReplyQuoteLength = 60
+	// EmbedTextLength caps embed titles, descriptions and image captions
+	EmbedTextLength = 100
+	// MaxImageCaptions caps the images captioned per history read when IMAGE_CAPTIONS is on
+	MaxImageCaptions = 5
+	// ImageCaptionMaxTokens bounds the length of each caption
+	ImageCaptionMaxTokens = 60
+	// MaxCachedCaptions caps the captions remembered across commands
+	MaxCachedCaptions = 500
+)
+
+// Summarizing histories too long for one prompt
+const (
+	// HistoryPromptTokens is the largest history sent to the model as is; longer ones are
+	// summarized in parts first
+	HistoryPromptTokens = 12000
+	// SummaryChunkTokens caps the history in each summarized part
+	SummaryChunkTokens = 6000
+	// SummaryMaxTokens caps the length of each part's summary
+	SummaryMaxTokens = 400
+	// SummaryConcurrency caps the parts summarized at once
+	SummaryConcurrency = 4
+)
+
+// Personal catch-up summaries with !catchup
+const (
+	// CatchupMaxMessages caps how far back !catchup looks for the member's last message
+	CatchupMaxMessages = 500
+)
+
+// Background jobs for long-running commands
+const (
+	// MaxConcurrentJobs caps the jobs running at once; later ones wait in the queue
+	MaxConcurrentJobs = 3
+	// MaxJobAttempts caps how many times a job is started; a job interrupted by that many
+	// restarts fails instead of resuming again
+	MaxJobAttempts = 2
+	// JobRetention is how long finished jobs are kept before being pruned at startup
+	JobRetention = 24 * time.Hour
+	// JobLabelLength caps the command shown in job status messages and !jobs
+	JobLabelLength = 80
+)
+
+// Guild member cache
+const (
+	// MemberCacheTTL is how long a member fetched over REST is reused
+	MemberCacheTTL = 10 * time.Minute
+	// MemberErrorTTL is how long a failed member lookup is remembered
+	MemberErrorTTL = time.Minute
+)
+
+// Graceful shutdown
+const (
+	// ShutdownCancelGrace is the end of the shutdown timeout kept for handlers still
+	// running to return after their contexts are cancelled
+	ShutdownCancelGrace = 5 * time.Second
+)
+
 // Message delivery timing for human-like responses
 const (
 	// MinMessageDelay is the minimum delay between message chunks
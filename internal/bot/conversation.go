@@ -0,0 +1,223 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+)
+
+// conversationCommand applies a cooldown to conversational replies. It isn't in the
+// registry, so it can't be invoked by name.
+var conversationCommand = &command{name: "conversation", cooldown: ConversationCooldown}
+
+// conversationTask tells the model how to treat the reply chain it is given
+const conversationTask = "You're chatting in a Discord channel. The earlier turns are the reply chain " +
+	"that led here; user turns start with the speaker's name. Reply to the latest message " +
+	"naturally and conversationally, like a regular in the chat, and keep it short unless asked for detail."
+
+// isConversationTrigger reports whether a message mentions the bot or replies to one of its messages
+func isConversationTrigger(m *discordgo.Message, botID string) bool {
+	for _, user := range m.Mentions {
+		if user.ID == botID {
+			return true
+		}
+	}
+	return m.ReferencedMessage != nil && m.ReferencedMessage.Author != nil && m.ReferencedMessage.Author.ID == botID
+}
+
+// handleConversation answers a mention or reply using the reply chain as multi-turn context
+func (b *Bot) handleConversation(ctx context.Context, m *discordgo.MessageCreate, botID string) {
+	if ok, _ := b.cooldowns.allow(conversationCommand, m.Author.ID, time.Now()); !ok {
+		b.logger.DebugContext(ctx, "conversation on cooldown", "user_id", m.Author.ID)
+		return
+	}
+
+	thread := b.conversationThread(ctx, m.Message, botID)
+	text := conversationText(m.Message, botID)
+	turns := append(b.factTurns(m, text), b.conversationTurns(ctx, m.GuildID, botID, thread)...)
+
+	provider := ai.DefaultProvider
+	model := ai.DefaultGrokModel
+
+	b.logger.InfoContext(ctx, "replying to conversation",
+		"user_id", m.Author.ID,
+		"channel_id", m.ChannelID,
+		"thread_messages", len(thread),
+		"turns", len(turns))
+
+	if err := b.session.ChannelTyping(m.ChannelID); err != nil {
+		b.logger.DebugContext(ctx, "failed to send typing indicator", "error", err)
+	}
+
+	response, err := b.aiClient.Chat(ctx, turns, buildSystemMessage(ai.GrokPersona, conversationTask), model, provider, ai.DefaultMaxTokens)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "AI request failed", "command", "conversation", "error", err)
+		b.send(withReply(ctx, m.Message), m.ChannelID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+
+	b.sendLongResponse(withReply(ctx, m.Message), m.ChannelID, b.moderateResponse(ctx, m, "conversation", response))
+	b.extractFacts(ctx, m, text, provider)
+}
+
+// conversationThread walks the reply chain that ends at m and returns it oldest first,
+// capped at MaxConversationMessages. Deleted or inaccessible messages end the walk.
+func (b *Bot) conversationThread(ctx context.Context, m *discordgo.Message, botID string) []*discordgo.Message {
+	thread := []*discordgo.Message{m}
+	seen := map[string]bool{m.ID: true}
+
+	current := m
+	for len(thread) < MaxConversationMessages {
+		parent := b.referencedMessage(ctx, current)
+		if parent == nil && current != m && isAuthor(current, botID) {
+			// Long bot replies are sent in parts and only the first part is a reply, so
+			// step back over the earlier parts to find it
+			parent = b.earlierReplyPart(ctx, current, botID, &thread, seen)
+		}
+		if parent == nil || seen[parent.ID] {
+			break
+		}
+		seen[parent.ID] = true
+		thread = append(thread, parent)
+		current = parent
+	}
+
+	// Collected newest first
+	slices.Reverse(thread)
+	return thread
+}
+
+// referencedMessage returns the message msg replies to, fetching it when Discord didn't
+// include it with the event
+func (b *Bot) referencedMessage(ctx context.Context, msg *discordgo.Message) *discordgo.Message {
+	if msg.ReferencedMessage != nil {
+		return msg.ReferencedMessage
+	}
+	ref := msg.MessageReference
+	if ref == nil || ref.MessageID == "" {
+		return nil
+	}
+
+	channelID := ref.ChannelID
+	if channelID == "" {
+		channelID = msg.ChannelID
+	}
+	parent, err := b.session.ChannelMessage(channelID, ref.MessageID)
+	if err != nil {
+		b.logger.DebugContext(ctx, "reply chain ended at unavailable message",
+			"message_id", ref.MessageID,
+			"error", err)
+		return nil
+	}
+	return parent
+}
+
+// earlierReplyPart looks back from an unreferenced bot message over the bot's
+// immediately preceding messages. Each part it passes is added to the thread; the
+// parent of the first part that is a reply is returned.
+func (b *Bot) earlierReplyPart(ctx context.Context, msg *discordgo.Message, botID string, thread *[]*discordgo.Message, seen map[string]bool) *discordgo.Message {
+	previous, err := b.session.ChannelMessages(msg.ChannelID, MaxConversationMessages, msg.ID, "", "")
+	if err != nil {
+		b.logger.DebugContext(ctx, "failed to fetch earlier reply parts", "error", err)
+		return nil
+	}
+
+	// Messages arrive newest first
+	for _, part := range previous {
+		if !isAuthor(part, botID) || seen[part.ID] || len(*thread) >= MaxConversationMessages {
+			return nil
+		}
+		seen[part.ID] = true
+		*thread = append(*thread, part)
+		if parent := b.referencedMessage(ctx, part); parent != nil {
+			return parent
+		}
+	}
+	return nil
+}
+
+// conversationTurns converts a reply chain into chat turns. The bot's messages become
+// assistant turns and consecutive parts are merged; everyone else's are user turns
+// labelled with the speaker. The oldest turns are dropped to fit MaxConversationTokens.
+func (b *Bot) conversationTurns(ctx context.Context, guildID, botID string, thread []*discordgo.Message) []ai.ChatMessage {
+	var turns []ai.ChatMessage
+	var userLines []string
+	var userTurns []int
+
+	for _, msg := range thread {
+		if msg.Author == nil {
+			continue
+		}
+		if isAuthor(msg, botID) {
+			if n := len(turns); n > 0 && turns[n-1].Role == ai.RoleAssistant {
+				turns[n-1].Content += "\n" + msg.Content
+				continue
+			}
+			turns = append(turns, ai.ChatMessage{Role: ai.RoleAssistant, Content: msg.Content})
+			continue
+		}
+
+		text := conversationText(msg, botID)
+		if text == "" {
+			text = "(mentioned you without saying anything)"
+		}
+		userLines = append(userLines, fmt.Sprintf("%s: %s", b.displayName(guildID, msg.Author), text))
+		userTurns = append(userTurns, len(turns))
+		turns = append(turns, ai.ChatMessage{Role: ai.RoleUser})
+	}
+
+	for i, line := range b.redactPII(ctx, guildID, "conversation", userLines) {
+		turns[userTurns[i]].Content = line
+	}
+
+	return trimConversation(turns, MaxConversationTokens)
+}
+
+// trimConversation drops the oldest turns until the conversation fits within maxTokens.
+// The latest turn is always kept.
+func trimConversation(turns []ai.ChatMessage, maxTokens int) []ai.ChatMessage {
+	total := 0
+	for _, turn := range turns {
+		total += ai.EstimateTokens(turn.Content)
+	}
+
+	start := 0
+	for start < len(turns)-1 && total > maxTokens {
+		total -= ai.EstimateTokens(turns[start].Content)
+		start++
+	}
+	return turns[start:]
+}
+
+// conversationText returns a message's content with the bot's mention removed and other
+// user mentions replaced by names
+func conversationText(msg *discordgo.Message, botID string) string {
+	return replaceMentions(msg.Content, msg.Mentions, botID)
+}
+
+// replaceMentions removes mentions of the bot from text and replaces other user
+// mentions with names, collapsing the whitespace left behind
+func replaceMentions(text string, mentions []*discordgo.User, botID string) string {
+	var replacements []string
+	if botID != "" {
+		replacements = append(replacements, "<@"+botID+">", "", "<@!"+botID+">", "")
+	}
+	for _, user := range mentions {
+		if user.ID == botID {
+			continue
+		}
+		replacements = append(replacements, "<@"+user.ID+">", "@"+user.Username, "<@!"+user.ID+">", "@"+user.Username)
+	}
+	return strings.Join(strings.Fields(strings.NewReplacer(replacements...).Replace(text)), " ")
+}
+
+// isAuthor reports whether msg was written by the given user
+func isAuthor(msg *discordgo.Message, userID string) bool {
+	return msg.Author != nil && msg.Author.ID == userID
+}
@@ -0,0 +1,221 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+)
+
+const testBotID = "bot"
+
+var (
+	testBotUser = &discordgo.User{ID: testBotID, Username: "coonbot", Bot: true}
+	testAlice   = &discordgo.User{ID: "1", Username: "alice"}
+	testBob     = &discordgo.User{ID: "2", Username: "bob"}
+)
+
+// reply builds a message that replies to parent by ID only, as Discord sends older chains
+func reply(id string, author *discordgo.User, content, parentID string) *discordgo.Message {
+	msg := &discordgo.Message{ID: id, ChannelID: "channel", Author: author, Content: content}
+	if parentID != "" {
+		msg.MessageReference = &discordgo.MessageReference{MessageID: parentID, ChannelID: "channel"}
+	}
+	return msg
+}
+
+func TestIsConversationTrigger(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *discordgo.Message
+		want bool
+	}{
+		{name: "mention", msg: &discordgo.Message{Mentions: []*discordgo.User{testBotUser}}, want: true},
+		{name: "reply to bot", msg: &discordgo.Message{ReferencedMessage: &discordgo.Message{Author: testBotUser}}, want: true},
+		{name: "reply to someone else", msg: &discordgo.Message{ReferencedMessage: &discordgo.Message{Author: testAlice}}},
+		{name: "mentions someone else", msg: &discordgo.Message{Mentions: []*discordgo.User{testBob}}},
+		{name: "plain message", msg: &discordgo.Message{Content: "hello"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConversationTrigger(tt.msg, testBotID); got != tt.want {
+				t.Errorf("isConversationTrigger() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversationText(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *discordgo.Message
+		want string
+	}{
+		{
+			name: "bot mention removed",
+			msg:  &discordgo.Message{Content: "<@bot> what's up", Mentions: []*discordgo.User{testBotUser}},
+			want: "what's up",
+		},
+		{
+			name: "nickname mention removed",
+			msg:  &discordgo.Message{Content: "hey <@!bot>, you there?", Mentions: []*discordgo.User{testBotUser}},
+			want: "hey , you there?",
+		},
+		{
+			name: "other mentions named",
+			msg:  &discordgo.Message{Content: "<@bot> is <@2> right?", Mentions: []*discordgo.User{testBotUser, testBob}},
+			want: "is @bob right?",
+		},
+		{
+			name: "only a mention",
+			msg:  &discordgo.Message{Content: "<@bot>", Mentions: []*discordgo.User{testBotUser}},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conversationText(tt.msg, testBotID); got != tt.want {
+				t.Errorf("conversationText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversationThread(t *testing.T) {
+	// alice asks, the bot answers in two parts, bob replies to the second part, and
+	// alice replies to bob
+	ask := reply("10", testAlice, "<@bot> who's the best Celtic ever?", "")
+	part1 := reply("11", testBotUser, "Bird. Obviously.", "10")
+	part2 := reply("12", testBotUser, "Russell has the rings though.", "")
+	bobReply := reply("13", testBob, "Russell, easy", "12")
+	latest := reply("14", testAlice, "<@bot> settle this", "13")
+
+	tests := []struct {
+		name    string
+		history []*discordgo.Message
+		want    []string
+	}{
+		{
+			name:    "follows replies and multi-part bot answers",
+			history: []*discordgo.Message{latest, bobReply, part2, part1, ask},
+			want:    []string{"10", "11", "12", "13", "14"},
+		},
+		{
+			name: "stops at a human between bot parts",
+			history: []*discordgo.Message{latest, bobReply, part2,
+				reply("99", testBob, "unrelated", ""), part1, ask},
+			want: []string{"12", "13", "14"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockDiscordSession{history: tt.history, messages: map[string]*discordgo.Message{}}
+			for _, msg := range tt.history {
+				session.messages[msg.ID] = msg
+			}
+			bot := &Bot{session: session, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+			thread := bot.conversationThread(context.Background(), latest, testBotID)
+
+			var ids []string
+			for _, msg := range thread {
+				ids = append(ids, msg.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("thread = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversationThreadStopsAtDeletedMessage(t *testing.T) {
+	latest := reply("2", testAlice, "<@bot> right?", "1")
+	session := &mockDiscordSession{messages: map[string]*discordgo.Message{}}
+	bot := &Bot{session: session, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	thread := bot.conversationThread(context.Background(), latest, testBotID)
+
+	if len(thread) != 1 || thread[0] != latest {
+		t.Errorf("thread = %v, want only the latest message", thread)
+	}
+}
+
+func TestTrimConversation(t *testing.T) {
+	turns := []ai.ChatMessage{
+		{Role: ai.RoleUser, Content: strings.Repeat("a", 40)},
+		{Role: ai.RoleAssistant, Content: strings.Repeat("b", 40)},
+		{Role: ai.RoleUser, Content: strings.Repeat("c", 40)},
+	}
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		wantLen   int
+	}{
+		{name: "fits", maxTokens: 30, wantLen: 3},
+		{name: "drops oldest", maxTokens: 20, wantLen: 2},
+		{name: "always keeps latest", maxTokens: 1, wantLen: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trimConversation(turns, tt.maxTokens)
+			if len(got) != tt.wantLen {
+				t.Fatalf("len = %d, want %d", len(got), tt.wantLen)
+			}
+			if got[len(got)-1] != turns[len(turns)-1] {
+				t.Errorf("latest turn dropped")
+			}
+		})
+	}
+}
+
+func TestHandleConversation(t *testing.T) {
+	answer := reply("11", testBotUser, "Bird. Obviously.", "10")
+	answer.ReferencedMessage = reply("10", testAlice, "<@bot> best Celtic ever?", "")
+	latest := reply("12", testBob, "nah, Russell", "11")
+	latest.ReferencedMessage = answer
+
+	session := &mockDiscordSession{}
+	mockAI := &mockAIClient{}
+	bot := &Bot{
+		session:   session,
+		aiClient:  mockAI,
+		cooldowns: newCooldownTracker(),
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	m := &discordgo.MessageCreate{Message: latest}
+
+	bot.handleConversation(context.Background(), m, testBotID)
+
+	if len(mockAI.conversations) != 1 {
+		t.Fatalf("Chat called %d times, want 1", len(mockAI.conversations))
+	}
+	want := []ai.ChatMessage{
+		{Role: ai.RoleUser, Content: "alice: best Celtic ever?"},
+		{Role: ai.RoleAssistant, Content: "Bird. Obviously."},
+		{Role: ai.RoleUser, Content: "bob: nah, Russell"},
+	}
+	if !slices.Equal(mockAI.conversations[0], want) {
+		t.Errorf("turns = %v, want %v", mockAI.conversations[0], want)
+	}
+
+	if len(session.replies) != 1 || session.replies[0].MessageID != "12" {
+		t.Errorf("replies = %v, want one reply to message 12", session.replies)
+	}
+
+	// A second message straight away is on cooldown and gets no answer
+	bot.handleConversation(context.Background(), m, testBotID)
+	if len(mockAI.conversations) != 1 {
+		t.Errorf("Chat called %d times after cooldown, want 1", len(mockAI.conversations))
+	}
+}
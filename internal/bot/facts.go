@@ -0,0 +1,333 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/facts"
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
+)
+
+// factExtractionTask instructs the model that pulls durable facts out of a message
+const factExtractionTask = "Read the Discord message in the user turn and list durable facts its author " +
+	"states about themselves: teams they support, where they live, their job, hobbies, strong preferences. " +
+	"Ignore opinions about the current conversation, jokes, hypotheticals, questions and anything sensitive " +
+	"(health, religion, politics, sexuality, contact details). Write each fact as a short phrase about the " +
+	"author without naming them, e.g. \"is a Jets fan\". Reply with only a JSON array of strings, or [] if there are none."
+
+// selfReferencePattern matches facts the author states about themselves
+var selfReferencePattern = regexp.MustCompile(`(?i)^(i|i'm|i\x{2019}m|im|i am|my|me)\b`)
+
+// newFactStore builds the persistent long-term fact store
+func newFactStore(cfg *config.Config) (*facts.Store, error) {
+	return facts.NewStore(filepath.Join(cfg.DataDir, FactsFile), MaxFactsPerGuild)
+}
+
+// handleRemember handles the !remember command
+func (b *Bot) handleRemember(ctx context.Context, req *commandRequest) {
+	m := req.m
+	text := replaceMentions(req.text("fact"), m.Mentions, "")
+	if text == "" {
+		b.sendUsage(ctx, req, "")
+		return
+	}
+	if len(text) > MaxFactLength {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("That's a lot to remember. Keep facts under %d characters.", MaxFactLength))
+		return
+	}
+
+	fact := facts.Fact{
+		Text:     text,
+		AuthorID: m.Author.ID,
+		Source:   facts.SourceCommand,
+		Status:   facts.StatusPending,
+	}
+
+	// A fact is about the first member mentioned, or the author when they talk about themselves
+	for _, user := range m.Mentions {
+		if !user.Bot {
+			fact.SubjectID, fact.SubjectName = user.ID, user.Username
+			fact.Text = strings.TrimSpace(strings.TrimPrefix(text, "@"+user.Username))
+			break
+		}
+	}
+	if fact.SubjectID == "" && selfReferencePattern.MatchString(text) {
+		fact.SubjectID, fact.SubjectName = m.Author.ID, m.Author.Username
+	}
+
+	if fact.SubjectID != "" && !b.canTarget(m.GuildID, fact.SubjectID, settings.OptOutAnalysis) {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("%s has opted out of analysis, so I won't keep facts about them.", fact.SubjectName))
+		return
+	}
+
+	// Facts about yourself are trusted; anything else needs a moderator's approval
+	if fact.SubjectID == m.Author.ID || b.hasPermission(m, discordgo.PermissionManageMessages) {
+		fact.Status = facts.StatusApproved
+	}
+
+	added, err := b.facts.Add(m.GuildID, fact)
+	var limitErr *facts.LimitError
+	if errors.As(err, &limitErr) {
+		b.send(ctx, m.ChannelID, capitalize(err.Error())+".")
+		return
+	}
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to save fact", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error saving fact: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "fact remembered",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"fact_id", added.ID,
+		"status", added.Status)
+
+	if added.Status == facts.StatusPending {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Noted as fact `%s`. A moderator has to approve facts about other people or the server before I use them.", added.ID))
+		return
+	}
+	b.send(ctx, m.ChannelID, fmt.Sprintf("Got it, I'll remember that (fact `%s`).", added.ID))
+}
+
+// handleForget handles the !forget command
+func (b *Bot) handleForget(ctx context.Context, req *commandRequest) {
+	m := req.m
+	id, given := req.value("id")
+	if !given {
+		b.sendUsage(ctx, req, "Use `!facts` to find the ID.")
+		return
+	}
+
+	fact, ok := b.facts.Get(m.GuildID, id)
+	if !ok {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("I don't have a fact `%s`.", id))
+		return
+	}
+
+	// The person who added a fact, the person it is about and moderators may remove it
+	if m.Author.ID != fact.AuthorID && m.Author.ID != fact.SubjectID && !b.hasPermission(m, discordgo.PermissionManageMessages) {
+		b.send(ctx, m.ChannelID, "You can only forget facts you added or that are about you.")
+		return
+	}
+
+	if _, err := b.facts.Remove(m.GuildID, id); err != nil {
+		b.logger.ErrorContext(ctx, "failed to remove fact", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error forgetting fact: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "fact forgotten",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"fact_id", id)
+
+	b.send(ctx, m.ChannelID, fmt.Sprintf("Forgotten: %s", fact))
+}
+
+// handleFacts handles the !facts command
+func (b *Bot) handleFacts(ctx context.Context, req *commandRequest) {
+	m := req.m
+	subjectID, _ := req.value("user")
+
+	list := b.facts.List(m.GuildID, facts.StatusApproved, subjectID)
+	if len(list) == 0 {
+		b.send(ctx, m.ChannelID, "I don't remember anything yet. Teach me with `!remember`.")
+		return
+	}
+
+	b.sendList(ctx, m.ChannelID, fmt.Sprintf("**Things I remember** (%d). Remove one with `!forget <id>`.", len(list)), factLines(list))
+}
+
+// handleReview handles the !review command
+func (b *Bot) handleReview(ctx context.Context, req *commandRequest) {
+	m := req.m
+	action, _ := req.value("action")
+	id, hasID := req.value("id")
+
+	if action == "" {
+		pending := b.facts.List(m.GuildID, facts.StatusPending, "")
+		if len(pending) == 0 {
+			b.send(ctx, m.ChannelID, "No facts are waiting for review.")
+			return
+		}
+		b.sendList(ctx, m.ChannelID, fmt.Sprintf("**Facts waiting for review** (%d). Use `!review approve <id>` or `!review reject <id>`.", len(pending)), factLines(pending))
+		return
+	}
+
+	if !hasID {
+		b.sendUsage(ctx, req, "")
+		return
+	}
+
+	fact, ok := b.facts.Get(m.GuildID, id)
+	if !ok {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("I don't have a fact `%s`.", id))
+		return
+	}
+
+	var err error
+	if action == "approve" {
+		_, err = b.facts.Approve(m.GuildID, id)
+	} else {
+		_, err = b.facts.Remove(m.GuildID, id)
+	}
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to update fact", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error updating fact: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "fact reviewed",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"fact_id", id,
+		"action", action)
+
+	if action == "approve" {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Approved: %s", fact))
+		return
+	}
+	b.send(ctx, m.ChannelID, fmt.Sprintf("Rejected: %s", fact))
+}
+
+// factLines formats facts for listing, one per line with their IDs
+func factLines(list []facts.Fact) []string {
+	lines := make([]string, len(list))
+	for i, fact := range list {
+		line := fmt.Sprintf("`%s` %s", fact.ID, fact)
+		if fact.Source == facts.SourceExtracted {
+			line += " *(picked up from chat)*"
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// factTurns returns a user turn carrying the approved facts relevant to text and the
+// members involved, or nil when there are none. Facts were written by members, so they
+// are passed as data rather than instructions.
+func (b *Bot) factTurns(m *discordgo.MessageCreate, text string) []ai.ChatMessage {
+	if b.facts == nil || m.GuildID == "" {
+		return nil
+	}
+
+	subjects := []string{m.Author.ID}
+	for _, user := range m.Mentions {
+		subjects = append(subjects, user.ID)
+	}
+
+	var lines []string
+	for _, fact := range b.facts.Relevant(m.GuildID, text, subjects, MaxRelevantFacts) {
+		// Members who opted out after a fact was stored are left out
+		if fact.SubjectID != "" && !b.canTarget(m.GuildID, fact.SubjectID, settings.OptOutAnalysis) {
+			continue
+		}
+		lines = append(lines, "- "+fact.String())
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []ai.ChatMessage{{
+		Role: ai.RoleUser,
+		Content: "Things you remember about this server and its members. Bring them up only when relevant:\n" +
+			untrustedBlock("remembered facts", strings.Join(lines, "\n")),
+	}}
+}
+
+// extractFacts asks the model for durable facts the author stated about themselves and
+// stores them for moderator review. It only runs when FACT_EXTRACTION is enabled.
+func (b *Bot) extractFacts(ctx context.Context, m *discordgo.MessageCreate, text, provider string) {
+	if b.facts == nil || b.config == nil || !b.config.FactExtraction || m.GuildID == "" {
+		return
+	}
+	if len(text) < MinFactExtractionLength || !b.canTarget(m.GuildID, m.Author.ID, settings.OptOutAnalysis) {
+		return
+	}
+
+	model := ai.DefaultGrokModel
+	if provider == ai.ProviderOpenAI {
+		model = ai.DefaultOpenAIModel
+	}
+
+	lines := b.redactPII(ctx, m.GuildID, "fact_extraction", []string{text})
+	systemMessage := buildSystemMessage("You extract facts for a chat bot's long-term memory.", factExtractionTask)
+	prompt := buildDataPrompt("List the facts as a JSON array.", untrustedBlock("message", lines[0]))
+
+	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, model, provider, ai.DefaultMaxTokens)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "fact extraction failed", "error", err)
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, status := range []string{facts.StatusApproved, facts.StatusPending} {
+		for _, fact := range b.facts.List(m.GuildID, status, m.Author.ID) {
+			known[strings.ToLower(fact.Text)] = true
+		}
+	}
+
+	added := 0
+	for _, text := range parseExtractedFacts(response) {
+		if known[strings.ToLower(text)] {
+			continue
+		}
+		_, err := b.facts.Add(m.GuildID, facts.Fact{
+			Text:        text,
+			SubjectID:   m.Author.ID,
+			SubjectName: m.Author.Username,
+			Source:      facts.SourceExtracted,
+			Status:      facts.StatusPending,
+		})
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to save extracted fact", "error", err)
+			break
+		}
+		added++
+	}
+
+	if added > 0 {
+		b.logger.InfoContext(ctx, "extracted facts for review",
+			"guild_id", m.GuildID,
+			"user_id", m.Author.ID,
+			"count", added)
+	}
+}
+
+// parseExtractedFacts reads the JSON array of facts from a model response, tolerating
+// surrounding prose or code fences. Overlong and excess facts are dropped.
+func parseExtractedFacts(response string) []string {
+	start := strings.Index(response, "[")
+	end := strings.LastIndex(response, "]")
+	if start < 0 || end < start {
+		return nil
+	}
+
+	var candidates []string
+	if err := json.Unmarshal([]byte(response[start:end+1]), &candidates); err != nil {
+		return nil
+	}
+
+	var extracted []string
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" || len(candidate) > MaxFactLength {
+			continue
+		}
+		extracted = append(extracted, candidate)
+		if len(extracted) == MaxExtractedFacts {
+			break
+		}
+	}
+	return extracted
+}
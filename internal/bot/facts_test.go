@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/facts"
+)
+
+// newFactsTestBot returns a bot with an in-memory fact store
+func newFactsTestBot(t *testing.T) (*Bot, *mockDiscordSession, *mockAIClient) {
+	t.Helper()
+	store, err := facts.NewStore("", MaxFactsPerGuild)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	session := &mockDiscordSession{}
+	mockAI := &mockAIClient{}
+	return &Bot{
+		session:   session,
+		aiClient:  mockAI,
+		facts:     store,
+		commands:  mustCommandRegistry(t),
+		cooldowns: newCooldownTracker(),
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}, session, mockAI
+}
+
+func guildMessage(author *discordgo.User, mentions ...*discordgo.User) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		GuildID:   "guild",
+		ChannelID: "channel",
+		Author:    author,
+		Mentions:  mentions,
+	}}
+}
+
+func TestHandleRemember(t *testing.T) {
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+	erin := &discordgo.User{ID: "2", Username: "erin"}
+
+	tests := []struct {
+		name        string
+		author      *discordgo.User
+		mentions    []*discordgo.User
+		args        []string
+		wantText    string
+		wantSubject string
+		wantStatus  string
+	}{
+		{
+			name:        "about yourself is approved",
+			author:      dave,
+			args:        []string{"I'm", "a", "Jets", "fan"},
+			wantText:    "I'm a Jets fan",
+			wantSubject: "1",
+			wantStatus:  facts.StatusApproved,
+		},
+		{
+			name:        "about someone else needs review",
+			author:      erin,
+			mentions:    []*discordgo.User{dave},
+			args:        []string{"<@1>", "is", "a", "Jets", "fan"},
+			wantText:    "is a Jets fan",
+			wantSubject: "1",
+			wantStatus:  facts.StatusPending,
+		},
+		{
+			name:       "server lore needs review",
+			author:     erin,
+			args:       []string{"The", "server", "started", "in", "2019"},
+			wantText:   "The server started in 2019",
+			wantStatus: facts.StatusPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, _, _ := newFactsTestBot(t)
+
+			bot.dispatch(context.Background(), "remember", guildMessage(tt.author, tt.mentions...), tt.args)
+
+			got, ok := bot.facts.Get("guild", "1")
+			if !ok {
+				t.Fatal("fact was not stored")
+			}
+			if got.Text != tt.wantText || got.SubjectID != tt.wantSubject || got.Status != tt.wantStatus {
+				t.Errorf("fact = %+v, want text %q, subject %q, status %q", got, tt.wantText, tt.wantSubject, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestFactCommandPermissions(t *testing.T) {
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+	erin := &discordgo.User{ID: "2", Username: "erin"}
+	mallory := &discordgo.User{ID: "3", Username: "mallory"}
+
+	bot, session, _ := newFactsTestBot(t)
+	fact, err := bot.facts.Add("guild", facts.Fact{Text: "is a Jets fan", SubjectID: dave.ID, AuthorID: erin.ID, Status: facts.StatusPending})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// Reviewing needs Manage Messages, which the mock session never grants
+	bot.dispatch(context.Background(), "review", guildMessage(mallory), []string{"approve", fact.ID})
+	if got, _ := bot.facts.Get("guild", fact.ID); got.Status != facts.StatusPending {
+		t.Errorf("status after unauthorized review = %q, want pending", got.Status)
+	}
+
+	// Strangers can't forget it, but the person it is about can
+	bot.dispatch(context.Background(), "forget", guildMessage(mallory), []string{fact.ID})
+	if _, ok := bot.facts.Get("guild", fact.ID); !ok {
+		t.Error("fact forgotten by an unrelated member")
+	}
+	bot.dispatch(context.Background(), "forget", guildMessage(dave), []string{fact.ID})
+	if _, ok := bot.facts.Get("guild", fact.ID); ok {
+		t.Error("fact not forgotten by its subject")
+	}
+
+	if !strings.Contains(strings.Join(session.sentMessages, "\n"), "Forgotten: is a Jets fan") {
+		t.Errorf("sent %q, want a confirmation", session.sentMessages)
+	}
+}
+
+func TestAskIncludesRelevantFacts(t *testing.T) {
+	bot, _, mockAI := newFactsTestBot(t)
+	for _, fact := range []facts.Fact{
+		{Text: "is a Jets fan", SubjectID: "1", SubjectName: "dave", Status: facts.StatusApproved},
+		{Text: "thinks the Jets will win it all", SubjectID: "2", SubjectName: "erin", Status: facts.StatusPending},
+		{Text: "loves sushi", SubjectID: "4", SubjectName: "fay", Status: facts.StatusApproved},
+	} {
+		if _, err := bot.facts.Add("guild", fact); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	m := guildMessage(&discordgo.User{ID: "3", Username: "gus"})
+	bot.handleAsk(context.Background(), &commandRequest{m: m, args: []string{"how", "are", "the", "Jets", "doing?"}, provider: ai.ProviderGrok})
+
+	if len(mockAI.conversations) != 1 {
+		t.Fatalf("Chat called %d times, want 1", len(mockAI.conversations))
+	}
+	turns := mockAI.conversations[0]
+	if len(turns) != 2 {
+		t.Fatalf("turns = %+v, want facts and the question", turns)
+	}
+	factTurn := turns[0].Content
+	if !strings.Contains(factTurn, "(about dave) is a Jets fan") {
+		t.Errorf("fact turn %q missing the relevant fact", factTurn)
+	}
+	if strings.Contains(factTurn, "erin") || strings.Contains(factTurn, "sushi") {
+		t.Errorf("fact turn %q includes pending or irrelevant facts", factTurn)
+	}
+	if !strings.Contains(factTurn, "<untrusted_data") {
+		t.Error("facts are not delimited as untrusted data")
+	}
+}
+
+func TestParseExtractedFacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+	}{
+		{name: "plain array", response: `["is a Jets fan", "lives in Boston"]`, want: []string{"is a Jets fan", "lives in Boston"}},
+		{name: "code fence", response: "```json\n[\"works as a nurse\"]\n```", want: []string{"works as a nurse"}},
+		{name: "empty", response: "[]", want: nil},
+		{name: "not json", response: "No facts here.", want: nil},
+		{name: "blank entries dropped", response: `["", "  plays guitar "]`, want: []string{"plays guitar"}},
+		{name: "capped", response: `["a1", "b2", "c3", "d4"]`, want: []string{"a1", "b2", "c3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseExtractedFacts(tt.response); !slices.Equal(got, tt.want) {
+				t.Errorf("parseExtractedFacts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,11 @@ package bot
 
 import (
 	"context"
-	"fmt"
 	"math"
+	"mime"
+	"regexp"
 	"strings"
 	"time"
-
-	"github.com/bwmarrin/discordgo"
 )
 
 // sendLongResponse sends responses broken up into natural chunks with human-like timing
@@ -51,10 +50,15 @@ func (b *Bot) sendChunkWithDelay(ctx context.Context, channelID, chunk string, a
 		// Discord typing indicator lasts ~10 seconds, so we trigger it periodically
 		go b.showTypingIndicator(ctx, channelID, delay)
 
-		time.Sleep(delay)
+		// A shutdown that runs out of time cancels ctx; the rest of the response is dropped
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
 	}
 
-	_, err := b.session.ChannelMessageSend(channelID, chunk)
+	_, err := b.send(ctx, channelID, chunk)
 	if err != nil {
 		b.logger.ErrorContext(ctx, "failed to send message chunk",
 			"channel_id", channelID,
@@ -156,50 +160,51 @@ func (b *Bot) splitLongChunk(chunk string) []string {
 	return subChunks
 }
 
-// formatChannelHistory fetches and formats recent messages
-func (b *Bot) formatChannelHistory(ctx context.Context, channelID string, numMessages int) (string, error) {
-	messages, err := b.session.ChannelMessages(channelID, numMessages, "", "", "")
-	if err != nil {
-		return "", fmt.Errorf("failed to fetch channel messages: %w", err)
+// sendList sends a header followed by lines, packing as many lines into each message as
+// Discord's length limit allows
+func (b *Bot) sendList(ctx context.Context, channelID, header string, lines []string) {
+	current := header
+	for _, line := range lines {
+		if len(current)+len(line)+1 > MaxDiscordMessageLength {
+			b.send(ctx, channelID, current)
+			current = ""
+		}
+		if current != "" {
+			current += "\n"
+		}
+		current += line
 	}
-
-	// Reverse the messages to show oldest first
-	var formatted []string
-	for i := len(messages) - 1; i >= 0; i-- {
-		msg := messages[i]
-		displayName := getDisplayName(b.session, msg)
-		formatted = append(formatted, fmt.Sprintf("%s: %s", displayName, msg.Content))
+	if current != "" {
+		b.send(ctx, channelID, current)
 	}
-
-	return strings.Join(formatted, "\n"), nil
 }
 
-// getDisplayName retrieves the display name for a message author
-func getDisplayName(session interface {
-	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
-}, msg *discordgo.Message) string {
-	displayName := msg.Author.Username
-	if msg.GuildID != "" {
-		member, err := session.GuildMember(msg.GuildID, msg.Author.ID)
-		if err == nil && member.Nick != "" {
-			displayName = member.Nick
-		}
+// formatChannelHistory fetches and formats the messages q selects, oldest first
+func (b *Bot) formatChannelHistory(ctx context.Context, q historyQuery, guildID string) ([]string, error) {
+	messages, err := b.fetchHistory(ctx, q)
+	if err != nil {
+		return nil, err
 	}
-	return displayName
+	return b.formatHistory(ctx, guildID, messages), nil
 }
 
-// extractProviderAndArgs extracts provider from arguments and returns remaining args
-func extractProviderAndArgs(args []string, defaultProvider string) (string, []string) {
-	provider := defaultProvider
-	if len(args) > 0 {
-		lower := strings.ToLower(args[0])
-		// Check if first arg is a known provider
-		if lower == "grok" || lower == "openai" {
-			provider = lower
-			args = args[1:]
-		}
+// urlPattern matches http and https links in message content
+var urlPattern = regexp.MustCompile(`https?://[^\s<>]+`)
+
+// extractFirstURL returns the first link in text, or an empty string if there is none.
+// Discord's <url> embed-suppression brackets and trailing punctuation are stripped.
+func extractFirstURL(text string) string {
+	match := urlPattern.FindString(text)
+	return strings.TrimRight(match, ".,;:!?)\"'>")
+}
+
+// isHTMLContentType reports whether a Content-Type header describes an HTML document
+func isHTMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
 	}
-	return provider, args
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
 }
 
 // providerDisplayName returns a formatted display name for a provider
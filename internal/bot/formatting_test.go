@@ -2,12 +2,22 @@ package bot
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/redact"
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
 )
 
 func TestSendLongResponse(t *testing.T) {
@@ -109,10 +119,27 @@ func TestSendLongResponse(t *testing.T) {
 
 // mockAIClient is a mock AI client for testing
 type mockAIClient struct {
-	messageBreaks []string
+	// mu guards the recorded calls, which summaries make concurrently
+	mu             sync.Mutex
+	messageBreaks  []string
+	prompts        []string
+	systemMessages []string
+	conversations  [][]ai.ChatMessage
+	// embedded records every text passed to Embed
+	embedded []string
 }
 
 func (m *mockAIClient) AskClient(ctx context.Context, prompt, systemMessage, model, provider string, maxTokens int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts = append(m.prompts, prompt)
+	m.systemMessages = append(m.systemMessages, systemMessage)
+	return "mock response", nil
+}
+
+func (m *mockAIClient) Chat(ctx context.Context, messages []ai.ChatMessage, systemMessage, model, provider string, maxTokens int) (string, error) {
+	m.conversations = append(m.conversations, messages)
+	m.systemMessages = append(m.systemMessages, systemMessage)
 	return "mock response", nil
 }
 
@@ -124,6 +151,27 @@ func (m *mockAIClient) ImageOpinionGrok(ctx context.Context, imageURL, systemMes
 	return "mock grok opinion", nil
 }
 
+func (m *mockAIClient) Moderate(ctx context.Context, text string) (*ai.ModerationResult, error) {
+	return &ai.ModerationResult{}, nil
+}
+
+// Embed returns bag-of-words vectors, so texts sharing words are similar
+func (m *mockAIClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	m.embedded = append(m.embedded, texts...)
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, 64)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			word = strings.Trim(word, ".,!?:")
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%64]++
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
 func (m *mockAIClient) SuggestMessageBreaks(ctx context.Context, message string) ([]string, error) {
 	if len(m.messageBreaks) > 0 {
 		return m.messageBreaks, nil
@@ -135,6 +183,31 @@ func (m *mockAIClient) SuggestMessageBreaks(ctx context.Context, message string)
 // mockDiscordSession is a mock implementation for testing
 type mockDiscordSession struct {
 	sentMessages []string
+	// sentChannels records the channel of every ChannelMessageSend call
+	sentChannels []string
+	followups    []string
+	embeds       []*discordgo.MessageEmbed
+	history      []*discordgo.Message
+	// messages holds messages retrievable by ID with ChannelMessage
+	messages map[string]*discordgo.Message
+	replies  []*discordgo.MessageReference
+	// edits records the new content of every edited message
+	edits []string
+	// nicknames are returned by GuildMember, which counts its calls in memberLookups
+	nicknames     map[string]string
+	memberLookups int
+	// memberErr makes GuildMember fail, as when Discord can't be reached
+	memberErr error
+	// permissions are returned by UserChannelPermissions, per channel
+	permissions map[string]int64
+	// historyReads records the channel of every ChannelMessages call
+	historyReads []string
+	// channelGuildID is the guild of every channel returned by Channel
+	channelGuildID string
+	// dmClosed makes UserChannelCreate fail, as for members who don't accept DMs
+	dmClosed bool
+	// responses records every initial interaction response
+	responses []*discordgo.InteractionResponse
 }
 
 func (m *mockDiscordSession) Open() error {
@@ -151,6 +224,7 @@ func (m *mockDiscordSession) User(userID string, options ...discordgo.RequestOpt
 
 func (m *mockDiscordSession) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
 	m.sentMessages = append(m.sentMessages, content)
+	m.sentChannels = append(m.sentChannels, channelID)
 	return &discordgo.Message{
 		ID:        "msg-id",
 		ChannelID: channelID,
@@ -158,21 +232,124 @@ func (m *mockDiscordSession) ChannelMessageSend(channelID string, content string
 	}, nil
 }
 
+func (m *mockDiscordSession) ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m.replies = append(m.replies, reference)
+	return m.ChannelMessageSend(channelID, content)
+}
+
+func (m *mockDiscordSession) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m.edits = append(m.edits, content)
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Content: content}, nil
+}
+
+func (m *mockDiscordSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m.embeds = append(m.embeds, embed)
+	return &discordgo.Message{ID: "msg-id", ChannelID: channelID, Embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
+
 func (m *mockDiscordSession) ChannelTyping(channelID string, options ...discordgo.RequestOption) error {
 	// Mock typing - do nothing in tests
 	return nil
 }
 
 func (m *mockDiscordSession) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
-	return []*discordgo.Message{}, nil
+	m.historyReads = append(m.historyReads, channelID)
+	history := m.history
+	if beforeID != "" {
+		// history is newest first, so older messages follow beforeID. It needn't be a
+		// message: snowflakes made from a time page back from that time.
+		start := slices.IndexFunc(history, func(msg *discordgo.Message) bool { return msg.ID == beforeID }) + 1
+		if start == 0 {
+			start = slices.IndexFunc(history, func(msg *discordgo.Message) bool { return snowflakeBefore(msg.ID, beforeID) })
+		}
+		if start < 0 {
+			start = len(history)
+		}
+		history = history[start:]
+	}
+	if afterID != "" {
+		// Discord returns the oldest messages after afterID, still newest first
+		for i, msg := range history {
+			if msg.ID == afterID {
+				history = history[max(0, i-limit):i]
+				break
+			}
+		}
+	}
+	if limit < len(history) {
+		return history[:limit], nil
+	}
+	return history, nil
 }
 
 func (m *mockDiscordSession) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if msg, ok := m.messages[messageID]; ok {
+		return msg, nil
+	}
+	if m.messages != nil {
+		return nil, fmt.Errorf("unknown message %s", messageID)
+	}
 	return &discordgo.Message{ID: messageID, ChannelID: channelID}, nil
 }
 
+func (m *mockDiscordSession) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: channelID, GuildID: m.channelGuildID}, nil
+}
+
+func (m *mockDiscordSession) UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error) {
+	return m.permissions[channelID], nil
+}
+
+func (m *mockDiscordSession) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if m.dmClosed {
+		return nil, fmt.Errorf("cannot send messages to this user")
+	}
+	return &discordgo.Channel{ID: "dm-" + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
+
 func (m *mockDiscordSession) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
-	return &discordgo.Member{User: &discordgo.User{ID: userID}}, nil
+	m.memberLookups++
+	if m.memberErr != nil {
+		return nil, m.memberErr
+	}
+	return &discordgo.Member{User: &discordgo.User{ID: userID}, Nick: m.nicknames[userID]}, nil
+}
+
+func (m *mockDiscordSession) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	return commands, nil
+}
+
+func (m *mockDiscordSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	m.responses = append(m.responses, resp)
+	return nil
+}
+
+func (m *mockDiscordSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if newresp.Embeds != nil {
+		m.embeds = append(m.embeds, *newresp.Embeds...)
+	}
+	if newresp.Content == nil {
+		return &discordgo.Message{ID: "response-id"}, nil
+	}
+	m.sentMessages = append(m.sentMessages, *newresp.Content)
+	return &discordgo.Message{ID: "response-id", Content: *newresp.Content}, nil
+}
+
+func (m *mockDiscordSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	m.embeds = append(m.embeds, data.Embeds...)
+	if data.Content == "" {
+		return &discordgo.Message{ID: "followup-id"}, nil
+	}
+	m.sentMessages = append(m.sentMessages, data.Content)
+	m.followups = append(m.followups, data.Content)
+	return &discordgo.Message{ID: "followup-id", Content: data.Content}, nil
+}
+
+func (m *mockDiscordSession) FollowupMessageEdit(interaction *discordgo.Interaction, messageID string, data *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if data.Content != nil {
+		m.edits = append(m.edits, *data.Content)
+	}
+	return &discordgo.Message{ID: messageID}, nil
 }
 
 func (m *mockDiscordSession) AddHandler(handler interface{}) func() {
@@ -182,3 +359,53 @@ func (m *mockDiscordSession) AddHandler(handler interface{}) func() {
 func (m *mockDiscordSession) GetState() *discordgo.State {
 	return &discordgo.State{}
 }
+
+func TestFormatChannelHistoryRedactsPII(t *testing.T) {
+	redactor, err := redact.NewRedactor(nil)
+	if err != nil {
+		t.Fatalf("NewRedactor() error = %v", err)
+	}
+
+	history := []*discordgo.Message{
+		{Content: "text me at 617-555-0199", Author: &discordgo.User{ID: "1", Username: "dave"}},
+		{Content: "or email dave@example.com", Author: &discordgo.User{ID: "1", Username: "dave"}},
+	}
+
+	tests := []struct {
+		name        string
+		guildToggle string
+		wantPII     bool
+	}{
+		{name: "redacts by default", guildToggle: "", wantPII: false},
+		{name: "guild turned redaction off", guildToggle: "off", wantPII: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := settings.NewStore("")
+			if err != nil {
+				t.Fatalf("NewStore() error = %v", err)
+			}
+			store.UpdateGuild("guild", func(gs *settings.GuildSettings) { gs.PIIRedaction = tt.guildToggle })
+
+			bot := &Bot{
+				session:  &mockDiscordSession{history: history},
+				settings: store,
+				redactor: redactor,
+				config:   &config.Config{PIIRedaction: true},
+				logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			lines, err := bot.formatChannelHistory(context.Background(), historyQuery{channelID: "channel", limit: 10}, "guild")
+			if err != nil {
+				t.Fatalf("formatChannelHistory() error = %v", err)
+			}
+			got := strings.Join(lines, "\n")
+
+			hasPII := strings.Contains(got, "617-555-0199") || strings.Contains(got, "dave@example.com")
+			if hasPII != tt.wantPII {
+				t.Errorf("formatChannelHistory() = %q, want PII present = %v", got, tt.wantPII)
+			}
+		})
+	}
+}
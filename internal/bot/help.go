@@ -0,0 +1,230 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+)
+
+// handleHelp handles the !help command
+func (b *Bot) handleHelp(ctx context.Context, req *commandRequest) {
+	m := req.m
+	var name string
+	if fields := strings.Fields(req.text("command")); len(fields) > 0 {
+		name = strings.TrimLeft(fields[0], "!/")
+	}
+
+	if name == "" {
+		b.sendEmbed(ctx, m.ChannelID, b.commands.overviewEmbed())
+		return
+	}
+
+	cmd, ok := b.commands.lookup(name)
+	if !ok {
+		b.send(ctx, m.ChannelID, unknownCommandMessage(name, b.commands.suggest(name)))
+		return
+	}
+
+	b.sendEmbed(ctx, m.ChannelID, cmd.helpEmbed())
+}
+
+// overviewEmbed lists every command with its description, one field per category so
+// the overview stays within Discord's 25 field limit
+func (r *commandRegistry) overviewEmbed() *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title: "Coonbot commands",
+		Description: "Use `!help <command>` for arguments and examples. " +
+			"Every command also works as a slash command.",
+		Color: HelpEmbedColor,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Put %s or %s right after an AI command to pick the provider, e.g. !ask openai ...",
+				ai.ProviderGrok, ai.ProviderOpenAI),
+		},
+	}
+
+	var categories []string
+	lines := make(map[string][]string)
+	for _, cmd := range r.commands {
+		if _, seen := lines[cmd.category]; !seen {
+			categories = append(categories, cmd.category)
+		}
+		lines[cmd.category] = append(lines[cmd.category], fmt.Sprintf("`!%s` - %s", cmd.name, cmd.description))
+	}
+	for _, category := range categories {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  category,
+			Value: strings.Join(lines[category], "\n"),
+		})
+	}
+
+	return embed
+}
+
+// helpEmbed describes one command in detail
+func (c *command) helpEmbed() *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       "!" + c.name,
+		Description: c.description,
+		Color:       HelpEmbedColor,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Usage", Value: "`" + c.usage() + "`"},
+		},
+	}
+
+	if len(c.args) > 0 {
+		var lines []string
+		for _, arg := range c.args {
+			line := fmt.Sprintf("`--%s` - %s", arg.name, arg.description)
+			if len(arg.flagAliases) > 0 {
+				line = fmt.Sprintf("`--%s` (`--%s`) - %s", arg.name, strings.Join(arg.flagAliases, "`, `--"), arg.description)
+			}
+			if len(arg.choices) > 0 {
+				line += fmt.Sprintf(" (%s)", strings.Join(arg.choices, ", "))
+			}
+			if arg.required {
+				line += " *required*"
+			}
+			lines = append(lines, line)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Arguments", Value: strings.Join(lines, "\n")})
+	}
+
+	if c.defaultProvider != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Provider",
+			Value: fmt.Sprintf("%s by default. Add `%s` or `%s` to override.",
+				providerDisplayName(c.defaultProvider), ai.ProviderGrok, ai.ProviderOpenAI),
+		})
+	}
+
+	if len(c.aliases) > 0 {
+		aliases := make([]string, len(c.aliases))
+		for i, alias := range c.aliases {
+			aliases[i] = "`!" + alias + "`"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Aliases", Value: strings.Join(aliases, ", "), Inline: true})
+	}
+
+	if c.cooldown > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Cooldown", Value: c.cooldown.String(), Inline: true})
+	}
+
+	if c.permission != 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Requires", Value: permissionName(c.permission), Inline: true})
+	}
+
+	if len(c.examples) > 0 {
+		examples := make([]string, len(c.examples))
+		for i, example := range c.examples {
+			examples[i] = "`" + example + "`"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Examples", Value: strings.Join(examples, "\n")})
+	}
+
+	return embed
+}
+
+// suggest returns the command names closest to a misspelled one, nearest first
+func (r *commandRegistry) suggest(name string) []string {
+	name = strings.ToLower(name)
+
+	type candidate struct {
+		name     string
+		distance int
+		prefix   int
+	}
+
+	best := make(map[string]candidate)
+	for alias, cmd := range r.byName {
+		distance := editDistance(name, alias)
+		isPrefix := len(name) >= 2 && strings.HasPrefix(alias, name)
+		if distance > MaxSuggestionDistance && !isPrefix {
+			continue
+		}
+		// Someone typing the start of a command most likely wants that command
+		if isPrefix {
+			distance = 0
+		}
+		// Suggest the canonical name even when an alias was the closer match
+		if existing, ok := best[cmd.name]; !ok || distance < existing.distance {
+			best[cmd.name] = candidate{name: cmd.name, distance: distance, prefix: sharedPrefixLen(name, alias)}
+		}
+	}
+
+	candidates := make([]candidate, 0, len(best))
+	for _, c := range best {
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		if candidates[i].prefix != candidates[j].prefix {
+			return candidates[i].prefix > candidates[j].prefix
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < MaxCommandSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// unknownCommandMessage tells the user a command doesn't exist and offers suggestions
+func unknownCommandMessage(name string, suggestions []string) string {
+	msg := fmt.Sprintf("I don't know `!%s`.", name)
+	if len(suggestions) > 0 {
+		quoted := make([]string, len(suggestions))
+		for i, s := range suggestions {
+			quoted[i] = "`!" + s + "`"
+		}
+		msg += fmt.Sprintf(" Did you mean %s?", strings.Join(quoted, " or "))
+	}
+	return msg + " Try `!help` for the full list."
+}
+
+// editDistance returns the optimal string alignment distance between two strings:
+// insertions, deletions, substitutions and adjacent transpositions each cost one
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+// sharedPrefixLen returns how many leading runes two strings have in common
+func sharedPrefixLen(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	n := 0
+	for n < len(ra) && n < len(rb) && ra[n] == rb[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,163 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "roast", b: "roast", want: 0},
+		{a: "rost", b: "roast", want: 1},
+		{a: "raost", b: "roast", want: 1},
+		{a: "mots", b: "roast", want: 3},
+		{a: "", b: "ask", want: 3},
+		{a: "kitten", b: "sitting", want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandRegistrySuggest(t *testing.T) {
+	registry := mustCommandRegistry(t)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "transposed letters", input: "raost", want: []string{"roast"}},
+		{name: "missing underscore", input: "whowin", want: []string{"who_won"}},
+		{name: "prefix", input: "opin", want: []string{"opinion"}},
+		{name: "case insensitive", input: "PIGN", want: []string{"ping"}},
+		{name: "nothing close", input: "xylophone", want: nil},
+		{name: "single letter is not a prefix match", input: "o", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := registry.suggest(tt.input)
+			if len(tt.want) == 0 {
+				if len(got) != 0 {
+					t.Errorf("suggest(%q) = %v, want none", tt.input, got)
+				}
+				return
+			}
+			if len(got) == 0 || got[0] != tt.want[0] {
+				t.Errorf("suggest(%q) = %v, want %v first", tt.input, got, tt.want[0])
+			}
+			if len(got) > MaxCommandSuggestions {
+				t.Errorf("suggest(%q) returned %d suggestions, max %d", tt.input, len(got), MaxCommandSuggestions)
+			}
+		})
+	}
+}
+
+func TestHandleHelp(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantTitle   string
+		wantMessage string
+	}{
+		{name: "overview", wantTitle: "Coonbot commands"},
+		{name: "single command", args: []string{"roast"}, wantTitle: "!roast"},
+		{name: "alias and prefix", args: []string{"!whowon"}, wantTitle: "!who_won"},
+		{name: "misspelled", args: []string{"rost"}, wantMessage: "Did you mean `!roast`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockDiscordSession{}
+			bot := &Bot{
+				session:   session,
+				commands:  mustCommandRegistry(t),
+				cooldowns: newCooldownTracker(),
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			m := &discordgo.MessageCreate{Message: &discordgo.Message{ChannelID: "channel", Author: &discordgo.User{ID: "user"}}}
+
+			bot.dispatch(context.Background(), "help", m, tt.args)
+
+			if tt.wantTitle != "" {
+				if len(session.embeds) != 1 || session.embeds[0].Title != tt.wantTitle {
+					t.Fatalf("embeds = %v, want one titled %q", session.embeds, tt.wantTitle)
+				}
+			}
+			if tt.wantMessage != "" && !strings.Contains(strings.Join(session.sentMessages, "\n"), tt.wantMessage) {
+				t.Errorf("sent %q, want a message containing %q", session.sentMessages, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestOverviewEmbedListsEveryCommand(t *testing.T) {
+	registry := mustCommandRegistry(t)
+	embed := registry.overviewEmbed()
+
+	// Discord rejects embeds with more than 25 fields or field values over 1024 characters
+	if len(embed.Fields) > 25 {
+		t.Errorf("overview has %d fields, Discord allows 25", len(embed.Fields))
+	}
+	var values []string
+	for _, field := range embed.Fields {
+		if len(field.Value) > 1024 {
+			t.Errorf("overview field %q is %d characters, Discord allows 1024", field.Name, len(field.Value))
+		}
+		values = append(values, field.Value)
+	}
+
+	listed := strings.Join(values, "\n")
+	for _, cmd := range registry.commands {
+		if cmd.category == "" {
+			t.Errorf("!%s has no category", cmd.name)
+		}
+		if !strings.Contains(listed, "`!"+cmd.name+"` - "+cmd.description) {
+			t.Errorf("overview doesn't list !%s", cmd.name)
+		}
+	}
+}
+
+func TestDispatchSuggestsOnlyForTypos(t *testing.T) {
+	tests := []struct {
+		command   string
+		wantReply bool
+	}{
+		{command: "tdlr", wantReply: true},
+		{command: "play", wantReply: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			session := &mockDiscordSession{}
+			bot := &Bot{
+				session:   session,
+				commands:  mustCommandRegistry(t),
+				cooldowns: newCooldownTracker(),
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			m := &discordgo.MessageCreate{Message: &discordgo.Message{ChannelID: "channel", Author: &discordgo.User{ID: "user"}}}
+
+			bot.dispatch(context.Background(), tt.command, m, nil)
+
+			if got := len(session.sentMessages) > 0; got != tt.wantReply {
+				t.Errorf("replied = %v, want %v (sent %q)", got, tt.wantReply, session.sentMessages)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/cmdparse"
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+// historyQuery selects recent messages from a channel
+type historyQuery struct {
+	channelID string
+	// limit caps how many messages are read; it is clamped to MaxHistoryMessages
+	limit int
+	// since stops the read at the first message older than it, when set
+	since time.Time
+	// beforeID starts the read at the first message older than it instead of the newest
+	beforeID string
+	// aroundID centres the read on a message: up to half the limit is read from after
+	// it, then the message itself and older messages for the rest
+	aroundID string
+	// statusChannelID receives progress updates for reads that take several requests;
+	// empty keeps the read quiet
+	statusChannelID string
+}
+
+// historyTarget is the conversation an analysis command reads: the channel it was sent
+// in by default, another channel of the server, or the messages around a linked one
+type historyTarget struct {
+	channelID string
+	// aroundID is the linked message to read around, or empty for the latest messages
+	aroundID string
+	// since and until bound the messages by when they were sent; zero means unbounded
+	since, until time.Time
+	// window describes the time bounds for replies and prompts, e.g. "in the last 2h"
+	window string
+}
+
+// resolveHistoryTarget reads the target from the command's channel, message and time
+// window arguments; a message link decides the channel when both are given. Other
+// channels must be in the same server and readable by the invoker, otherwise this
+// explains why and returns false.
+func (b *Bot) resolveHistoryTarget(ctx context.Context, req *commandRequest) (historyTarget, bool) {
+	m := req.m
+	target := historyTarget{channelID: m.ChannelID}
+	if channelID, ok := req.value("channel"); ok {
+		target.channelID = channelID
+	}
+	if link, ok := req.value("message"); ok {
+		guildID, channelID, messageID, _ := cmdparse.MessageLink(link)
+		if guildID != m.GuildID && (guildID != "@me" || m.GuildID != "") {
+			b.send(ctx, m.ChannelID, "That message is in another server.")
+			return target, false
+		}
+		target.channelID, target.aroundID = channelID, messageID
+	}
+	if !b.resolveWindow(ctx, req, &target) {
+		return target, false
+	}
+	if target.channelID == m.ChannelID {
+		return target, true
+	}
+
+	if channel, err := b.lookupChannel(target.channelID); m.GuildID == "" || err != nil || channel.GuildID != m.GuildID {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("I can't find <#%s> in this server.", target.channelID))
+		return target, false
+	}
+	if !b.canRead(m.Author.ID, target.channelID) {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("You can't read <#%s>.", target.channelID))
+		return target, false
+	}
+	return target, true
+}
+
+// resolveWindow reads the since, from and to arguments into the target's time bounds.
+// Times of day are in the bot's time zone; a --from time of day is its latest
+// occurrence before --to, so "--from 22:00 --to 01:00" spans midnight.
+func (b *Bot) resolveWindow(ctx context.Context, req *commandRequest, target *historyTarget) bool {
+	m := req.m
+	sinceValue, hasSince := req.value("since")
+	fromValue, hasFrom := req.value("from")
+	toValue, hasTo := req.value("to")
+	if !hasSince && !hasFrom && !hasTo {
+		return true
+	}
+	fail := func(reply string) bool {
+		b.send(ctx, m.ChannelID, reply)
+		return false
+	}
+	if target.aroundID != "" {
+		return fail("Pick either a message link or a time window, not both.")
+	}
+	if hasSince && (hasFrom || hasTo) {
+		return fail("Use either --since or --from and --to, not both.")
+	}
+
+	now := b.now()
+	if hasSince {
+		d, err := cmdparse.ParseDuration("since", sinceValue)
+		if err != nil {
+			return fail(capitalize(err.Error()) + ".")
+		}
+		target.since = now.Add(-d)
+		target.window = "in the last " + sinceValue
+		return true
+	}
+
+	ref := now
+	if hasTo {
+		until, err := cmdparse.ParseTime("to", toValue, now)
+		if err != nil {
+			return fail(capitalize(err.Error()) + ".")
+		}
+		target.until, ref = until, until
+	}
+	if hasFrom {
+		since, err := cmdparse.ParseTime("from", fromValue, ref)
+		if err != nil {
+			return fail(capitalize(err.Error()) + ".")
+		}
+		target.since = since
+	}
+
+	switch {
+	case hasFrom && hasTo:
+		if !target.since.Before(target.until) {
+			return fail("--from has to be before --to.")
+		}
+		target.window = fmt.Sprintf("between %s and %s", windowTime(target.since, now), windowTime(target.until, now))
+	case hasFrom:
+		target.window = "since " + windowTime(target.since, now)
+	default:
+		target.window = "before " + windowTime(target.until, now)
+	}
+	return true
+}
+
+// windowTime formats a window bound, leaving out the date for times today
+func windowTime(t, now time.Time) string {
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04 MST")
+	}
+	return t.Format("Jan 2 15:04 MST")
+}
+
+// now returns the current time in the bot's time zone
+func (b *Bot) now() time.Time {
+	if b.location == nil {
+		return time.Now()
+	}
+	return time.Now().In(b.location)
+}
+
+// messageCount returns the messages argument, or fallback when it wasn't given. Time
+// windows read every message in the window, up to the ceiling, unless a count is given.
+func (t historyTarget) messageCount(req *commandRequest, fallback int) int {
+	if t.window != "" {
+		fallback = MaxHistoryMessages
+	}
+	return req.intValue("messages", fallback)
+}
+
+// query selects up to limit messages from the target, reporting progress in the
+// channel the command was sent in. The end of a time window becomes the snowflake the
+// read pages back from.
+func (t historyTarget) query(m *discordgo.MessageCreate, limit int) historyQuery {
+	q := historyQuery{
+		channelID:       t.channelID,
+		limit:           limit,
+		since:           t.since,
+		aroundID:        t.aroundID,
+		statusChannelID: m.ChannelID,
+	}
+	if !t.until.IsZero() {
+		q.beforeID = snowflake.At(t.until)
+	}
+	return q
+}
+
+// where names the target channel for system prompts
+func (t historyTarget) where(m *discordgo.MessageCreate) string {
+	if t.channelID == m.ChannelID {
+		return "this channel"
+	}
+	return "another channel of this server"
+}
+
+// describe names n messages read from the target for system prompts
+func (t historyTarget) describe(m *discordgo.MessageCreate, n int) string {
+	if t.window != "" {
+		return fmt.Sprintf("the messages sent in %s %s", t.where(m), t.window)
+	}
+	if t.aroundID != "" {
+		return fmt.Sprintf("%d messages around a linked message in %s", n, t.where(m))
+	}
+	return fmt.Sprintf("the last %d messages in %s", n, t.where(m))
+}
+
+// fetchHistory returns messages matching q, newest first. The archive answers as much
+// as it can, then Discord is paged backwards with beforeID, 100 messages per request,
+// until the limit, the time bound or the start of the channel is reached.
+func (b *Bot) fetchHistory(ctx context.Context, q historyQuery) ([]*discordgo.Message, error) {
+	limit := min(q.limit, MaxHistoryMessages)
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	var messages []*discordgo.Message
+	progress := &historyProgress{b: b, channelID: q.statusChannelID, total: limit, capped: q.limit > MaxHistoryMessages}
+	defer func() { progress.finish(ctx, len(messages)) }()
+
+	var err error
+	if q.aroundID != "" {
+		messages, err = b.fetchAround(ctx, q, limit, progress)
+	} else {
+		messages, err = b.fetchBefore(ctx, q, limit, progress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// fetchBefore reads up to limit messages older than q.beforeID, newest first
+func (b *Bot) fetchBefore(ctx context.Context, q historyQuery, limit int, progress *historyProgress) ([]*discordgo.Message, error) {
+	within := func(msg *discordgo.Message) bool {
+		return q.since.IsZero() || !msg.Timestamp.Before(q.since)
+	}
+
+	var messages []*discordgo.Message
+	beforeID := q.beforeID
+	if b.archive != nil {
+		archived, err := b.archive.Messages(q.channelID, limit, beforeID)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to read message archive", "channel_id", q.channelID, "error", err)
+			archived = nil
+		}
+		for _, msg := range archived {
+			if !within(msg) {
+				return messages, nil
+			}
+			messages = append(messages, msg)
+		}
+		if len(messages) == limit {
+			return messages, nil
+		}
+		if len(archived) > 0 {
+			beforeID = archived[len(archived)-1].ID
+		}
+	}
+
+	for pages := 0; len(messages) < limit; pages++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Only reads that need another request are worth a status message
+		if pages > 0 {
+			progress.update(ctx, progress.read+len(messages))
+		}
+
+		pageSize := min(MaxMessagesPerRequest, limit-len(messages))
+		page, err := b.session.ChannelMessages(q.channelID, pageSize, beforeID, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch channel messages: %w", err)
+		}
+		for _, msg := range page {
+			if !within(msg) {
+				return messages, nil
+			}
+			messages = append(messages, msg)
+		}
+		if len(page) < pageSize {
+			break
+		}
+
+		beforeID = page[len(page)-1].ID
+	}
+
+	return messages, nil
+}
+
+// fetchAround reads the messages around q.aroundID, newest first. Messages after it are
+// paged forwards with afterID; the linked message and older ones come from fetchBefore,
+// which also covers any messages the newer side is short of.
+func (b *Bot) fetchAround(ctx context.Context, q historyQuery, limit int, progress *historyProgress) ([]*discordgo.Message, error) {
+	target, err := b.session.ChannelMessage(q.channelID, q.aroundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the linked message: %w", err)
+	}
+
+	var newer []*discordgo.Message
+	for afterID := q.aroundID; len(newer) < (limit-1)/2; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pageSize := min(MaxMessagesPerRequest, (limit-1)/2-len(newer))
+		page, err := b.session.ChannelMessages(q.channelID, pageSize, "", afterID, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch channel messages: %w", err)
+		}
+		// Each page holds the oldest messages after afterID, newest first
+		newer = append(page, newer...)
+		if len(page) < pageSize {
+			break
+		}
+		afterID = page[0].ID
+	}
+
+	progress.read = len(newer) + 1
+	older, err := b.fetchBefore(ctx, historyQuery{
+		channelID: q.channelID,
+		since:     q.since,
+		beforeID:  q.aroundID,
+	}, limit-len(newer)-1, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := append(newer, target)
+	return append(messages, older...), nil
+}
+
+// historyProgress keeps one status message up to date while a long read pages through
+// history, so users know the bot hasn't stalled
+type historyProgress struct {
+	b         *Bot
+	channelID string
+	total     int
+	// capped means the requested count was cut down to MaxHistoryMessages
+	capped bool
+	// read counts messages gathered before the current backwards pass, such as the
+	// newer side of a read around a message
+	read int
+
+	status  *discordgo.Message
+	updated time.Time
+}
+
+// update reports how many messages have been read, at most once per HistoryProgressInterval
+func (p *historyProgress) update(ctx context.Context, read int) {
+	if p.channelID == "" || time.Since(p.updated) < HistoryProgressInterval {
+		return
+	}
+	p.updated = time.Now()
+	p.show(ctx, fmt.Sprintf("Reading message history... %d/%d", read, p.total))
+}
+
+// finish replaces the status message with the final count. Reads cut down to the ceiling
+// always say so, even when they were quick.
+func (p *historyProgress) finish(ctx context.Context, read int) {
+	if p.channelID == "" || (p.status == nil && !p.capped) {
+		return
+	}
+	content := fmt.Sprintf("Read %d messages.", read)
+	if p.capped {
+		content = fmt.Sprintf("Read %d messages, the most I read for one command.", read)
+	}
+	p.show(ctx, content)
+}
+
+// show posts the status message, or edits it once it exists. Background jobs show
+// progress in their own status message instead.
+func (p *historyProgress) show(ctx context.Context, content string) {
+	if p.b.reportJobProgress(ctx, content) {
+		return
+	}
+	if p.status == nil {
+		status, err := p.b.send(ctx, p.channelID, content)
+		if err != nil {
+			p.b.logger.ErrorContext(ctx, "failed to send history progress", "error", err)
+			return
+		}
+		p.status = status
+		return
+	}
+	if _, err := p.b.edit(ctx, p.channelID, p.status.ID, content); err != nil {
+		p.b.logger.ErrorContext(ctx, "failed to update history progress", "error", err)
+	}
+}
@@ -0,0 +1,307 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+)
+
+func TestFetchHistory(t *testing.T) {
+	tests := []struct {
+		name      string
+		available int
+		query     historyQuery
+		want      int
+		// wantStatus is the final progress message, if one is expected
+		wantStatus string
+	}{
+		{name: "single page", available: 250, query: historyQuery{limit: 40}, want: 40},
+		{name: "pages past 100", available: 250, query: historyQuery{limit: 230}, want: 230, wantStatus: "Read 230 messages."},
+		{name: "start of channel", available: 150, query: historyQuery{limit: 500}, want: 150, wantStatus: "Read 150 messages."},
+		{name: "time bound", available: 250, query: historyQuery{limit: 500, since: time.Now().Add(-119*time.Minute - 30*time.Second)}, want: 120, wantStatus: "Read 120 messages."},
+		{
+			name:       "hard ceiling",
+			available:  MaxHistoryMessages + 50,
+			query:      historyQuery{limit: 5000},
+			want:       MaxHistoryMessages,
+			wantStatus: "the most I read for one command",
+		},
+		{name: "nothing requested", available: 10, query: historyQuery{limit: 0}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockDiscordSession{history: testHistory(tt.available)}
+			bot := &Bot{session: session, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+			q := tt.query
+			q.channelID, q.statusChannelID = "channel", "channel"
+			got, err := bot.fetchHistory(context.Background(), q)
+			if err != nil {
+				t.Fatalf("fetchHistory() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("fetchHistory() returned %d messages, want %d", len(got), tt.want)
+			}
+			for i := 1; i < len(got); i++ {
+				if !snowflakeBefore(got[i].ID, got[i-1].ID) {
+					t.Fatalf("messages %d and %d are out of order or repeated", i-1, i)
+				}
+			}
+
+			// The status message is posted once, then edited
+			updates := append(session.sentMessages, session.edits...)
+			if tt.wantStatus == "" {
+				if len(updates) != 0 {
+					t.Errorf("unexpected progress messages %q", updates)
+				}
+				return
+			}
+			if len(session.sentMessages) != 1 || !strings.HasPrefix(session.sentMessages[0], "Reading message history...") {
+				t.Errorf("sent %q, want one progress message", session.sentMessages)
+			}
+			if final := updates[len(updates)-1]; !strings.Contains(final, tt.wantStatus) {
+				t.Errorf("final status = %q, want it to contain %q", final, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestFetchHistoryContinuesPastArchive(t *testing.T) {
+	bot, session := newArchiveTestBot(t, &config.Config{ArchiveBackfill: 150})
+	history := testHistory(400)
+
+	// Archive the newest 150 messages, then make Discord serve everything
+	session.history = history
+	if err := bot.backfillChannel(context.Background(), "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+	session.history = history
+
+	got, err := bot.fetchHistory(context.Background(), historyQuery{channelID: "channel", limit: 300})
+	if err != nil {
+		t.Fatalf("fetchHistory() error = %v", err)
+	}
+	if len(got) != 300 {
+		t.Fatalf("fetchHistory() returned %d messages, want 300", len(got))
+	}
+	for i, msg := range got {
+		if msg.ID != history[i].ID {
+			t.Fatalf("message %d = %s, want %s", i, msg.Content, history[i].Content)
+		}
+	}
+}
+
+func TestFetchHistoryEditsStatusInInteractions(t *testing.T) {
+	session := &mockDiscordSession{history: testHistory(300)}
+	bot := &Bot{session: session, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	ctx := withInteraction(context.Background(), &interactionResponder{interaction: &discordgo.Interaction{ID: "interaction"}})
+
+	if _, err := bot.fetchHistory(ctx, historyQuery{channelID: "channel", limit: 300, statusChannelID: "channel"}); err != nil {
+		t.Fatalf("fetchHistory() error = %v", err)
+	}
+	if len(session.edits) == 0 || session.edits[len(session.edits)-1] != "Read 300 messages." {
+		t.Errorf("edits = %q, want the final count", session.edits)
+	}
+}
+
+func TestFetchHistoryAround(t *testing.T) {
+	history := testHistory(300)
+
+	tests := []struct {
+		name   string
+		around int
+		limit  int
+		// want is the slice of history expected back
+		wantFrom, wantTo int
+	}{
+		{name: "centred", around: 150, limit: 50, wantFrom: 126, wantTo: 176},
+		{name: "older side fills in near the newest message", around: 10, limit: 250, wantFrom: 0, wantTo: 250},
+		{name: "just the message", around: 150, limit: 1, wantFrom: 150, wantTo: 151},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := history[tt.around]
+			session := &mockDiscordSession{history: history, messages: map[string]*discordgo.Message{target.ID: target}}
+			bot := &Bot{session: session, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+			got, err := bot.fetchHistory(context.Background(), historyQuery{channelID: "channel", limit: tt.limit, aroundID: target.ID})
+			if err != nil {
+				t.Fatalf("fetchHistory() error = %v", err)
+			}
+			want := history[tt.wantFrom:tt.wantTo]
+			if len(got) != len(want) {
+				t.Fatalf("fetchHistory() returned %d messages, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].ID != want[i].ID {
+					t.Fatalf("message %d = %s, want %s", i, got[i].Content, want[i].Content)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchHistoryAroundCapped(t *testing.T) {
+	history := testHistory(MaxHistoryMessages + 600)
+	target := history[1000]
+	session := &mockDiscordSession{history: history, messages: map[string]*discordgo.Message{target.ID: target}}
+	bot := &Bot{session: session, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	got, err := bot.fetchHistory(context.Background(), historyQuery{
+		channelID:       "channel",
+		limit:           MaxHistoryMessages + 500,
+		aroundID:        target.ID,
+		statusChannelID: "channel",
+	})
+	if err != nil {
+		t.Fatalf("fetchHistory() error = %v", err)
+	}
+	if len(got) != MaxHistoryMessages {
+		t.Fatalf("fetchHistory() returned %d messages, want %d", len(got), MaxHistoryMessages)
+	}
+	if got[0].ID != history[1].ID || got[len(got)-1].ID != history[MaxHistoryMessages].ID {
+		t.Errorf("fetchHistory() read %s to %s, want %s to %s", got[0].Content, got[len(got)-1].Content, history[1].Content, history[MaxHistoryMessages].Content)
+	}
+
+	// One status message, ending with the full count and the ceiling
+	want := fmt.Sprintf("Read %d messages, the most I read for one command.", MaxHistoryMessages)
+	updates := append(session.sentMessages, session.edits...)
+	if len(session.sentMessages) != 1 || updates[len(updates)-1] != want {
+		t.Errorf("status messages = %q, want one ending with %q", updates, want)
+	}
+}
+
+func TestHistoryTargets(t *testing.T) {
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+	linked := &discordgo.Message{ID: "42", ChannelID: "200", Author: dave, Content: "pineapple belongs on pizza"}
+	readable := int64(discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory)
+
+	tests := []struct {
+		name    string
+		command string
+		args    string
+		// wantRead is the channel history is read from, if any
+		wantRead string
+		// wantPrompt is expected in the system message or prompt
+		wantPrompt string
+		wantSent   string
+	}{
+		{name: "this channel", command: "opinion", args: "20", wantRead: "channel", wantPrompt: "the last 20 messages in this channel"},
+		{name: "another channel", command: "opinion", args: "<#200> 20", wantRead: "200", wantPrompt: "the last 20 messages in another channel"},
+		{name: "around a linked message", command: "who_won", args: "https://discord.com/channels/100/200/42", wantRead: "200", wantPrompt: "messages around a linked message"},
+		{name: "unreadable channel", command: "most", args: "<#300> funniest", wantSent: "You can't read <#300>."},
+		{name: "link to another server", command: "opinion", args: "https://discord.com/channels/999/200/42", wantSent: "That message is in another server."},
+		{name: "roast a linked message", command: "roast", args: "https://discord.com/channels/100/200/42", wantPrompt: "pineapple belongs on pizza"},
+		{name: "roast a message in an unreadable channel", command: "roast", args: "https://discord.com/channels/100/300/42", wantSent: "You can't read <#300>."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockDiscordSession{
+				history:     testHistory(30),
+				messages:    map[string]*discordgo.Message{linked.ID: linked},
+				permissions: map[string]int64{"200": readable},
+				// Message links need a numeric guild ID
+				channelGuildID: "100",
+			}
+			mockAI := &mockAIClient{}
+			bot := &Bot{
+				session:   session,
+				aiClient:  mockAI,
+				commands:  mustCommandRegistry(t),
+				cooldowns: newCooldownTracker(),
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			m := guildMessage(&discordgo.User{ID: "2", Username: "erin"})
+			m.GuildID = "100"
+			bot.dispatch(context.Background(), tt.command, m, strings.Fields(tt.args))
+
+			if tt.wantSent != "" {
+				if len(mockAI.prompts) != 0 || len(session.sentMessages) != 1 || session.sentMessages[0] != tt.wantSent {
+					t.Errorf("sent %q, want only %q", session.sentMessages, tt.wantSent)
+				}
+				return
+			}
+			if len(mockAI.prompts) != 1 {
+				t.Fatalf("sent %d prompts, want 1 (messages %q)", len(mockAI.prompts), session.sentMessages)
+			}
+			if tt.wantRead != "" && (len(session.historyReads) == 0 || session.historyReads[0] != tt.wantRead) {
+				t.Errorf("read history from %q, want %q", session.historyReads, tt.wantRead)
+			}
+			if text := mockAI.systemMessages[0] + mockAI.prompts[0]; !strings.Contains(text, tt.wantPrompt) {
+				t.Errorf("prompt is missing %q:\n%s", tt.wantPrompt, text)
+			}
+		})
+	}
+}
+
+func TestTimeWindows(t *testing.T) {
+	now := time.Now().UTC()
+	clock := func(d time.Duration) string { return now.Add(-d).Format("2006-01-02T15:04") }
+
+	tests := []struct {
+		name    string
+		command string
+		args    string
+		// wantMessages is roughly how many messages are read; the window edges can
+		// move by a minute while the test runs
+		wantMessages int
+		wantPrompt   string
+		wantSent     string
+	}{
+		{name: "since", command: "who_won", args: "--since 30m", wantMessages: 30, wantPrompt: "messages sent in this channel in the last 30m"},
+		{name: "positional duration", command: "opinion", args: "2h", wantMessages: 120, wantPrompt: "in the last 2h"},
+		{name: "from and to", command: "most", args: "funniest --from " + clock(3*time.Hour) + " --to " + clock(time.Hour), wantMessages: 120, wantPrompt: "between"},
+		{name: "a count still caps the window", command: "opinion", args: "--since 2h 20", wantMessages: 20},
+		{name: "since with from", command: "opinion", args: "--since 2h --from 18:00", wantSent: "Use either --since or --from and --to, not both."},
+		{name: "window with a link", command: "who_won", args: "2h https://discord.com/channels/100/200/42", wantSent: "Pick either a message link or a time window, not both."},
+		{name: "from after to", command: "opinion", args: "--from " + clock(time.Hour) + " --to " + clock(2*time.Hour), wantSent: "--from has to be before --to."},
+		{name: "empty window", command: "opinion", args: "--to 2015-02-01", wantSent: "There are no messages to read there."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &mockDiscordSession{history: testHistory(500)}
+			mockAI := &mockAIClient{}
+			bot := &Bot{
+				session:   session,
+				aiClient:  mockAI,
+				commands:  mustCommandRegistry(t),
+				cooldowns: newCooldownTracker(),
+				location:  time.UTC,
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			m := guildMessage(&discordgo.User{ID: "2", Username: "erin"})
+			m.GuildID = "100"
+
+			bot.dispatch(context.Background(), tt.command, m, strings.Fields(tt.args))
+
+			if tt.wantSent != "" {
+				if len(mockAI.prompts) != 0 || len(session.sentMessages) == 0 || session.sentMessages[len(session.sentMessages)-1] != tt.wantSent {
+					t.Errorf("sent %q, want %q", session.sentMessages, tt.wantSent)
+				}
+				return
+			}
+			if len(mockAI.prompts) != 1 {
+				t.Fatalf("sent %d prompts, want 1 (messages %q)", len(mockAI.prompts), session.sentMessages)
+			}
+			if read := strings.Count(mockAI.prompts[0], "dave: message"); read < tt.wantMessages-1 || read > tt.wantMessages+1 {
+				t.Errorf("read %d messages, want about %d", read, tt.wantMessages)
+			}
+			if !strings.Contains(mockAI.systemMessages[0], tt.wantPrompt) {
+				t.Errorf("system message is missing %q:\n%s", tt.wantPrompt, mockAI.systemMessages[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// interactionResponder routes a handler's output to a deferred slash command response.
+// The first message replaces the "thinking..." placeholder; later ones become follow-ups.
+type interactionResponder struct {
+	interaction *discordgo.Interaction
+	ephemeral   bool
+
+	mu        sync.Mutex
+	responded bool
+}
+
+// interactionKey is the context key for the active interaction responder
+type interactionKey struct{}
+
+// withInteraction returns a context whose sends go to the given interaction
+func withInteraction(ctx context.Context, responder *interactionResponder) context.Context {
+	return context.WithValue(ctx, interactionKey{}, responder)
+}
+
+// interactionFromContext returns the interaction responder carried by ctx, if any
+func interactionFromContext(ctx context.Context) *interactionResponder {
+	responder, _ := ctx.Value(interactionKey{}).(*interactionResponder)
+	return responder
+}
+
+// replyTarget makes the first message sent for a conversation a Discord reply, so the
+// reply chain can be followed back to what prompted it
+type replyTarget struct {
+	reference *discordgo.MessageReference
+
+	mu      sync.Mutex
+	replied bool
+}
+
+// replyKey is the context key for the active reply target
+type replyKey struct{}
+
+// withReply returns a context whose first send replies to the given message
+func withReply(ctx context.Context, m *discordgo.Message) context.Context {
+	return context.WithValue(ctx, replyKey{}, &replyTarget{reference: m.Reference()})
+}
+
+// replyFromContext returns the reply target carried by ctx, if any
+func replyFromContext(ctx context.Context) *replyTarget {
+	target, _ := ctx.Value(replyKey{}).(*replyTarget)
+	return target
+}
+
+// send posts a message for the current command. Prefix commands post to the channel;
+// slash commands edit their deferred response or post follow-up messages.
+func (b *Bot) send(ctx context.Context, channelID, content string) (*discordgo.Message, error) {
+	if err := cancelledJob(ctx); err != nil {
+		return nil, err
+	}
+	responder := interactionFromContext(ctx)
+	if responder == nil {
+		if target := replyFromContext(ctx); target != nil {
+			target.mu.Lock()
+			defer target.mu.Unlock()
+			if !target.replied {
+				target.replied = true
+				return b.session.ChannelMessageSendReply(channelID, content, target.reference)
+			}
+		}
+		return b.session.ChannelMessageSend(channelID, content)
+	}
+
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+
+	if !responder.responded {
+		responder.responded = true
+		return b.session.InteractionResponseEdit(responder.interaction, &discordgo.WebhookEdit{Content: &content})
+	}
+
+	params := &discordgo.WebhookParams{Content: content}
+	if responder.ephemeral {
+		params.Flags = discordgo.MessageFlagsEphemeral
+	}
+	return b.session.FollowupMessageCreate(responder.interaction, true, params)
+}
+
+// sendEmbed posts an embed for the current command, following the same routing as send
+func (b *Bot) sendEmbed(ctx context.Context, channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	if err := cancelledJob(ctx); err != nil {
+		return nil, err
+	}
+	responder := interactionFromContext(ctx)
+	if responder == nil {
+		return b.session.ChannelMessageSendEmbed(channelID, embed)
+	}
+
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+
+	embeds := []*discordgo.MessageEmbed{embed}
+	if !responder.responded {
+		responder.responded = true
+		return b.session.InteractionResponseEdit(responder.interaction, &discordgo.WebhookEdit{Embeds: &embeds})
+	}
+
+	params := &discordgo.WebhookParams{Embeds: embeds}
+	if responder.ephemeral {
+		params.Flags = discordgo.MessageFlagsEphemeral
+	}
+	return b.session.FollowupMessageCreate(responder.interaction, true, params)
+}
+
+// edit replaces the content of a message posted with send, such as a status message
+func (b *Bot) edit(ctx context.Context, channelID, messageID, content string) (*discordgo.Message, error) {
+	if responder := interactionFromContext(ctx); responder != nil {
+		return b.session.FollowupMessageEdit(responder.interaction, messageID, &discordgo.WebhookEdit{Content: &content})
+	}
+	return b.session.ChannelMessageEdit(channelID, messageID, content)
+}
+
+// finish closes out a deferred response that the handler never wrote to, so the
+// user isn't left looking at "thinking..." forever
+func (r *interactionResponder) finish(b *Bot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.responded {
+		return
+	}
+	r.responded = true
+
+	content := "Done."
+	if _, err := b.session.InteractionResponseEdit(r.interaction, &discordgo.WebhookEdit{Content: &content}); err != nil {
+		b.logger.Error("failed to finish interaction response", "error", err)
+	}
+}
@@ -0,0 +1,407 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/jobs"
+)
+
+// jobQueue runs background commands a few at a time and tracks the ones in flight so
+// they can be cancelled. Job records live in the store, so they outlast restarts.
+type jobQueue struct {
+	store *jobs.Store
+	// slots caps the jobs running at once; queued jobs wait for one
+	slots chan struct{}
+
+	mu      sync.Mutex
+	running map[int]*runningJob
+}
+
+// newJobQueue opens the job store and creates an empty queue
+func newJobQueue(cfg *config.Config) (*jobQueue, error) {
+	store, err := jobs.NewStore(filepath.Join(cfg.DataDir, JobsFile))
+	if err != nil {
+		return nil, err
+	}
+	return &jobQueue{
+		store:   store,
+		slots:   make(chan struct{}, MaxConcurrentJobs),
+		running: make(map[int]*runningJob),
+	}, nil
+}
+
+// runningJob is a job in flight in this process. Its handler's context carries it, so
+// progress reports can edit the job's status message.
+type runningJob struct {
+	id        int
+	channelID string
+	label     string
+	cancel    context.CancelFunc
+
+	mu          sync.Mutex
+	statusID    string
+	cancelledBy string
+	// progress is the latest progress report. It is only kept in memory: reports come
+	// once per page read, and a resumed job starts over anyway.
+	progress string
+}
+
+// jobKey is the context key for the job a handler runs as
+type jobKey struct{}
+
+// jobFromContext returns the job carried by ctx, if any
+func jobFromContext(ctx context.Context) *runningJob {
+	job, _ := ctx.Value(jobKey{}).(*runningJob)
+	return job
+}
+
+// cancelledJob returns the context's error when ctx belongs to a job that was cancelled
+// or interrupted, so the handler's remaining replies, such as errors about the
+// cancellation itself, aren't posted
+func cancelledJob(ctx context.Context) error {
+	if jobFromContext(ctx) == nil {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// track registers a job as in flight
+func (q *jobQueue) track(job *runningJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running[job.id] = job
+}
+
+// untrack forgets a finished job
+func (q *jobQueue) untrack(id int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, id)
+}
+
+// cancel stops a job in flight and reports whether it was running here
+func (q *jobQueue) cancel(id int, by string) bool {
+	q.mu.Lock()
+	job, ok := q.running[id]
+	q.mu.Unlock()
+	if !ok {
+		return false
+	}
+	job.mu.Lock()
+	job.cancelledBy = by
+	job.mu.Unlock()
+	job.cancel()
+	return true
+}
+
+// progress returns the latest progress of a job in flight, or "" if it has none
+func (q *jobQueue) progress(id int) string {
+	q.mu.Lock()
+	job, ok := q.running[id]
+	q.mu.Unlock()
+	if !ok {
+		return ""
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.progress
+}
+
+// jobLabel renders the command a job runs, e.g. "!opinion --since 2h"
+func jobLabel(job jobs.Job) string {
+	return shorten(strings.TrimSpace("!"+job.Command+" "+strings.Join(job.Args, " ")), JobLabelLength)
+}
+
+// startJob records a background command and runs it without blocking the handler that
+// received it. Slash commands get a short reply, since the job outlives the interaction.
+func (b *Bot) startJob(ctx context.Context, req *commandRequest, args []string) {
+	m := req.m
+	job, err := b.jobs.store.Create(jobs.Job{
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		MessageID: m.ID,
+		UserID:    m.Author.ID,
+		Username:  m.Author.Username,
+		Command:   req.cmd.name,
+		Args:      args,
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to create job", "command", req.cmd.name, "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error starting job: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "job created",
+		"job_id", job.ID,
+		"command", job.Command,
+		"user_id", job.UserID)
+
+	if interactionFromContext(ctx) != nil {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Started job #%d. Its progress and results are posted in the channel.", job.ID))
+	}
+	b.launchJob(job, req)
+}
+
+// launchJob tracks a job as in flight and runs it in the background, with a context
+// that is cancelled by !cancel or when the bot closes
+func (b *Bot) launchJob(job jobs.Job, req *commandRequest) {
+	// A job not launched because the bot is closing stays queued and resumes on the next start
+	if !b.beginHandler() {
+		return
+	}
+	ctx, cancel := context.WithCancel(b.background)
+	running := &runningJob{
+		id:        job.ID,
+		channelID: job.ChannelID,
+		label:     jobLabel(job),
+		cancel:    cancel,
+		statusID:  job.StatusMessageID,
+	}
+	b.jobs.track(running)
+	go b.runJob(context.WithValue(ctx, jobKey{}, running), running, req)
+}
+
+// runJob waits for a free slot, then runs the job's handler. A panic fails the job
+// instead of the bot.
+func (b *Bot) runJob(ctx context.Context, running *runningJob, req *commandRequest) {
+	defer b.handlers.Done()
+	defer running.cancel()
+	defer b.jobs.untrack(running.id)
+
+	if running.statusID == "" {
+		b.showJobStatus(ctx, running, fmt.Sprintf("queued. Stop it with `!cancel %d`.", running.id))
+	}
+
+	select {
+	case b.jobs.slots <- struct{}{}:
+		defer func() { <-b.jobs.slots }()
+	case <-ctx.Done():
+		b.finishJob(ctx, running, nil)
+		return
+	}
+
+	b.updateJob(ctx, running.id, func(j *jobs.Job) {
+		j.State = jobs.StateRunning
+		j.Attempts++
+	})
+	b.showJobStatus(ctx, running, fmt.Sprintf("running. Stop it with `!cancel %d`.", running.id))
+
+	var failure error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				b.logger.ErrorContext(ctx, "job panicked", "job_id", running.id, "panic", r, "stack", string(debug.Stack()))
+				failure = fmt.Errorf("%v", r)
+			}
+		}()
+		req.cmd.handler(b, ctx, req)
+	}()
+	b.finishJob(ctx, running, failure)
+}
+
+// finishJob records how a job ended and says so in its status message. Jobs stopped by
+// shutdown stay active, so they resume on the next start.
+func (b *Bot) finishJob(ctx context.Context, job *runningJob, failure error) {
+	job.mu.Lock()
+	cancelledBy := job.cancelledBy
+	job.mu.Unlock()
+
+	var state, status string
+	switch {
+	case b.background.Err() != nil && cancelledBy == "":
+		b.logger.InfoContext(ctx, "job interrupted by shutdown", "job_id", job.id)
+		return
+	case failure != nil:
+		state, status = jobs.StateFailed, fmt.Sprintf("failed: %v", failure)
+	case cancelledBy != "":
+		state, status = jobs.StateCancelled, "cancelled by "+cancelledBy+"."
+	default:
+		state, status = jobs.StateDone, "finished."
+	}
+
+	b.updateJob(ctx, job.id, func(j *jobs.Job) {
+		j.State = state
+		if failure != nil {
+			j.Error = failure.Error()
+		}
+	})
+	b.showJobStatus(ctx, job, status)
+
+	b.logger.InfoContext(ctx, "job finished", "job_id", job.id, "state", state)
+}
+
+// reportJobProgress shows progress in the status message of the job ctx carries and
+// reports whether there was one. Progress reporters call it before posting their own
+// status messages.
+func (b *Bot) reportJobProgress(ctx context.Context, progress string) bool {
+	job := jobFromContext(ctx)
+	if job == nil {
+		return false
+	}
+	// Progress reported while a cancelled job winds down would overwrite its final status
+	if ctx.Err() != nil {
+		return true
+	}
+	job.mu.Lock()
+	job.progress = progress
+	job.mu.Unlock()
+	b.showJobStatus(ctx, job, fmt.Sprintf("running. Stop it with `!cancel %d`.\n%s", job.id, progress))
+	return true
+}
+
+// showJobStatus posts the job's status message, or edits it once it exists
+func (b *Bot) showJobStatus(ctx context.Context, job *runningJob, status string) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	content := fmt.Sprintf("**Job #%d** `%s` %s", job.id, job.label, status)
+	if job.statusID != "" {
+		if _, err := b.session.ChannelMessageEdit(job.channelID, job.statusID, content); err != nil {
+			b.logger.ErrorContext(ctx, "failed to update job status", "job_id", job.id, "error", err)
+		}
+		return
+	}
+
+	msg, err := b.session.ChannelMessageSend(job.channelID, content)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to send job status", "job_id", job.id, "error", err)
+		return
+	}
+	job.statusID = msg.ID
+	b.updateJob(ctx, job.id, func(j *jobs.Job) { j.StatusMessageID = msg.ID })
+}
+
+// updateJob applies fn to a job record, logging failures to save it
+func (b *Bot) updateJob(ctx context.Context, id int, fn func(*jobs.Job)) {
+	if _, _, err := b.jobs.store.Update(id, fn); err != nil {
+		b.logger.ErrorContext(ctx, "failed to save job", "job_id", id, "error", err)
+	}
+}
+
+// resumeJobs picks up the jobs that were active when the bot last stopped. Each job is
+// run again from the start, unless it has already been interrupted MaxJobAttempts times
+// or can no longer be parsed, in which case it fails with an explanation. Finished jobs
+// older than JobRetention are pruned first.
+func (b *Bot) resumeJobs(ctx context.Context) {
+	if removed, err := b.jobs.store.Prune(time.Now().Add(-JobRetention)); err != nil {
+		b.logger.ErrorContext(ctx, "failed to prune jobs", "error", err)
+	} else if removed > 0 {
+		b.logger.InfoContext(ctx, "pruned finished jobs", "jobs", removed)
+	}
+
+	// Stale jobs are failed before any job is launched, so status edits don't interleave
+	type resumable struct {
+		job jobs.Job
+		req *commandRequest
+	}
+	var resume []resumable
+	for _, job := range b.jobs.store.Active("") {
+		running := &runningJob{id: job.ID, channelID: job.ChannelID, label: jobLabel(job), statusID: job.StatusMessageID}
+
+		cmd, ok := b.commands.lookup(job.Command)
+		var parsed *parsedArgs
+		var err error
+		if ok {
+			parsed, err = parseCommandArgs(cmd, job.Args)
+		}
+		if !ok || err != nil || job.Attempts >= MaxJobAttempts {
+			b.updateJob(ctx, job.ID, func(j *jobs.Job) {
+				j.State = jobs.StateFailed
+				j.Error = "interrupted by a restart"
+			})
+			b.showJobStatus(ctx, running, "failed: the bot restarted while it was running. Run the command again to retry.")
+			b.logger.InfoContext(ctx, "job failed after restart", "job_id", job.ID, "attempts", job.Attempts)
+			continue
+		}
+
+		b.showJobStatus(ctx, running, "is resuming after a restart.")
+		b.logger.InfoContext(ctx, "job resumed", "job_id", job.ID, "command", job.Command)
+
+		m := &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID:        job.MessageID,
+			GuildID:   job.GuildID,
+			ChannelID: job.ChannelID,
+			Author:    &discordgo.User{ID: job.UserID, Username: job.Username},
+		}}
+		resume = append(resume, resumable{job: job, req: &commandRequest{
+			cmd:      cmd,
+			m:        m,
+			args:     parsed.rest,
+			values:   parsed.values,
+			provider: parsed.provider,
+			model:    parsed.model,
+		}})
+	}
+
+	for _, r := range resume {
+		b.launchJob(r.job, r.req)
+	}
+}
+
+// handleJobs handles the !jobs command
+func (b *Bot) handleJobs(ctx context.Context, req *commandRequest) {
+	m := req.m
+	active := b.jobs.store.Active(m.GuildID)
+	if len(active) == 0 {
+		b.send(ctx, m.ChannelID, "No jobs are running.")
+		return
+	}
+
+	lines := make([]string, len(active))
+	for i, job := range active {
+		line := fmt.Sprintf("`#%d` `%s` · %s · started by %s <t:%d:R>", job.ID, jobLabel(job), job.State, job.Username, job.Created.Unix())
+		if progress := b.jobs.progress(job.ID); progress != "" {
+			line += "\n> " + progress
+		}
+		lines[i] = line
+	}
+	b.sendList(ctx, m.ChannelID, fmt.Sprintf("**Running jobs** (%d). Stop one with `!cancel <id>`.", len(active)), lines)
+}
+
+// handleCancel handles the !cancel command
+func (b *Bot) handleCancel(ctx context.Context, req *commandRequest) {
+	m := req.m
+	value, given := req.value("id")
+	id, err := strconv.Atoi(value)
+	if !given || err != nil {
+		b.sendUsage(ctx, req, "Use `!jobs` to find the ID.")
+		return
+	}
+
+	job, ok := b.jobs.store.Get(id)
+	if !ok || job.GuildID != m.GuildID || !job.Active() {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("There's no running job #%d.", id))
+		return
+	}
+
+	// The member who started a job and moderators may cancel it
+	if m.Author.ID != job.UserID && !b.hasPermission(m, discordgo.PermissionManageMessages) {
+		b.send(ctx, m.ChannelID, "You can only cancel jobs you started.")
+		return
+	}
+
+	by := b.displayName(m.GuildID, m.Author)
+	if !b.jobs.cancel(id, by) {
+		// Not running in this process, so nothing else will finish the record
+		b.updateJob(ctx, id, func(j *jobs.Job) { j.State = jobs.StateCancelled })
+		b.showJobStatus(ctx, &runningJob{id: id, channelID: job.ChannelID, label: jobLabel(job), statusID: job.StatusMessageID},
+			"cancelled by "+by+".")
+	}
+
+	b.logger.InfoContext(ctx, "job cancelled",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"job_id", id)
+
+	b.send(ctx, m.ChannelID, fmt.Sprintf("Cancelled job #%d.", id))
+}
@@ -0,0 +1,339 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/jobs"
+)
+
+// newJobsTestBot returns a bot with a job queue stored in a temporary directory. Besides
+// the default commands it has !slow, a background command that runs until cancelled and
+// closes started when it begins.
+func newJobsTestBot(t *testing.T) (*Bot, *mockDiscordSession, chan struct{}) {
+	t.Helper()
+	queue, err := newJobQueue(&config.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("newJobQueue() error = %v", err)
+	}
+
+	started := make(chan struct{})
+	slow := &command{
+		name:       "slow",
+		category:   categoryGeneral,
+		background: true,
+		handler: func(b *Bot, ctx context.Context, req *commandRequest) {
+			close(started)
+			<-ctx.Done()
+			b.send(ctx, req.m.ChannelID, "Error: "+ctx.Err().Error())
+		},
+	}
+	commands, err := newCommandRegistry(append(defaultCommands(), slow))
+	if err != nil {
+		t.Fatalf("newCommandRegistry() error = %v", err)
+	}
+
+	background, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	session := &mockDiscordSession{history: catchupHistory()}
+	return &Bot{
+		session:        session,
+		aiClient:       &mockAIClient{},
+		jobs:           queue,
+		commands:       commands,
+		cooldowns:      newCooldownTracker(),
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		background:     background,
+		stopBackground: stop,
+	}, session, started
+}
+
+// waitForJobs waits until no job is running
+func waitForJobs(t *testing.T, b *Bot) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.jobs.mu.Lock()
+		running := len(b.jobs.running)
+		b.jobs.mu.Unlock()
+		if running == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d jobs still running", running)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitForStart waits for the !slow handler to begin
+func waitForStart(t *testing.T, started chan struct{}) {
+	t.Helper()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job never started")
+	}
+}
+
+func TestBackgroundCommandRunsAsJob(t *testing.T) {
+	bot, session, _ := newJobsTestBot(t)
+	alice := &discordgo.User{ID: "alice", Username: "alice"}
+
+	bot.dispatch(context.Background(), "opinion", guildMessage(alice), []string{"5"})
+	waitForJobs(t, bot)
+
+	job, ok := bot.jobs.store.Get(1)
+	if !ok {
+		t.Fatal("no job recorded")
+	}
+	if job.State != jobs.StateDone || job.Command != "opinion" || job.UserID != "alice" || job.Attempts != 1 {
+		t.Errorf("job = %+v, want opinion by alice done after 1 attempt", job)
+	}
+	if !slices.Equal(job.Args, []string{"5"}) {
+		t.Errorf("job args = %v, want [5]", job.Args)
+	}
+
+	if len(session.sentMessages) == 0 || session.sentMessages[0] != "**Job #1** `!opinion 5` queued. Stop it with `!cancel 1`." {
+		t.Errorf("sent messages = %q, want the job status first", session.sentMessages)
+	}
+	if !slices.ContainsFunc(session.sentMessages, func(msg string) bool { return strings.Contains(msg, "mock response") }) {
+		t.Errorf("sent messages = %q, want the command's answer", session.sentMessages)
+	}
+	if len(session.edits) == 0 || session.edits[len(session.edits)-1] != "**Job #1** `!opinion 5` finished." {
+		t.Errorf("status edits = %q, want it to end finished", session.edits)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		canceller   string
+		permissions int64
+		args        []string
+		wantReply   string
+		wantState   string
+		wantStatus  string
+	}{
+		{
+			name:       "by the member who started it",
+			canceller:  "alice",
+			args:       []string{"1"},
+			wantReply:  "Cancelled job #1.",
+			wantState:  jobs.StateCancelled,
+			wantStatus: "**Job #1** `!slow` cancelled by alice.",
+		},
+		{
+			name:        "by a moderator",
+			canceller:   "mod",
+			permissions: discordgo.PermissionManageMessages,
+			args:        []string{"1"},
+			wantReply:   "Cancelled job #1.",
+			wantState:   jobs.StateCancelled,
+			wantStatus:  "**Job #1** `!slow` cancelled by mod.",
+		},
+		{
+			name:      "by someone else",
+			canceller: "bob",
+			args:      []string{"1"},
+			wantReply: "You can only cancel jobs you started.",
+			wantState: jobs.StateRunning,
+		},
+		{
+			name:      "unknown job",
+			canceller: "alice",
+			args:      []string{"9"},
+			wantReply: "There's no running job #9.",
+			wantState: jobs.StateRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, session, started := newJobsTestBot(t)
+			session.permissions = map[string]int64{"channel": tt.permissions}
+			alice := &discordgo.User{ID: "alice", Username: "alice"}
+
+			bot.dispatch(context.Background(), "slow", guildMessage(alice), nil)
+			waitForStart(t, started)
+
+			canceller := &discordgo.User{ID: tt.canceller, Username: tt.canceller}
+			sent := len(session.sentMessages)
+			bot.dispatch(context.Background(), "cancel", guildMessage(canceller), tt.args)
+			if got := session.sentMessages[sent:]; len(got) != 1 || got[0] != tt.wantReply {
+				t.Errorf("replies = %q, want %q", got, tt.wantReply)
+			}
+
+			if tt.wantState == jobs.StateRunning {
+				bot.jobs.cancel(1, "cleanup")
+			}
+			waitForJobs(t, bot)
+
+			if tt.wantState != jobs.StateRunning {
+				job, _ := bot.jobs.store.Get(1)
+				if job.State != tt.wantState {
+					t.Errorf("job state = %q, want %q", job.State, tt.wantState)
+				}
+				if got := session.edits[len(session.edits)-1]; got != tt.wantStatus {
+					t.Errorf("final status = %q, want %q", got, tt.wantStatus)
+				}
+				// The handler's error about the cancellation isn't posted
+				if slices.Contains(session.sentMessages, "Error: context canceled") {
+					t.Errorf("sent messages = %q, want no error after cancelling", session.sentMessages)
+				}
+			}
+		})
+	}
+}
+
+func TestJobsListsActiveJobs(t *testing.T) {
+	bot, session, started := newJobsTestBot(t)
+	alice := &discordgo.User{ID: "alice", Username: "alice"}
+
+	bot.dispatch(context.Background(), "jobs", guildMessage(alice), nil)
+	if got := session.sentMessages; len(got) != 1 || got[0] != "No jobs are running." {
+		t.Errorf("replies = %q, want no jobs", got)
+	}
+
+	bot.dispatch(context.Background(), "slow", guildMessage(alice), nil)
+	waitForStart(t, started)
+
+	// Progress is shown without saving the jobs file
+	saved, _ := bot.jobs.store.Get(1)
+	bot.jobs.mu.Lock()
+	ctx := context.WithValue(context.Background(), jobKey{}, bot.jobs.running[1])
+	bot.jobs.mu.Unlock()
+	bot.reportJobProgress(ctx, "Reading message history... 800/2000")
+	if job, _ := bot.jobs.store.Get(1); !job.Updated.Equal(saved.Updated) {
+		t.Errorf("job record updated at %v after a progress report, want it left at %v", job.Updated, saved.Updated)
+	}
+
+	bot.dispatch(context.Background(), "jobs", guildMessage(alice), nil)
+	last := session.sentMessages[len(session.sentMessages)-1]
+	for _, want := range []string{"**Running jobs** (1)", "`#1` `!slow` · running · started by alice", "> Reading message history... 800/2000"} {
+		if !strings.Contains(last, want) {
+			t.Errorf("listing = %q, want it to contain %q", last, want)
+		}
+	}
+
+	bot.jobs.cancel(1, "alice")
+	waitForJobs(t, bot)
+}
+
+func TestResumeJobs(t *testing.T) {
+	bot, session, _ := newJobsTestBot(t)
+	records := []jobs.Job{
+		{Command: "opinion", Args: []string{"5"}, State: jobs.StateRunning, Attempts: 1},
+		{Command: "opinion", Args: []string{"5"}, State: jobs.StateRunning, Attempts: MaxJobAttempts},
+		{Command: "removed_command"},
+	}
+	for _, record := range records {
+		record.GuildID, record.ChannelID, record.UserID, record.Username = "guild", "channel", "alice", "alice"
+		record.StatusMessageID = "status"
+		created, err := bot.jobs.store.Create(record)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, _, err := bot.jobs.store.Update(created.ID, func(j *jobs.Job) {
+			j.State = cmp.Or(record.State, jobs.StateQueued)
+			j.Attempts = record.Attempts
+		}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+
+	bot.resumeJobs(context.Background())
+	waitForJobs(t, bot)
+
+	wantStates := []string{jobs.StateDone, jobs.StateFailed, jobs.StateFailed}
+	for i, want := range wantStates {
+		job, _ := bot.jobs.store.Get(i + 1)
+		if job.State != want {
+			t.Errorf("job #%d state = %q, want %q", job.ID, job.State, want)
+		}
+	}
+
+	for _, want := range []string{
+		"**Job #1** `!opinion 5` is resuming after a restart.",
+		"**Job #1** `!opinion 5` finished.",
+		"**Job #2** `!opinion 5` failed: the bot restarted while it was running. Run the command again to retry.",
+		"**Job #3** `!removed_command` failed: the bot restarted while it was running. Run the command again to retry.",
+	} {
+		if !slices.Contains(session.edits, want) {
+			t.Errorf("status edits = %q, want %q", session.edits, want)
+		}
+	}
+}
+
+func TestJobsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DataDir: dir}
+	queue, err := newJobQueue(cfg)
+	if err != nil {
+		t.Fatalf("newJobQueue() error = %v", err)
+	}
+	if _, err := queue.store.Create(jobs.Job{GuildID: "guild", Command: "opinion"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	reopened, err := jobs.NewStore(filepath.Join(dir, JobsFile))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	if active := reopened.Active("guild"); len(active) != 1 || active[0].Command != "opinion" {
+		t.Errorf("active jobs after reopening = %+v, want the queued opinion job", active)
+	}
+}
+
+func TestShutdownDrainsJobs(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		timeout time.Duration
+		// wantState is the job's saved state after Close; running jobs resume on the next start
+		wantState  string
+		wantAnswer bool
+	}{
+		{name: "finished during the drain", command: "opinion", timeout: ShutdownCancelGrace + 5*time.Second, wantState: jobs.StateDone, wantAnswer: true},
+		{name: "interrupted at the timeout", command: "slow", timeout: 50 * time.Millisecond, wantState: jobs.StateRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, session, started := newJobsTestBot(t)
+			alice := &discordgo.User{ID: "alice", Username: "alice"}
+
+			bot.dispatch(context.Background(), tt.command, guildMessage(alice), nil)
+			if tt.command == "slow" {
+				waitForStart(t, started)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := bot.Close(ctx); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			job, _ := bot.jobs.store.Get(1)
+			if job.State != tt.wantState {
+				t.Errorf("job state after shutdown = %q, want %q", job.State, tt.wantState)
+			}
+			answered := slices.ContainsFunc(session.sentMessages, func(msg string) bool { return strings.Contains(msg, "mock response") })
+			if answered != tt.wantAnswer {
+				t.Errorf("sent messages = %q, want answer posted = %v", session.sentMessages, tt.wantAnswer)
+			}
+			if slices.Contains(session.sentMessages, "Error: context canceled") {
+				t.Errorf("sent messages = %q, want no error from the interrupted job", session.sentMessages)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// memberKey identifies a member of one guild
+type memberKey struct {
+	guildID string
+	userID  string
+}
+
+// memberEntry is a cached lookup. Failed lookups are cached too, for a shorter time,
+// so a departed member isn't fetched again for every message they left behind.
+type memberEntry struct {
+	member  *discordgo.Member
+	err     error
+	fetched time.Time
+}
+
+// memberCache remembers guild members seen in messages, member events and REST lookups,
+// so resolving names for a batch of messages doesn't cost one request per message
+type memberCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	errorTTL time.Duration
+	now      func() time.Time
+	entries  map[memberKey]memberEntry
+	// swept is when expired entries were last removed. Entries are otherwise only
+	// dropped when read, and most members cached from events are never read again.
+	swept time.Time
+}
+
+// newMemberCache creates a cache whose entries expire after ttl, or errorTTL for failed lookups
+func newMemberCache(ttl, errorTTL time.Duration) *memberCache {
+	return &memberCache{
+		ttl:      ttl,
+		errorTTL: errorTTL,
+		now:      time.Now,
+		entries:  make(map[memberKey]memberEntry),
+	}
+}
+
+// get returns a cached lookup and whether it is still fresh
+func (c *memberCache) get(guildID, userID string) (memberEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := memberKey{guildID: guildID, userID: userID}
+	entry, ok := c.entries[key]
+	if !ok {
+		return memberEntry{}, false
+	}
+	if c.expired(entry, c.now()) {
+		delete(c.entries, key)
+		return memberEntry{}, false
+	}
+	return entry, true
+}
+
+// expired reports whether an entry is past its TTL. Callers must hold the lock.
+func (c *memberCache) expired(entry memberEntry, now time.Time) bool {
+	ttl := c.ttl
+	if entry.err != nil {
+		ttl = c.errorTTL
+	}
+	return now.Sub(entry.fetched) >= ttl
+}
+
+// put caches the result of a lookup. At most once per TTL it also sweeps out expired
+// entries, so the cache only holds members seen recently.
+func (c *memberCache) put(guildID, userID string, member *discordgo.Member, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.Sub(c.swept) >= c.ttl {
+		for key, entry := range c.entries {
+			if c.expired(entry, now) {
+				delete(c.entries, key)
+			}
+		}
+		c.swept = now
+	}
+	c.entries[memberKey{guildID: guildID, userID: userID}] = memberEntry{member: member, err: err, fetched: now}
+}
+
+// remove forgets a member
+func (c *memberCache) remove(guildID, userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, memberKey{guildID: guildID, userID: userID})
+}
+
+// guildMember looks a member up in the gateway state, then the cache, and only then
+// over REST, caching the result
+func (b *Bot) guildMember(guildID, userID string) (*discordgo.Member, error) {
+	if member, err := b.session.GetState().Member(guildID, userID); err == nil {
+		return member, nil
+	}
+	if b.members != nil {
+		if entry, ok := b.members.get(guildID, userID); ok {
+			return entry.member, entry.err
+		}
+	}
+
+	member, err := b.session.GuildMember(guildID, userID)
+	if b.members != nil {
+		b.members.put(guildID, userID, member, err)
+	}
+	return member, err
+}
+
+// displayName returns the name a member goes by in a guild: their server nickname, then
+// their global display name, then their username
+func (b *Bot) displayName(guildID string, user *discordgo.User) string {
+	if guildID != "" {
+		if member, err := b.guildMember(guildID, user.ID); err == nil && member.Nick != "" {
+			return member.Nick
+		}
+	}
+	return user.DisplayName()
+}
+
+// cacheMessageMember caches the partial member object Discord attaches to guild messages
+func (b *Bot) cacheMessageMember(m *discordgo.MessageCreate) {
+	if b.members == nil || m.GuildID == "" || m.Member == nil || m.Author == nil {
+		return
+	}
+	member := *m.Member
+	member.GuildID = m.GuildID
+	member.User = m.Author
+	b.members.put(m.GuildID, m.Author.ID, &member, nil)
+}
+
+// guildMemberAddHandler caches members as they join
+func (b *Bot) guildMemberAddHandler(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	if b.members != nil && m.User != nil {
+		b.members.put(m.GuildID, m.User.ID, m.Member, nil)
+	}
+}
+
+// guildMemberUpdateHandler refreshes cached members when their nickname or roles change
+func (b *Bot) guildMemberUpdateHandler(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	if b.members != nil && m.User != nil {
+		b.members.put(m.GuildID, m.User.ID, m.Member, nil)
+	}
+}
+
+// guildMemberRemoveHandler forgets members who leave
+func (b *Bot) guildMemberRemoveHandler(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	if b.members != nil && m.User != nil {
+		b.members.remove(m.GuildID, m.User.ID)
+	}
+}
@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMemberCacheExpiry(t *testing.T) {
+	cache := newMemberCache(10*time.Minute, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	cache.put("guild", "1", &discordgo.Member{Nick: "Davey"}, nil)
+	cache.put("guild", "2", nil, errors.New("unknown member"))
+
+	now = now.Add(2 * time.Minute)
+	if entry, ok := cache.get("guild", "1"); !ok || entry.member.Nick != "Davey" {
+		t.Errorf("get(1) = %+v, %v, want the cached member", entry, ok)
+	}
+	if _, ok := cache.get("guild", "2"); ok {
+		t.Error("failed lookup still cached after the error TTL")
+	}
+	if _, ok := cache.get("other-guild", "1"); ok {
+		t.Error("member cached across guilds")
+	}
+
+	now = now.Add(10 * time.Minute)
+	if _, ok := cache.get("guild", "1"); ok {
+		t.Error("member still cached after the TTL")
+	}
+}
+
+func TestMemberCacheSweepsExpiredEntries(t *testing.T) {
+	cache := newMemberCache(10*time.Minute, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	// Members cached from events and never looked up again
+	for i := range 100 {
+		cache.put("guild", strconv.Itoa(i), &discordgo.Member{}, nil)
+	}
+	now = now.Add(5 * time.Minute)
+	cache.put("guild", "recent", &discordgo.Member{}, nil)
+
+	now = now.Add(6 * time.Minute)
+	cache.put("guild", "new", &discordgo.Member{}, nil)
+	if len(cache.entries) != 2 {
+		t.Errorf("cache holds %d entries, want only the 2 still fresh", len(cache.entries))
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		name    string
+		guildID string
+		user    *discordgo.User
+		want    string
+	}{
+		{name: "server nickname", guildID: "guild", user: &discordgo.User{ID: "1", Username: "dave", GlobalName: "Dave"}, want: "Davey"},
+		{name: "global name", guildID: "guild", user: &discordgo.User{ID: "2", Username: "erin", GlobalName: "Erin"}, want: "Erin"},
+		{name: "username", guildID: "guild", user: &discordgo.User{ID: "3", Username: "gus"}, want: "gus"},
+		{name: "outside a server", user: &discordgo.User{ID: "1", Username: "dave", GlobalName: "Dave"}, want: "Dave"},
+	}
+
+	session := &mockDiscordSession{nicknames: map[string]string{"1": "Davey"}}
+	bot := &Bot{session: session, members: newMemberCache(MemberCacheTTL, MemberErrorTTL)}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bot.displayName(tt.guildID, tt.user); got != tt.want {
+				t.Errorf("displayName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryLooksUpEachMemberOnce(t *testing.T) {
+	var history []*discordgo.Message
+	for i := range 60 {
+		author := &discordgo.User{ID: strconv.Itoa(i % 3), Username: "user" + strconv.Itoa(i%3)}
+		history = append(history, &discordgo.Message{ID: strconv.Itoa(100 - i), Content: "hi", Author: author})
+	}
+	session := &mockDiscordSession{history: history, nicknames: map[string]string{"0": "Zed"}}
+	bot := &Bot{
+		session: session,
+		members: newMemberCache(MemberCacheTTL, MemberErrorTTL),
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	messages, counts, err := bot.fetchAndCountMessages(context.Background(), historyQuery{channelID: "channel", limit: 60}, "guild")
+	if err != nil {
+		t.Fatalf("fetchAndCountMessages() error = %v", err)
+	}
+	if session.memberLookups != 3 {
+		t.Errorf("GuildMember called %d times, want once per member (3)", session.memberLookups)
+	}
+	if len(messages) != 60 || counts["Zed"] != 20 || counts["user1"] != 20 {
+		t.Errorf("counts = %v", counts)
+	}
+}
+
+func TestMemberEventsUpdateCache(t *testing.T) {
+	session := &mockDiscordSession{}
+	bot := &Bot{session: session, members: newMemberCache(MemberCacheTTL, MemberErrorTTL)}
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+
+	// Messages carry a partial member that is enough to resolve names
+	bot.cacheMessageMember(&discordgo.MessageCreate{Message: &discordgo.Message{
+		GuildID: "guild", Author: dave, Member: &discordgo.Member{Nick: "Davey", Roles: []string{"mod"}},
+	}})
+	if got := bot.displayName("guild", dave); got != "Davey" {
+		t.Errorf("name from message member = %q, want Davey", got)
+	}
+
+	bot.guildMemberUpdateHandler(nil, &discordgo.GuildMemberUpdate{Member: &discordgo.Member{GuildID: "guild", User: dave, Nick: "Big Dave"}})
+	if got := bot.displayName("guild", dave); got != "Big Dave" {
+		t.Errorf("name after update = %q, want Big Dave", got)
+	}
+	if session.memberLookups != 0 {
+		t.Errorf("GuildMember called %d times, want 0", session.memberLookups)
+	}
+
+	bot.guildMemberRemoveHandler(nil, &discordgo.GuildMemberRemove{Member: &discordgo.Member{GuildID: "guild", User: dave}})
+	member, err := bot.guildMember("guild", dave.ID)
+	if err != nil || session.memberLookups != 1 || slices.Contains(member.Roles, "mod") {
+		t.Errorf("after removal got %+v, %v with %d lookups, want a fresh REST lookup", member, err, session.memberLookups)
+	}
+}
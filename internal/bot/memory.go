@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/memory"
+)
+
+// memorySummaryTask instructs the model that folds old turns into the running summary
+const memorySummaryTask = "Update the running summary of a chat between a Discord user and the assistant. " +
+	"Merge the previous summary with the older turns. Keep names, topics, preferences, facts the user " +
+	"shared and open questions; drop small talk. Write plain prose of at most 120 words."
+
+// newMemoryStore builds the short-term memory, persisted to the data directory when enabled
+func newMemoryStore(cfg *config.Config) (*memory.Store, error) {
+	path := ""
+	if cfg.PersistMemory {
+		path = filepath.Join(cfg.DataDir, MemoryFile)
+	}
+	return memory.NewStore(path, MemoryMaxTokens, MemoryIdleTimeout)
+}
+
+// memoryKey identifies the author's conversation with the bot in the message's channel
+func memoryKey(m *discordgo.MessageCreate) memory.Key {
+	return memory.Key{ChannelID: m.ChannelID, UserID: m.Author.ID}
+}
+
+// rememberedTurns returns the remembered conversation followed by the new prompt. The
+// summary is model-written from chat content, so it is passed as data, not instructions.
+func (b *Bot) rememberedTurns(m *discordgo.MessageCreate, prompt string) []ai.ChatMessage {
+	var turns []ai.ChatMessage
+	if b.memory != nil {
+		conv := b.memory.Get(memoryKey(m))
+		if conv.Summary != "" {
+			turns = append(turns, ai.ChatMessage{
+				Role:    ai.RoleUser,
+				Content: "For context, a summary of what we talked about earlier:\n" + untrustedBlock("conversation summary", conv.Summary),
+			})
+		}
+		turns = append(turns, conv.Turns...)
+	}
+	return append(turns, ai.ChatMessage{Role: ai.RoleUser, Content: prompt})
+}
+
+// remember records an exchange and folds older turns into the summary once the
+// conversation outgrows its budget
+func (b *Bot) remember(ctx context.Context, m *discordgo.MessageCreate, prompt, response, provider string) {
+	if b.memory == nil {
+		return
+	}
+
+	key := memoryKey(m)
+	err := b.memory.Append(key,
+		ai.ChatMessage{Role: ai.RoleUser, Content: prompt},
+		ai.ChatMessage{Role: ai.RoleAssistant, Content: response})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to save conversation memory", "error", err)
+		return
+	}
+
+	summarize := func(ctx context.Context, summary string, turns []ai.ChatMessage) (string, error) {
+		return b.summarizeMemory(ctx, summary, turns, provider)
+	}
+	if err := b.memory.Compact(ctx, key, summarize); err != nil {
+		b.logger.ErrorContext(ctx, "failed to compact conversation memory",
+			"channel_id", m.ChannelID,
+			"user_id", m.Author.ID,
+			"error", err)
+	}
+}
+
+// summarizeMemory asks the model to merge older turns into the running summary
+func (b *Bot) summarizeMemory(ctx context.Context, summary string, turns []ai.ChatMessage, provider string) (string, error) {
+	model := ai.DefaultGrokModel
+	if provider == ai.ProviderOpenAI {
+		model = ai.DefaultOpenAIModel
+	}
+
+	lines := make([]string, len(turns))
+	for i, turn := range turns {
+		speaker := "User"
+		if turn.Role == ai.RoleAssistant {
+			speaker = "Assistant"
+		}
+		lines[i] = fmt.Sprintf("%s: %s", speaker, turn.Content)
+	}
+
+	blocks := []string{untrustedBlock("older turns", strings.Join(lines, "\n"))}
+	if summary != "" {
+		blocks = append([]string{untrustedBlock("previous summary", summary)}, blocks...)
+	}
+
+	systemMessage := buildSystemMessage("You keep concise notes for a chat bot.", memorySummaryTask)
+	prompt := buildDataPrompt("Write the updated summary.", blocks...)
+
+	return b.aiClient.AskClient(ctx, prompt, systemMessage, model, provider, ai.DefaultMaxTokens)
+}
+
+// handleReset handles the !reset command
+func (b *Bot) handleReset(ctx context.Context, req *commandRequest) {
+	m := req.m
+	if b.memory == nil {
+		b.send(ctx, m.ChannelID, "I don't keep conversation memory right now.")
+		return
+	}
+
+	if scope, _ := req.value("scope"); scope == "channel" {
+		if !b.hasPermission(m, discordgo.PermissionManageMessages) {
+			b.send(ctx, m.ChannelID, "You need the Manage Messages permission to reset everyone's memory in this channel.")
+			return
+		}
+
+		removed, err := b.memory.ResetChannel(m.ChannelID)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to reset channel memory", "error", err)
+			b.send(ctx, m.ChannelID, fmt.Sprintf("Error clearing memory: %v", err))
+			return
+		}
+
+		b.logger.InfoContext(ctx, "channel memory reset",
+			"channel_id", m.ChannelID,
+			"user_id", m.Author.ID,
+			"conversations", removed)
+
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Forgot %d conversation(s) in this channel.", removed))
+		return
+	}
+
+	removed, err := b.memory.Reset(memoryKey(m))
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to reset memory", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error clearing memory: %v", err))
+		return
+	}
+
+	if !removed {
+		b.send(ctx, m.ChannelID, "We weren't talking about anything here, so there's nothing to forget.")
+		return
+	}
+	b.send(ctx, m.ChannelID, "Done, I've forgotten our conversation in this channel. Fresh start.")
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/memory"
+)
+
+func TestAskRemembersConversation(t *testing.T) {
+	store, err := memory.NewStore("", MemoryMaxTokens, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	session := &mockDiscordSession{}
+	mockAI := &mockAIClient{}
+	bot := &Bot{
+		session:   session,
+		aiClient:  mockAI,
+		memory:    store,
+		commands:  mustCommandRegistry(t),
+		cooldowns: newCooldownTracker(),
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	message := func(author string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{Message: &discordgo.Message{
+			ChannelID: "channel",
+			Author:    &discordgo.User{ID: author, Username: author},
+		}}
+	}
+
+	bot.handleAsk(context.Background(), &commandRequest{m: message("alice"), args: []string{"who", "won", "in", "1986?"}, provider: ai.ProviderGrok})
+	bot.handleAsk(context.Background(), &commandRequest{m: message("alice"), args: []string{"what", "about", "the", "Celtics?"}, provider: ai.ProviderGrok})
+	bot.handleAsk(context.Background(), &commandRequest{m: message("bob"), args: []string{"hi"}, provider: ai.ProviderGrok})
+
+	if len(mockAI.conversations) != 3 {
+		t.Fatalf("Chat called %d times, want 3", len(mockAI.conversations))
+	}
+	followUp := mockAI.conversations[1]
+	if len(followUp) != 3 || followUp[0].Content != "who won in 1986?" || followUp[1].Role != ai.RoleAssistant {
+		t.Errorf("follow-up turns = %+v, want the first exchange before the new question", followUp)
+	}
+	if other := mockAI.conversations[2]; len(other) != 1 {
+		t.Errorf("another user's turns = %+v, want only their question", other)
+	}
+
+	bot.dispatch(context.Background(), "reset", message("alice"), nil)
+	if !strings.Contains(strings.Join(session.sentMessages, "\n"), "forgotten our conversation") {
+		t.Errorf("sent %q, want a reset confirmation", session.sentMessages)
+	}
+
+	bot.handleAsk(context.Background(), &commandRequest{m: message("alice"), args: []string{"hello?"}, provider: ai.ProviderGrok})
+	if afterReset := mockAI.conversations[3]; len(afterReset) != 1 {
+		t.Errorf("turns after reset = %+v, want only the new question", afterReset)
+	}
+}
+
+func TestResetChannelRequiresPermission(t *testing.T) {
+	store, err := memory.NewStore("", MemoryMaxTokens, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	key := memory.Key{ChannelID: "channel", UserID: "alice"}
+	if err := store.Append(key, ai.ChatMessage{Role: ai.RoleUser, Content: "hi"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	session := &mockDiscordSession{}
+	bot := &Bot{
+		session:   session,
+		memory:    store,
+		commands:  mustCommandRegistry(t),
+		cooldowns: newCooldownTracker(),
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{ChannelID: "channel", Author: &discordgo.User{ID: "bob"}}}
+
+	bot.dispatch(context.Background(), "reset", m, []string{"channel"})
+
+	if !strings.Contains(strings.Join(session.sentMessages, "\n"), "Manage Messages") {
+		t.Errorf("sent %q, want a permission error", session.sentMessages)
+	}
+	if got := store.Get(key); len(got.Turns) != 1 {
+		t.Errorf("memory was cleared without permission: %+v", got)
+	}
+}
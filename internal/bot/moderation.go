@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/moderation"
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
+)
+
+// safeRetorts replace responses that fail moderation
+var safeRetorts = []string{
+	"Yeah no, I'm not posting that one. Even a raccoon has standards.",
+	"I had something for this but my lawyer told me to shut up. Next question.",
+	"Wicked tempting, but I'm keeping that one in the dumpster where it belongs.",
+	"Nah. Ask me something else before I go back to eating chocolate out of the trash.",
+}
+
+// newModerator builds the output moderator, preferring the provider endpoint when an
+// OpenAI key is configured and falling back to the local regex classifier otherwise
+func newModerator(aiClient ai.Client, openAIEnabled bool, blocklistPath string) (*moderation.Moderator, error) {
+	extra := map[string][]string{}
+	if blocklistPath != "" {
+		patterns, err := loadPatternFile(blocklistPath)
+		if err != nil {
+			return nil, err
+		}
+		extra[moderation.CategoryBlocklist] = patterns
+	}
+
+	local, err := moderation.NewLocalClassifier(extra)
+	if err != nil {
+		return nil, fmt.Errorf("invalid moderation blocklist pattern: %w", err)
+	}
+
+	if !openAIEnabled {
+		return moderation.NewModerator(local, nil), nil
+	}
+
+	// Blocklist patterns are server specific, so the local classifier always runs alongside the endpoint
+	return moderation.NewModerator(moderation.Combine(moderation.NewProviderClassifier(aiClient), local), local), nil
+}
+
+// loadPatternFile reads one regular expression per line, skipping blanks and # comments
+func loadPatternFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pattern file: %w", err)
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pattern file: %w", err)
+	}
+
+	return patterns, nil
+}
+
+// moderationLevel returns the effective moderation level for a guild
+func (b *Bot) moderationLevel(guildID string) moderation.Level {
+	if b.settings != nil {
+		if level, err := moderation.ParseLevel(b.settings.Guild(guildID).ModerationLevel); err == nil {
+			return level
+		}
+	}
+	return b.defaultModeration
+}
+
+// moderateResponse checks generated output before it is posted. Flagged output is
+// replaced with a safe persona retort and the incident is logged without its content.
+func (b *Bot) moderateResponse(ctx context.Context, m *discordgo.MessageCreate, command, response string) string {
+	if b.moderator == nil {
+		return response
+	}
+
+	level := b.moderationLevel(m.GuildID)
+	verdict, err := b.moderator.Check(ctx, response, level)
+	if err != nil {
+		// Fail closed: if we can't classify it, we don't post it
+		b.logger.ErrorContext(ctx, "moderation check failed",
+			"command", command,
+			"guild_id", m.GuildID,
+			"error", err)
+		return randomSafeRetort()
+	}
+
+	if !verdict.Blocked {
+		return response
+	}
+
+	b.logger.WarnContext(ctx, "moderation blocked response",
+		"command", command,
+		"guild_id", m.GuildID,
+		"channel_id", m.ChannelID,
+		"user_id", m.Author.ID,
+		"level", level,
+		"categories", verdict.Categories,
+		"response_length", len(response))
+
+	return randomSafeRetort()
+}
+
+// randomSafeRetort picks a replacement line for blocked output
+func randomSafeRetort() string {
+	return safeRetorts[rand.Intn(len(safeRetorts))]
+}
+
+// handleModeration handles the !moderation command
+func (b *Bot) handleModeration(ctx context.Context, req *commandRequest) {
+	m := req.m
+	value, given := req.value("level")
+
+	if !given {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Moderation level is **%s**. Usage: %s",
+			b.moderationLevel(m.GuildID), req.cmd.usage()))
+		return
+	}
+
+	if !b.hasPermission(m, discordgo.PermissionManageGuild) {
+		b.send(ctx, m.ChannelID, "You need the Manage Server permission to change moderation settings.")
+		return
+	}
+
+	level, err := moderation.ParseLevel(value)
+	if err != nil {
+		b.send(ctx, m.ChannelID, err.Error())
+		return
+	}
+
+	err = b.settings.UpdateGuild(m.GuildID, func(gs *settings.GuildSettings) {
+		gs.ModerationLevel = string(level)
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to save guild settings", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error saving settings: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "moderation level changed",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"level", level)
+
+	b.send(ctx, m.ChannelID, fmt.Sprintf("Moderation level set to **%s**.", level))
+}
+
+// hasPermission reports whether the message author holds a permission in the channel
+func (b *Bot) hasPermission(m *discordgo.MessageCreate, permission int64) bool {
+	perms, err := b.session.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		return false
+	}
+	return perms&permission != 0 || perms&discordgo.PermissionAdministrator != 0
+}
@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+	"github.com/Dmetrikx/goDiscordChatter/internal/vectors"
+)
+
+// newVectorIndex opens the embeddings index used by !recall, or returns nil when recall
+// is disabled. Embeddings come from OpenAI and are built from the archive, so both are
+// required.
+func newVectorIndex(cfg *config.Config) (*vectors.Index, error) {
+	if !cfg.RecallEnabled || !cfg.ArchiveEnabled || cfg.OpenAIAPIKey == "" {
+		return nil, nil
+	}
+	return vectors.Open(filepath.Join(cfg.DataDir, VectorsFile))
+}
+
+// indexChannel embeds up to limit archived messages newer than the channel's index cursor
+// and returns how many messages it went through. Only messages worth recalling are
+// embedded: bot messages, commands, short messages and messages by members who opted out
+// of analysis are skipped. Text is redacted before it is sent for embedding. Channels
+// that haven't been backfilled yet are left alone, and indexing stops at a message whose
+// author's roles couldn't be checked, so it is retried on the next pass.
+func (b *Bot) indexChannel(ctx context.Context, channelID string, limit int) (int, error) {
+	b.indexing.Lock()
+	defer b.indexing.Unlock()
+
+	if covered, _, err := b.archive.Covered(channelID); err != nil || covered == "" {
+		return 0, err
+	}
+	cursor, err := b.vectors.Cursor(channelID)
+	if err != nil {
+		return 0, err
+	}
+
+	read := 0
+	for read < limit {
+		batch, err := b.archive.After(channelID, cursor, min(ai.MaxEmbeddingBatch, limit-read))
+		if err != nil || len(batch) == 0 {
+			return read, err
+		}
+
+		var ids, texts []string
+		var lookupErr error
+		for i, msg := range batch {
+			if !recallable(msg) {
+				continue
+			}
+			allowed, err := b.checkTarget(msg.GuildID, msg.Author.ID, settings.OptOutAnalysis)
+			if err != nil {
+				batch, lookupErr = batch[:i], fmt.Errorf("failed to check consent of %s: %w", msg.Author.ID, err)
+				break
+			}
+			if !allowed {
+				continue
+			}
+			ids = append(ids, msg.ID)
+			texts = append(texts, fmt.Sprintf("%s: %s", b.displayName(msg.GuildID, msg.Author), msg.Content))
+		}
+		if len(batch) == 0 {
+			return read, lookupErr
+		}
+
+		var entries []vectors.Entry
+		if len(texts) > 0 {
+			embeddings, err := b.aiClient.Embed(ctx, b.redactPII(ctx, batch[0].GuildID, "recall index", texts))
+			if err != nil {
+				return read, err
+			}
+			for i, embedding := range embeddings {
+				entries = append(entries, vectors.Entry{MessageID: ids[i], Vector: embedding})
+			}
+		}
+
+		cursor = batch[len(batch)-1].ID
+		if err := b.vectors.Add(channelID, entries, cursor); err != nil {
+			return read, err
+		}
+		read += len(batch)
+		if lookupErr != nil {
+			return read, lookupErr
+		}
+	}
+	return read, nil
+}
+
+// rewindRecallCursor moves a channel's recall cursor back to just before oldestID when it
+// has already passed it. Backfill stores messages older than ones indexed since a
+// restart, and the cursor only moves forwards, so they would never be embedded otherwise.
+func (b *Bot) rewindRecallCursor(channelID, oldestID string) error {
+	b.indexing.Lock()
+	defer b.indexing.Unlock()
+
+	cursor, err := b.vectors.Cursor(channelID)
+	if err != nil || cursor == "" || snowflakeBefore(cursor, oldestID) {
+		return err
+	}
+	previous, err := snowflake.Prev(oldestID)
+	if err != nil {
+		return err
+	}
+	return b.vectors.Add(channelID, nil, previous)
+}
+
+// recallable reports whether a message says enough to be worth finding with !recall
+func recallable(msg *discordgo.Message) bool {
+	if msg.Author == nil || msg.Author.Bot || strings.HasPrefix(msg.Content, "!") {
+		return false
+	}
+	return len(strings.Fields(msg.Content)) >= MinRecallWords
+}
+
+// recallSources loads the archived messages behind search matches, dropping weak matches,
+// messages deleted since they were indexed and members who opted out since
+func (b *Bot) recallSources(ctx context.Context, guildID string, matches []vectors.Match) []*discordgo.Message {
+	var sources []*discordgo.Message
+	for _, match := range matches {
+		if match.Score < MinRecallScore || len(sources) == MaxRecallMatches {
+			break
+		}
+		msg, err := b.archive.Message(match.ChannelID, match.MessageID)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "failed to load recalled message", "message_id", match.MessageID, "error", err)
+			continue
+		}
+		if msg == nil || msg.Author == nil || !b.canTarget(guildID, msg.Author.ID, settings.OptOutAnalysis) {
+			continue
+		}
+		sources = append(sources, msg)
+	}
+	return sources
+}
+
+// handleRecall handles the !recall command
+func (b *Bot) handleRecall(ctx context.Context, req *commandRequest) {
+	m := req.m
+	question := req.text("question")
+	if question == "" {
+		b.sendUsage(ctx, req, "")
+		return
+	}
+	if b.vectors == nil {
+		b.send(ctx, m.ChannelID, "Recall isn't enabled on this bot.")
+		return
+	}
+
+	provider := req.provider
+	model := ai.DefaultGrokModel
+	persona := ai.GrokPersona
+	if provider == ai.ProviderOpenAI {
+		model = ai.DefaultOpenAIModel
+		persona = ai.OpenAIPersona
+	}
+	model = req.modelOr(model)
+
+	b.send(ctx, m.ChannelID, "Digging through the archives...")
+
+	// Catch the index up on recent messages so the last few minutes are searchable too
+	channels := b.readableChannels(m)
+	for _, channelID := range channels {
+		if _, err := b.indexChannel(ctx, channelID, RecallTopUpMessages); err != nil {
+			b.logger.ErrorContext(ctx, "failed to index channel", "channel_id", channelID, "error", err)
+		}
+	}
+
+	embeddings, err := b.aiClient.Embed(ctx, []string{question})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "embedding request failed", "command", "recall", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+	matches, err := b.vectors.Search(channels, embeddings[0], 2*MaxRecallMatches)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "vector search failed", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error searching the archive: %v", err))
+		return
+	}
+
+	sources := b.recallSources(ctx, m.GuildID, matches)
+	if len(sources) == 0 {
+		b.send(ctx, m.ChannelID, "I couldn't find anything about that in the archive.")
+		return
+	}
+
+	lines := make([]string, len(sources))
+	links := make([]string, len(sources))
+	for i, msg := range sources {
+		lines[i] = fmt.Sprintf("[%d] %s %s: %s", i+1, msg.Timestamp.Format("2006-01-02"), b.displayName(m.GuildID, msg.Author), msg.Content)
+		links[i] = fmt.Sprintf("[%d] %s", i+1, messageLink(m.GuildID, msg.ChannelID, msg.ID))
+	}
+	lines = b.redactPII(ctx, m.GuildID, "recall", lines)
+
+	systemMessage := buildSystemMessage(persona, "The user turn contains numbered messages from this server's history "+
+		"that may be relevant to the question. Answer the question from them, citing the messages you used like [1]. "+
+		"If they don't answer it, say you don't remember.")
+	prompt := buildDataPrompt(question, untrustedBlock("archived messages", strings.Join(lines, "\n")))
+
+	response, err := b.aiClient.AskClient(ctx, prompt, systemMessage, model, provider, ai.DefaultMaxTokens)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "AI request failed", "command", "recall", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error: %v", err))
+		return
+	}
+
+	b.sendLongResponse(ctx, m.ChannelID, b.moderateResponse(ctx, m, "recall", response))
+	b.sendList(ctx, m.ChannelID, "**Sources**", links)
+}
@@ -0,0 +1,252 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
+	"github.com/Dmetrikx/goDiscordChatter/internal/vectors"
+)
+
+// newRecallTestBot returns a bot with an archive and recall index, and the mock AI client
+func newRecallTestBot(t *testing.T) (*Bot, *mockDiscordSession, *mockAIClient) {
+	t.Helper()
+	bot, session := newArchiveTestBot(t, &config.Config{ArchiveBackfill: 1000})
+	index, err := vectors.Open(filepath.Join(t.TempDir(), VectorsFile))
+	if err != nil {
+		t.Fatalf("vectors.Open() error = %v", err)
+	}
+	t.Cleanup(func() { index.Close() })
+
+	mockAI := &mockAIClient{}
+	bot.vectors = index
+	bot.aiClient = mockAI
+	bot.commands = mustCommandRegistry(t)
+	bot.cooldowns = newCooldownTracker()
+	return bot, session, mockAI
+}
+
+// archiveTestMessages archives messages in a channel, a minute apart and ending now
+func archiveTestMessages(t *testing.T, bot *Bot, channelID string, messages ...*discordgo.Message) {
+	t.Helper()
+	now := time.Now()
+	for i, msg := range messages {
+		sent := now.Add(-time.Duration(len(messages)-i) * time.Minute)
+		msg.ID, msg.ChannelID, msg.GuildID, msg.Timestamp = testSnowflake(sent), channelID, "guild", sent
+		if err := bot.archive.Put(msg); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	if err := bot.archive.MarkCovered("guild", channelID, "0", ""); err != nil {
+		t.Fatalf("MarkCovered() error = %v", err)
+	}
+}
+
+func TestHandleRecall(t *testing.T) {
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+	erin := &discordgo.User{ID: "2", Username: "erin"}
+	quiet := &discordgo.User{ID: "3", Username: "quiet"}
+	coonbot := &discordgo.User{ID: "4", Username: "coonbot", Bot: true}
+
+	bot, session, mockAI := newRecallTestBot(t)
+	store, err := settings.NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	store.UpdateGuild("guild", func(gs *settings.GuildSettings) { gs.SetOptOut(quiet.ID, settings.OptOutAnalysis, true) })
+	bot.settings = store
+
+	general := []*discordgo.Message{
+		{Author: dave, Content: "we booked the vegas trip for march"},
+		{Author: coonbot, Content: "the vegas trip sounds fun"},
+		{Author: quiet, Content: "i will skip the vegas trip"},
+		{Author: erin, Content: "vegas"},
+		{Author: erin, Content: "!recall vegas trip plans"},
+	}
+	random := []*discordgo.Message{{Author: erin, Content: "flights for the vegas trip are cheap"}}
+	secret := []*discordgo.Message{{Author: erin, Content: "surprise party at the vegas trip"}}
+	archiveTestMessages(t, bot, "channel", general...)
+	archiveTestMessages(t, bot, "random", random...)
+	archiveTestMessages(t, bot, "secret", secret...)
+	session.permissions = map[string]int64{"random": discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory}
+
+	bot.dispatch(context.Background(), "recall", guildMessage(erin), strings.Fields("when is the vegas trip"))
+
+	for _, text := range mockAI.embedded {
+		for _, skipped := range []string{"sounds fun", "skip the", "!recall", "surprise"} {
+			if strings.Contains(text, skipped) {
+				t.Errorf("embedded %q, which should have been skipped", text)
+			}
+		}
+	}
+
+	if len(mockAI.prompts) != 1 {
+		t.Fatalf("sent %d prompts, want 1", len(mockAI.prompts))
+	}
+	prompt := mockAI.prompts[0]
+	for _, want := range []string{"dave: we booked the vegas trip for march", "erin: flights for the vegas trip are cheap"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+
+	sent := strings.Join(session.sentMessages, "\n")
+	for _, want := range []string{
+		"**Sources**",
+		messageLink("guild", "channel", general[0].ID),
+		messageLink("guild", "random", random[0].ID),
+	} {
+		if !strings.Contains(sent, want) {
+			t.Errorf("sent messages are missing %q:\n%s", want, sent)
+		}
+	}
+	if strings.Contains(sent, "/secret/") {
+		t.Errorf("recall linked a channel the member can't read:\n%s", sent)
+	}
+}
+
+func TestIndexChannelIsIncremental(t *testing.T) {
+	bot, _, mockAI := newRecallTestBot(t)
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+	ctx := context.Background()
+
+	archiveTestMessages(t, bot, "channel",
+		&discordgo.Message{Author: dave, Content: "first message worth indexing"},
+		&discordgo.Message{Author: dave, Content: "second message worth indexing"},
+	)
+	if read, err := bot.indexChannel(ctx, "channel", 1000); err != nil || read != 2 {
+		t.Fatalf("indexChannel() = %d, %v, want 2", read, err)
+	}
+
+	later := &discordgo.Message{
+		ID: testSnowflake(time.Now()), ChannelID: "channel", GuildID: "guild",
+		Author: dave, Content: "third message worth indexing",
+	}
+	bot.archive.Put(later)
+	if read, err := bot.indexChannel(ctx, "channel", 1000); err != nil || read != 1 {
+		t.Fatalf("second indexChannel() = %d, %v, want only the new message", read, err)
+	}
+	if len(mockAI.embedded) != 3 {
+		t.Errorf("embedded %d texts, want each message once", len(mockAI.embedded))
+	}
+
+	// Deleted messages drop out of the index
+	bot.messageDeleteHandler(nil, &discordgo.MessageDelete{Message: &discordgo.Message{ID: later.ID, ChannelID: "channel"}})
+	query, _ := mockAI.Embed(ctx, []string{"third message"})
+	matches, err := bot.vectors.Search([]string{"channel"}, query[0], 10)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	for _, match := range matches {
+		if match.MessageID == later.ID {
+			t.Error("deleted message is still indexed")
+		}
+	}
+	if len(matches) != 2 {
+		t.Errorf("Search() returned %d matches, want 2", len(matches))
+	}
+}
+
+func TestIndexChannelAfterBackfill(t *testing.T) {
+	bot, session, mockAI := newRecallTestBot(t)
+	ctx := context.Background()
+	history := testHistory(10)
+	for _, msg := range history {
+		msg.Content += " is worth indexing"
+	}
+
+	session.history = history[5:]
+	if err := bot.backfillChannel(ctx, "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+
+	// A live message is indexed before backfill catches up on the ones sent while the
+	// bot was down
+	history[0].GuildID = "guild"
+	bot.archiveMessage(ctx, history[0])
+	if _, err := bot.indexChannel(ctx, "channel", 1000); err != nil {
+		t.Fatalf("indexChannel() error = %v", err)
+	}
+	session.history = history
+	if err := bot.backfillChannel(ctx, "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+	if _, err := bot.indexChannel(ctx, "channel", 1000); err != nil {
+		t.Fatalf("indexChannel() after backfill error = %v", err)
+	}
+
+	for _, msg := range history {
+		if !slices.ContainsFunc(mockAI.embedded, func(text string) bool { return strings.HasSuffix(text, ": "+msg.Content) }) {
+			t.Errorf("%q was never embedded", msg.Content)
+		}
+	}
+}
+
+func TestIndexChannelRetriesFailedLookups(t *testing.T) {
+	bot, session, mockAI := newRecallTestBot(t)
+	store, err := settings.NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	store.UpdateGuild("guild", func(gs *settings.GuildSettings) { gs.SetProtectedRole("admins", true) })
+	bot.settings = store
+	ctx := context.Background()
+
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+	archiveTestMessages(t, bot, "channel",
+		&discordgo.Message{Author: dave, Content: "first message worth indexing"},
+		&discordgo.Message{Author: dave, Content: "second message worth indexing"},
+	)
+
+	// Members whose roles can't be fetched aren't embedded, and aren't skipped for good
+	session.memberErr = fmt.Errorf("discord is down")
+	if read, err := bot.indexChannel(ctx, "channel", 1000); err == nil || read != 0 {
+		t.Errorf("indexChannel() during an outage = %d, %v, want 0 and an error", read, err)
+	}
+	if len(mockAI.embedded) != 0 {
+		t.Errorf("embedded %q during an outage", mockAI.embedded)
+	}
+
+	session.memberErr = nil
+	if read, err := bot.indexChannel(ctx, "channel", 1000); err != nil || read != 2 {
+		t.Errorf("indexChannel() after the outage = %d, %v, want 2", read, err)
+	}
+	if len(mockAI.embedded) != 2 {
+		t.Errorf("embedded %d texts after the outage, want 2", len(mockAI.embedded))
+	}
+}
+
+func TestIndexChannelWaitsForBackfill(t *testing.T) {
+	bot, _, mockAI := newRecallTestBot(t)
+	msg := &discordgo.Message{
+		ID: testSnowflake(time.Now()), ChannelID: "channel", GuildID: "guild",
+		Author: &discordgo.User{ID: "1", Username: "dave"}, Content: "live message worth indexing",
+	}
+	bot.archiveMessage(context.Background(), msg)
+
+	if read, err := bot.indexChannel(context.Background(), "channel", 1000); err != nil || read != 0 {
+		t.Errorf("indexChannel() before backfill = %d, %v, want 0", read, err)
+	}
+	if cursor, _ := bot.vectors.Cursor("channel"); cursor != "" || len(mockAI.embedded) != 0 {
+		t.Errorf("cursor = %q, embedded %q before backfill, want nothing", cursor, mockAI.embedded)
+	}
+}
+
+func TestRecallDisabled(t *testing.T) {
+	bot, session, _ := newRecallTestBot(t)
+	bot.vectors = nil
+
+	bot.dispatch(context.Background(), "recall", guildMessage(&discordgo.User{ID: "1"}), []string{"anything"})
+
+	if len(session.sentMessages) != 1 || !strings.Contains(session.sentMessages[0], "isn't enabled") {
+		t.Errorf("sent %q, want a disabled notice", session.sentMessages)
+	}
+}
@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/redact"
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
+)
+
+// newRedactor builds the PII redactor from the built-in rules and an optional pattern file
+func newRedactor(patternsPath string) (*redact.Redactor, error) {
+	var custom []string
+	if patternsPath != "" {
+		patterns, err := loadPatternFile(patternsPath)
+		if err != nil {
+			return nil, err
+		}
+		custom = patterns
+	}
+	return redact.NewRedactor(custom)
+}
+
+// piiRedactionEnabled reports whether chat history is redacted for a guild
+func (b *Bot) piiRedactionEnabled(guildID string) bool {
+	if b.settings != nil {
+		switch b.settings.Guild(guildID).PIIRedaction {
+		case "on":
+			return true
+		case "off":
+			return false
+		}
+	}
+	return b.config == nil || b.config.PIIRedaction
+}
+
+// redactPII masks personal information in history before it is sent to an AI provider.
+// Only redaction counts are logged, never the matched text.
+func (b *Bot) redactPII(ctx context.Context, guildID, source string, lines []string) []string {
+	if b.redactor == nil || !b.piiRedactionEnabled(guildID) {
+		return lines
+	}
+
+	total := redact.Counts{}
+	redacted := make([]string, len(lines))
+	for i, line := range lines {
+		var counts redact.Counts
+		redacted[i], counts = b.redactor.Redact(line)
+		total.Add(counts)
+	}
+
+	if total.Total() > 0 {
+		b.logger.InfoContext(ctx, "redacted PII from history",
+			"guild_id", guildID,
+			"source", source,
+			"total", total.Total(),
+			"counts", map[string]int(total))
+	}
+
+	return redacted
+}
+
+// handleRedaction handles the !redaction command
+func (b *Bot) handleRedaction(ctx context.Context, req *commandRequest) {
+	m := req.m
+	state, given := req.value("state")
+
+	if !given {
+		state := "off"
+		if b.piiRedactionEnabled(m.GuildID) {
+			state = "on"
+		}
+		var rules string
+		if b.redactor != nil {
+			rules = fmt.Sprintf(" Masking: %s.", strings.Join(b.redactor.RuleNames(), ", "))
+		}
+		b.send(ctx, m.ChannelID, fmt.Sprintf("PII redaction is **%s**.%s Usage: %s", state, rules, req.cmd.usage()))
+		return
+	}
+
+	if !b.hasPermission(m, discordgo.PermissionManageGuild) {
+		b.send(ctx, m.ChannelID, "You need the Manage Server permission to change redaction settings.")
+		return
+	}
+
+	value := strings.ToLower(state)
+	if value != "on" && value != "off" {
+		b.sendUsage(ctx, req, "")
+		return
+	}
+
+	err := b.settings.UpdateGuild(m.GuildID, func(gs *settings.GuildSettings) {
+		gs.PIIRedaction = value
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to save guild settings", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error saving settings: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "PII redaction changed",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"redaction", value)
+
+	b.send(ctx, m.ChannelID, fmt.Sprintf("PII redaction is now **%s**.", value))
+}
@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/settings"
+)
+
+// roastIntensity controls how harsh and explicit a roast is allowed to be
+type roastIntensity int
+
+// Roast intensity levels, from gentlest to harshest
+const (
+	roastMild roastIntensity = iota
+	roastSpicy
+	roastSavage
+)
+
+// DefaultRoastIntensity is used when the command doesn't request a level
+const DefaultRoastIntensity = roastSpicy
+
+// roastIntensityNames maps intensity levels to their command names
+var roastIntensityNames = []string{"mild", "spicy", "savage"}
+
+// String returns the command name of the intensity
+func (r roastIntensity) String() string {
+	return roastIntensityNames[r]
+}
+
+// parseRoastIntensity converts a command argument to an intensity level
+func parseRoastIntensity(s string) (roastIntensity, bool) {
+	for i, name := range roastIntensityNames {
+		if strings.EqualFold(s, name) {
+			return roastIntensity(i), true
+		}
+	}
+	return 0, false
+}
+
+// instructions returns the system prompt guidance for the intensity
+func (r roastIntensity) instructions() string {
+	switch r {
+	case roastMild:
+		return "Keep it playful and good-natured, like ribbing a friend at a barbecue. " +
+			"No profanity, nothing about appearance, identity or anything actually hurtful."
+	case roastSavage:
+		return "Be a Boston comedian who grew up in the Bronx and go all out: be really, really mean. " +
+			"Crude and explicit humor is fine here, but no slurs."
+	default:
+		return "Be a Boston comedian who grew up in the Bronx: sharp, sarcastic and a little mean. " +
+			"Mild profanity is fine, but keep it clean enough for a general channel."
+	}
+}
+
+// effectiveRoastIntensity clamps the requested intensity to the guild cap. The
+// explicit (savage) tier is only permitted in channels Discord marks as NSFW.
+func effectiveRoastIntensity(requested, guildCap roastIntensity, nsfw bool) roastIntensity {
+	effective := requested
+	if effective > guildCap {
+		effective = guildCap
+	}
+	if effective == roastSavage && !nsfw {
+		effective = roastSpicy
+	}
+	return effective
+}
+
+// roastIntensityCap returns the guild's maximum roast intensity
+func (b *Bot) roastIntensityCap(guildID string) roastIntensity {
+	if b.settings != nil {
+		if level, ok := parseRoastIntensity(b.settings.Guild(guildID).MaxRoastIntensity); ok {
+			return level
+		}
+	}
+	return roastSavage
+}
+
+// resolveRoastIntensity picks the intensity for a roast from the requested level name,
+// the guild cap and the channel's NSFW flag. The returned note explains any downgrade.
+func (b *Bot) resolveRoastIntensity(m *discordgo.MessageCreate, requestedName string) (roastIntensity, string) {
+	requested := DefaultRoastIntensity
+	if level, ok := parseRoastIntensity(requestedName); ok {
+		requested = level
+	}
+
+	nsfw := false
+	if channel, err := b.lookupChannel(m.ChannelID); err == nil {
+		nsfw = channel.NSFW
+	}
+
+	guildCap := b.roastIntensityCap(m.GuildID)
+	effective := effectiveRoastIntensity(requested, guildCap, nsfw)
+
+	var note string
+	if effective < requested {
+		if requested == roastSavage && !nsfw && guildCap == roastSavage {
+			note = "Savage roasts only happen in NSFW channels, toning it down to spicy."
+		} else {
+			note = fmt.Sprintf("This server caps roasts at %s.", effective)
+		}
+	}
+
+	return effective, note
+}
+
+// handleRoastCap handles the !roast_cap command
+func (b *Bot) handleRoastCap(ctx context.Context, req *commandRequest) {
+	m := req.m
+	value, given := req.value("level")
+
+	if !given {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Roasts are capped at **%s**. Usage: %s",
+			b.roastIntensityCap(m.GuildID), req.cmd.usage()))
+		return
+	}
+
+	if !b.hasPermission(m, discordgo.PermissionManageGuild) {
+		b.send(ctx, m.ChannelID, "You need the Manage Server permission to change the roast cap.")
+		return
+	}
+
+	level, ok := parseRoastIntensity(value)
+	if !ok {
+		b.sendUsage(ctx, req, "")
+		return
+	}
+
+	err := b.settings.UpdateGuild(m.GuildID, func(gs *settings.GuildSettings) {
+		gs.MaxRoastIntensity = level.String()
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "failed to save guild settings", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error saving settings: %v", err))
+		return
+	}
+
+	b.logger.InfoContext(ctx, "roast cap changed",
+		"guild_id", m.GuildID,
+		"user_id", m.Author.ID,
+		"cap", level.String())
+
+	b.send(ctx, m.ChannelID, fmt.Sprintf("Roasts are now capped at **%s**.", level))
+}
@@ -0,0 +1,235 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/cmdparse"
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/search"
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+// newSearchIndex opens the keyword index used by !search, or returns nil when the
+// archive it is built from is disabled
+func newSearchIndex(cfg *config.Config) (*search.Index, error) {
+	if !cfg.ArchiveEnabled {
+		return nil, nil
+	}
+	return search.Open(filepath.Join(cfg.DataDir, SearchFile))
+}
+
+// indexSearchChannel adds archived messages newer than the channel's search cursor to
+// the search index. Indexing is local and re-indexing a message replaces it, so unlike
+// recall this needs no limit or lock. Channels that haven't been backfilled yet are left
+// alone; backfill indexes the older messages it stores itself.
+func (b *Bot) indexSearchChannel(ctx context.Context, channelID string) error {
+	if b.search == nil {
+		return nil
+	}
+	if covered, _, err := b.archive.Covered(channelID); err != nil || covered == "" {
+		return err
+	}
+	cursor, err := b.search.Cursor(channelID)
+	if err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		batch, err := b.archive.After(channelID, cursor, MaxMessagesPerRequest)
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		cursor = batch[len(batch)-1].ID
+		if err := b.search.Add(channelID, searchDocuments(batch), cursor); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// searchDocuments converts the messages worth indexing for search
+func searchDocuments(messages []*discordgo.Message) []search.Document {
+	var docs []search.Document
+	for _, msg := range messages {
+		if msg.Author != nil && msg.Content != "" {
+			docs = append(docs, searchDocument(msg))
+		}
+	}
+	return docs
+}
+
+// searchDocument converts a message for the search index
+func searchDocument(msg *discordgo.Message) search.Document {
+	return search.Document{
+		ChannelID: msg.ChannelID,
+		MessageID: msg.ID,
+		AuthorID:  msg.Author.ID,
+		Content:   msg.Content,
+	}
+}
+
+// parseSearchQuery splits !search input into search terms and the from:, in:, before:
+// and after: filters. Dates are UTC days, as in Discord's search: before:2024-05-01
+// means before that day starts and after:2024-05-01 means after it ends. Durations
+// count back from now, so after:7d is the last week.
+func parseSearchQuery(tokens []string, now time.Time) (search.Query, error) {
+	var query search.Query
+	var text []string
+	for i := 0; i < len(tokens); i++ {
+		filter, value, ok := strings.Cut(tokens[i], ":")
+		filter = strings.ToLower(filter)
+		if !ok || !slices.Contains([]string{"from", "in", "before", "after"}, filter) {
+			text = append(text, tokens[i])
+			continue
+		}
+		// Allow a space after the colon, e.g. "from: @dave"
+		if value == "" && i+1 < len(tokens) {
+			i++
+			value = tokens[i]
+		}
+
+		switch filter {
+		case "from":
+			id, ok := cmdparse.UserID(value)
+			if !ok && !cmdparse.IsInt(value) {
+				return query, fmt.Errorf("the from: filter needs a member mention, got %q", value)
+			}
+			if !ok {
+				id = value
+			}
+			query.AuthorID = id
+		case "in":
+			id, ok := cmdparse.ChannelID(value)
+			if !ok && !cmdparse.IsInt(value) {
+				return query, fmt.Errorf("the in: filter needs a channel like #general, got %q", value)
+			}
+			if !ok {
+				id = value
+			}
+			query.ChannelIDs = []string{id}
+		case "before", "after":
+			t, err := parseSearchTime(filter, value, now)
+			if err != nil {
+				return query, err
+			}
+			if filter == "before" {
+				query.BeforeID = snowflake.At(t)
+			} else {
+				query.AfterID = snowflake.At(t)
+			}
+		}
+	}
+
+	query.Terms = search.Terms(strings.Join(text, " "))
+	return query, nil
+}
+
+// parseSearchTime converts a before: or after: value to the time it bounds
+func parseSearchTime(filter, value string, now time.Time) (time.Time, error) {
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if filter == "after" {
+			return day.AddDate(0, 0, 1), nil
+		}
+		return day, nil
+	}
+	if d, err := cmdparse.ParseDuration(filter, value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("the %s: filter needs a date like 2024-05-01 or a duration like 7d, got %q", filter, value)
+}
+
+// handleSearch handles the !search command
+func (b *Bot) handleSearch(ctx context.Context, req *commandRequest) {
+	m := req.m
+	tokens, err := cmdparse.Tokenize(req.text("query"))
+	if err != nil {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("%s. Usage: %s (see `!help search`)", capitalize(err.Error()), req.cmd.usage()))
+		return
+	}
+	query, err := parseSearchQuery(tokens, time.Now())
+	if err != nil {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("%s. Usage: %s (see `!help search`)", capitalize(err.Error()), req.cmd.usage()))
+		return
+	}
+	if len(query.Terms) == 0 && query.AuthorID == "" {
+		b.sendUsage(ctx, req, "")
+		return
+	}
+	if b.search == nil {
+		b.send(ctx, m.ChannelID, "Search needs the message archive, which is turned off on this bot.")
+		return
+	}
+
+	// Only channels the member can read are searched
+	readable := b.readableChannels(m)
+	if len(query.ChannelIDs) == 0 {
+		query.ChannelIDs = readable
+	} else if !slices.Contains(readable, query.ChannelIDs[0]) {
+		b.send(ctx, m.ChannelID, fmt.Sprintf("I can't search <#%s>: it isn't archived or you can't read it.", query.ChannelIDs[0]))
+		return
+	}
+
+	// Catch up on messages archived since the last indexing pass
+	for _, channelID := range query.ChannelIDs {
+		if err := b.indexSearchChannel(ctx, channelID); err != nil {
+			b.logger.ErrorContext(ctx, "failed to update search index", "channel_id", channelID, "error", err)
+		}
+	}
+
+	query.Limit = MaxSearchResults
+	results, total, err := b.search.Search(query)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "search failed", "error", err)
+		b.send(ctx, m.ChannelID, fmt.Sprintf("Error searching the archive: %v", err))
+		return
+	}
+	if total == 0 {
+		b.send(ctx, m.ChannelID, "No messages found.")
+		return
+	}
+
+	if _, err := b.sendEmbed(ctx, m.ChannelID, b.searchEmbed(m.GuildID, results, total)); err != nil {
+		b.logger.ErrorContext(ctx, "failed to send search results", "error", err)
+	}
+}
+
+// searchEmbed lists search results with their author, channel, age and a jump link
+func (b *Bot) searchEmbed(guildID string, results []search.Result, total int) *discordgo.MessageEmbed {
+	var entries []string
+	for _, result := range results {
+		msg, err := b.archive.Message(result.ChannelID, result.MessageID)
+		if err != nil || msg == nil || msg.Author == nil {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("**%s** in <#%s> <t:%d:R> · [Jump](%s)\n> %s",
+			b.displayName(guildID, msg.Author),
+			msg.ChannelID,
+			msg.Timestamp.Unix(),
+			messageLink(guildID, msg.ChannelID, msg.ID),
+			searchSnippet(msg.Content)))
+	}
+
+	footer := fmt.Sprintf("%d matches, newest first", total)
+	if total > len(results) {
+		footer = fmt.Sprintf("Showing %d of %d matches, newest first", len(results), total)
+	}
+	return &discordgo.MessageEmbed{
+		Title:       "Search results",
+		Description: strings.Join(entries, "\n\n"),
+		Color:       SearchEmbedColor,
+		Footer:      &discordgo.MessageEmbedFooter{Text: footer},
+	}
+}
+
+// searchSnippet flattens a message onto one line and shortens it for the results list
+func searchSnippet(content string) string {
+	return shorten(content, SearchSnippetLength)
+}
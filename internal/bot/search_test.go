@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+	"github.com/Dmetrikx/goDiscordChatter/internal/search"
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    search.Query
+		wantErr string
+	}{
+		{name: "terms only", input: "Vegas trip!", want: search.Query{Terms: []string{"vegas", "trip"}}},
+		{
+			name:  "all filters",
+			input: "hotel from:<@1> in:<#2> before:2024-06-01 after:2024-05-01",
+			want: search.Query{
+				Terms:      []string{"hotel"},
+				AuthorID:   "1",
+				ChannelIDs: []string{"2"},
+				BeforeID:   snowflake.At(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)),
+				AfterID:    snowflake.At(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)),
+			},
+		},
+		{name: "space after the colon", input: "from: <@1>", want: search.Query{AuthorID: "1"}},
+		{name: "duration", input: "ramen after:7d", want: search.Query{Terms: []string{"ramen"}, AfterID: snowflake.At(now.AddDate(0, 0, -7))}},
+		{name: "other colons are text", input: "time 10:30", want: search.Query{Terms: []string{"time", "10", "30"}}},
+		{name: "bad member", input: "from:dave", wantErr: "the from: filter needs a member mention"},
+		{name: "bad date", input: "after:yesterday", wantErr: "the after: filter needs a date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := strings.Fields(tt.input)
+			got, err := parseSearchQuery(tokens, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseSearchQuery() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSearchQuery() error = %v", err)
+			}
+			if !slices.Equal(got.Terms, tt.want.Terms) || got.AuthorID != tt.want.AuthorID ||
+				!slices.Equal(got.ChannelIDs, tt.want.ChannelIDs) ||
+				got.BeforeID != tt.want.BeforeID || got.AfterID != tt.want.AfterID {
+				t.Errorf("parseSearchQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newSearchTestBot returns a bot with an archive and search index
+func newSearchTestBot(t *testing.T) (*Bot, *mockDiscordSession) {
+	t.Helper()
+	bot, session := newArchiveTestBot(t, &config.Config{})
+	index, err := search.Open(filepath.Join(t.TempDir(), SearchFile))
+	if err != nil {
+		t.Fatalf("search.Open() error = %v", err)
+	}
+	t.Cleanup(func() { index.Close() })
+
+	bot.search = index
+	bot.commands = mustCommandRegistry(t)
+	bot.cooldowns = newCooldownTracker()
+	return bot, session
+}
+
+func TestHandleSearch(t *testing.T) {
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+	erin := &discordgo.User{ID: "2", Username: "erin"}
+
+	bot, session := newSearchTestBot(t)
+	general := []*discordgo.Message{
+		{Author: dave, Content: "we booked the vegas trip"},
+		{Author: erin, Content: "pizza tonight"},
+	}
+	random := []*discordgo.Message{{Author: erin, Content: "vegas trip flights are cheap"}}
+	secret := []*discordgo.Message{{Author: erin, Content: "vegas trip surprise"}}
+	archiveTestMessages(t, bot, "channel", general...)
+	archiveTestMessages(t, bot, "200", random...)
+	archiveTestMessages(t, bot, "300", secret...)
+	session.permissions = map[string]int64{"200": discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory}
+
+	tests := []struct {
+		name      string
+		args      string
+		wantLinks []string
+		wantSent  string
+	}{
+		{
+			name:      "readable channels",
+			args:      "Vegas trip",
+			wantLinks: []string{messageLink("guild", "200", random[0].ID), messageLink("guild", "channel", general[0].ID)},
+		},
+		{name: "from", args: "vegas from:<@1>", wantLinks: []string{messageLink("guild", "channel", general[0].ID)}},
+		{name: "in", args: "vegas in:<#200>", wantLinks: []string{messageLink("guild", "200", random[0].ID)}},
+		{name: "unreadable channel", args: "vegas in:<#300>", wantSent: "I can't search <#300>"},
+		{name: "no matches", args: "skiing", wantSent: "No messages found."},
+		{name: "filters need terms or an author", args: "after:7d", wantSent: "Usage: !search"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session.embeds, session.sentMessages = nil, nil
+			bot.cooldowns = newCooldownTracker()
+
+			bot.dispatch(context.Background(), "search", guildMessage(erin), strings.Fields(tt.args))
+
+			if tt.wantSent != "" {
+				if len(session.sentMessages) != 1 || !strings.Contains(session.sentMessages[0], tt.wantSent) {
+					t.Errorf("sent %q, want %q", session.sentMessages, tt.wantSent)
+				}
+				return
+			}
+			if len(session.embeds) != 1 {
+				t.Fatalf("sent %d embeds, want 1 (messages %q)", len(session.embeds), session.sentMessages)
+			}
+			description := session.embeds[0].Description
+			if strings.Count(description, "[Jump]") != len(tt.wantLinks) {
+				t.Errorf("results:\n%s\nwant %d", description, len(tt.wantLinks))
+			}
+			// Newest first
+			last := -1
+			for _, link := range tt.wantLinks {
+				i := strings.Index(description, link)
+				if i < last {
+					t.Errorf("results are missing %s or out of order:\n%s", link, description)
+				}
+				last = i
+			}
+		})
+	}
+}
+
+func TestSearchFollowsEdits(t *testing.T) {
+	bot, session := newSearchTestBot(t)
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+	msg := &discordgo.Message{Author: dave, Content: "meet at the casino"}
+	archiveTestMessages(t, bot, "channel", msg)
+
+	bot.dispatch(context.Background(), "search", guildMessage(dave), []string{"casino"})
+	bot.messageUpdateHandler(nil, &discordgo.MessageUpdate{Message: &discordgo.Message{
+		ID: msg.ID, ChannelID: "channel", Content: "meet at the hotel", Author: dave,
+	}})
+
+	session.embeds, session.sentMessages = nil, nil
+	bot.cooldowns = newCooldownTracker()
+	bot.dispatch(context.Background(), "search", guildMessage(dave), []string{"casino"})
+	if len(session.sentMessages) != 1 || session.sentMessages[0] != "No messages found." {
+		t.Errorf("search for the old wording sent %q, want no results", session.sentMessages)
+	}
+
+	bot.cooldowns = newCooldownTracker()
+	bot.dispatch(context.Background(), "search", guildMessage(dave), []string{"hotel"})
+	if len(session.embeds) != 1 || !strings.Contains(session.embeds[0].Description, "meet at the hotel") {
+		t.Errorf("search for the new wording found %v", session.embeds)
+	}
+}
+
+func TestSearchAfterBackfill(t *testing.T) {
+	bot, session := newSearchTestBot(t)
+	bot.config.ArchiveBackfill = 1000
+	ctx := context.Background()
+	history := testHistory(10)
+	for i, msg := range history {
+		msg.Content = fmt.Sprintf("topic%d", len(history)-i)
+	}
+	dave := &discordgo.User{ID: "1", Username: "dave"}
+
+	// Channels aren't indexed before their first backfill, so live messages don't move
+	// the cursor past the history backfill is about to store
+	history[9].GuildID = "guild"
+	bot.archiveMessage(ctx, history[9])
+	if err := bot.indexSearchChannel(ctx, "channel"); err != nil {
+		t.Fatalf("indexSearchChannel() error = %v", err)
+	}
+	if cursor, _ := bot.search.Cursor("channel"); cursor != "" {
+		t.Errorf("search cursor before backfill = %q, want none", cursor)
+	}
+
+	session.history = history[5:]
+	if err := bot.backfillChannel(ctx, "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+
+	// After a restart a live message is indexed before backfill fetches the messages
+	// sent while the bot was down
+	history[0].GuildID = "guild"
+	bot.archiveMessage(ctx, history[0])
+	bot.dispatch(ctx, "search", guildMessage(dave), []string{"topic10"})
+	session.history = history
+	if err := bot.backfillChannel(ctx, "guild", "channel"); err != nil {
+		t.Fatalf("backfillChannel() error = %v", err)
+	}
+
+	session.embeds, session.sentMessages = nil, nil
+	bot.cooldowns = newCooldownTracker()
+	bot.dispatch(ctx, "search", guildMessage(dave), []string{"topic7"})
+	if len(session.embeds) != 1 || !strings.Contains(session.embeds[0].Description, messageLink("guild", "channel", history[3].ID)) {
+		t.Errorf("search for a message sent while the bot was down found %v, messages %q", session.embeds, session.sentMessages)
+	}
+}
@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/cmdparse"
+)
+
+// providerOption lets a slash command override the AI provider
+var providerOption = &discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionString,
+	Name:        "provider",
+	Description: "AI provider to use",
+	Choices: []*discordgo.ApplicationCommandOptionChoice{
+		{Name: "Grok", Value: ai.ProviderGrok},
+		{Name: "OpenAI", Value: ai.ProviderOpenAI},
+	},
+}
+
+// choices builds string option choices whose names and values are identical
+func choices(values ...string) []*discordgo.ApplicationCommandOptionChoice {
+	out := make([]*discordgo.ApplicationCommandOptionChoice, len(values))
+	for i, v := range values {
+		out[i] = &discordgo.ApplicationCommandOptionChoice{Name: v, Value: v}
+	}
+	return out
+}
+
+// registerSlashCommands replaces the bot's global application commands
+func (b *Bot) registerSlashCommands(ctx context.Context, appID string) error {
+	registered, err := b.session.ApplicationCommandBulkOverwrite(appID, "", b.commands.applicationCommands())
+	if err != nil {
+		return fmt.Errorf("error registering slash commands: %w", err)
+	}
+
+	b.logger.InfoContext(ctx, "registered slash commands", "count", len(registered))
+	return nil
+}
+
+// interactionHandler handles slash command invocations. The response is deferred right
+// away so slow AI calls don't miss Discord's 3-second interaction deadline.
+func (b *Bot) interactionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	// Discord shows an error for interactions that get no response, so commands sent
+	// while the bot is closing are told to try again
+	if !b.beginHandler() {
+		b.rejectInteraction(i.Interaction)
+		return
+	}
+	defer b.handlers.Done()
+	ctx := b.handlerContext()
+
+	data := i.ApplicationCommandData()
+	responder := &interactionResponder{interaction: i.Interaction}
+	if cmd, ok := b.commands.lookup(data.Name); ok {
+		responder.ephemeral = cmd.ephemeral
+	}
+
+	deferred := &discordgo.InteractionResponse{Type: discordgo.InteractionResponseDeferredChannelMessageWithSource}
+	if responder.ephemeral {
+		deferred.Data = &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral}
+	}
+	if err := b.session.InteractionRespond(i.Interaction, deferred); err != nil {
+		b.logger.ErrorContext(ctx, "failed to defer interaction response", "error", err)
+		return
+	}
+
+	m, args := interactionToMessage(i, data)
+
+	b.logger.InfoContext(ctx, "received slash command",
+		"command", data.Name,
+		"user_id", m.Author.ID,
+		"username", m.Author.Username,
+		"channel_id", m.ChannelID,
+		"args_count", len(args))
+
+	b.dispatch(withInteraction(ctx, responder), data.Name, m, args)
+	responder.finish(b)
+}
+
+// rejectInteraction answers a slash command the bot won't run because it is shutting down
+func (b *Bot) rejectInteraction(interaction *discordgo.Interaction) {
+	err := b.session.InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "I'm restarting. Try again in a minute.",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		b.logger.Error("failed to reject interaction during shutdown", "error", err)
+	}
+}
+
+// interactionToMessage converts a slash command into the message and arguments a prefix
+// command would have produced, so both paths share the same handlers. Options become
+// --name=value flags, which bind by name regardless of which ones were skipped.
+func interactionToMessage(i *discordgo.InteractionCreate, data discordgo.ApplicationCommandInteractionData) (*discordgo.MessageCreate, []string) {
+	author := i.User
+	if i.Member != nil && i.Member.User != nil {
+		author = i.Member.User
+	}
+
+	msg := &discordgo.Message{
+		ID:        i.ID,
+		ChannelID: i.ChannelID,
+		GuildID:   i.GuildID,
+		Author:    author,
+		Member:    i.Member,
+	}
+
+	var args []string
+	var content []string
+
+	for _, opt := range data.Options {
+		var value string
+		switch opt.Type {
+		case discordgo.ApplicationCommandOptionString:
+			value = opt.StringValue()
+		case discordgo.ApplicationCommandOptionInteger:
+			value = strconv.FormatInt(opt.IntValue(), 10)
+		case discordgo.ApplicationCommandOptionUser:
+			userID, _ := opt.Value.(string)
+			user := data.Resolved.Users[userID]
+			if user == nil {
+				user = &discordgo.User{ID: userID}
+			}
+			msg.Mentions = append(msg.Mentions, user)
+			value = "<@" + userID + ">"
+		case discordgo.ApplicationCommandOptionRole:
+			roleID, _ := opt.Value.(string)
+			msg.MentionRoles = append(msg.MentionRoles, roleID)
+			value = "<@&" + roleID + ">"
+		case discordgo.ApplicationCommandOptionChannel:
+			channelID, _ := opt.Value.(string)
+			value = "<#" + channelID + ">"
+		case discordgo.ApplicationCommandOptionAttachment:
+			attachmentID, _ := opt.Value.(string)
+			if attachment := data.Resolved.Attachments[attachmentID]; attachment != nil {
+				msg.Attachments = append(msg.Attachments, attachment)
+			}
+			continue
+		}
+
+		args = append(args, cmdparse.FlagPrefix+opt.Name+"="+value)
+		content = append(content, value)
+	}
+
+	msg.Content = strings.TrimSpace("/" + data.Name + " " + strings.Join(content, " "))
+
+	return &discordgo.MessageCreate{Message: msg}, args
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/config"
+)
+
+func TestFormatHistory(t *testing.T) {
+	alice := &discordgo.User{ID: "1", Username: "alice"}
+	bob := &discordgo.User{ID: "2", Username: "bob"}
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	edited := day.Add(time.Hour)
+	question := &discordgo.Message{ID: "10", Author: bob, Content: "cabin or beach?", Timestamp: day}
+
+	tests := []struct {
+		name     string
+		messages []*discordgo.Message
+		captions bool
+		want     []string
+	}{
+		{
+			name: "reply quotes its target and times skip the repeated date",
+			messages: []*discordgo.Message{
+				{ID: "11", Author: alice, Content: "cabin, obviously", Timestamp: day.Add(5 * time.Minute),
+					Type: discordgo.MessageTypeReply, MessageReference: &discordgo.MessageReference{MessageID: "10"}},
+				question,
+			},
+			want: []string{
+				"[May 1 12:00] bob: cabin or beach?",
+				`[12:05] alice (replying to bob: "cabin or beach?"): cabin, obviously`,
+			},
+		},
+		{
+			name: "reply to a message outside the history",
+			messages: []*discordgo.Message{
+				{ID: "11", Author: alice, Content: "lol", Timestamp: day,
+					Type: discordgo.MessageTypeReply, MessageReference: &discordgo.MessageReference{MessageID: "9"}},
+			},
+			want: []string{"[May 1 12:00] alice (replying to an earlier message): lol"},
+		},
+		{
+			name: "new day repeats the date",
+			messages: []*discordgo.Message{
+				{ID: "12", Author: alice, Content: "morning", Timestamp: day.Add(24 * time.Hour)},
+				question,
+			},
+			want: []string{"[May 1 12:00] bob: cabin or beach?", "[May 2 12:00] alice: morning"},
+		},
+		{
+			name: "mentions, emoji and timestamps are resolved",
+			messages: []*discordgo.Message{
+				{ID: "11", Author: alice, Content: "<@!2> <@&77> in <#88> at <t:1714564800:R> <:pog:123>",
+					Mentions: []*discordgo.User{bob}, Timestamp: day},
+			},
+			want: []string{"[May 1 12:00] alice: @bob @role in #channel at May 1 12:00 UTC :pog:"},
+		},
+		{
+			name: "attachments, embeds, stickers and edits",
+			messages: []*discordgo.Message{
+				{
+					ID: "11", Author: alice, Content: "look", Timestamp: day, EditedTimestamp: &edited,
+					Attachments: []*discordgo.MessageAttachment{
+						{ID: "a1", Filename: "cabin.jpg", ContentType: "image/jpeg"},
+						{ID: "a2", Filename: "notes.pdf", ContentType: "application/pdf"},
+					},
+					Embeds: []*discordgo.MessageEmbed{
+						{Type: discordgo.EmbedTypeArticle, Title: "Lakeside Cabins"},
+						{Type: discordgo.EmbedTypeRich, Title: "Poll", Description: "Cabin wins"},
+						{Type: discordgo.EmbedTypeLink},
+					},
+					StickerItems: []*discordgo.StickerItem{{Name: "wave"}},
+				},
+			},
+			want: []string{"[May 1 12:00] alice: look [image: cabin.jpg] [file: notes.pdf] " +
+				"[article: Lakeside Cabins] [embed: Poll - Cabin wins] [sticker: wave] (edited)"},
+		},
+		{
+			name: "image captions when enabled",
+			messages: []*discordgo.Message{
+				{ID: "11", Author: alice, Timestamp: day, Attachments: []*discordgo.MessageAttachment{
+					{ID: "a1", Filename: "cabin.jpg", ContentType: "image/jpeg", URL: "https://cdn.example/cabin.jpg"},
+				}},
+			},
+			captions: true,
+			want:     []string{`[May 1 12:00] alice: [image: cabin.jpg, showing "mock image opinion"]`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := &Bot{
+				session:  &mockDiscordSession{},
+				aiClient: &mockAIClient{},
+				config:   &config.Config{ImageCaptions: tt.captions, OpenAIAPIKey: "key"},
+				location: time.UTC,
+				logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+
+			got := bot.formatHistory(context.Background(), "", tt.messages)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("formatHistory() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Package cmdparse tokenizes chat command lines and converts flag and argument values
+// (numbers, durations, mentions and channel references) into typed values.
+package cmdparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FlagPrefix marks a token as a named flag, e.g. --provider=grok
+const FlagPrefix = "--"
+
+// closingQuotes maps each supported opening quote to its closing quote. Phone keyboards
+// often insert typographic quotes, so those are accepted too.
+var closingQuotes = map[rune]rune{
+	'"':      '"',
+	'\'':     '\'',
+	'\u201c': '\u201d',
+	'\u2018': '\u2019',
+}
+
+// Tokenize splits a command line on whitespace. Quoted sections are kept together as a
+// single token without their quotes; a quote only opens at the start of a token, so
+// apostrophes inside words ("don't") are left alone.
+func Tokenize(input string) ([]string, error) {
+	return tokenize(input, true)
+}
+
+// TokenizeText splits the command line of a free-text command, such as a question. Only
+// quoted flag values (--prompt="make it funny") are unquoted; other quotes are part of
+// the text, so a question starting with an apostrophe ('sup) or quoting someone keeps
+// its quotes and never fails to parse.
+func TokenizeText(input string) ([]string, error) {
+	return tokenize(input, false)
+}
+
+// tokenize implements Tokenize and TokenizeText. bareQuotes makes quotes at the start
+// of a token group words.
+func tokenize(input string, bareQuotes bool) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inToken := false
+	var closing rune
+
+	for _, r := range input {
+		switch {
+		case closing != 0:
+			if r == closing {
+				closing = 0
+				continue
+			}
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			if q, ok := closingQuotes[r]; ok && bareQuotes && current.Len() == 0 {
+				closing = q
+				inToken = true
+				continue
+			}
+			// Quotes may also open a flag value: --prompt="make it funny"
+			if q, ok := closingQuotes[r]; ok && strings.HasPrefix(current.String(), FlagPrefix) && strings.HasSuffix(current.String(), "=") {
+				closing = q
+				continue
+			}
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+
+	if closing != 0 {
+		for opening, c := range closingQuotes {
+			if c == closing {
+				return nil, NewUnterminatedQuoteError(opening)
+			}
+		}
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens, nil
+}
+
+// SplitFlag reports whether token is a flag and splits it into name and value.
+// hasValue is false for the "--name value" form, where the value is the next token.
+func SplitFlag(token string) (name, value string, hasValue, ok bool) {
+	if !strings.HasPrefix(token, FlagPrefix) || len(token) == len(FlagPrefix) {
+		return "", "", false, false
+	}
+	body := strings.TrimPrefix(token, FlagPrefix)
+	name, value, hasValue = strings.Cut(body, "=")
+	return strings.ToLower(name), value, hasValue, true
+}
+
+// ParseInt converts a flag value to an integer no smaller than minValue
+func ParseInt(flag, value string, minValue int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, NewInvalidValueError(flag, value, "a whole number")
+	}
+	if n < minValue {
+		return 0, NewInvalidValueError(flag, value, "at least "+strconv.Itoa(minValue))
+	}
+	return n, nil
+}
+
+// durationPattern matches compound durations such as 90m, 2h, 1h30m, 7d or 2w
+var durationPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?[wdhms])+$`)
+
+// durationUnitPattern matches one number-and-unit component of a duration
+var durationUnitPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)([wdhms])`)
+
+// durationUnits maps unit suffixes to their length
+var durationUnits = map[string]time.Duration{
+	"w": 7 * 24 * time.Hour,
+	"d": 24 * time.Hour,
+	"h": time.Hour,
+	"m": time.Minute,
+	"s": time.Second,
+}
+
+// ParseDuration converts a flag value such as 2h, 1h30m or 7d to a positive duration.
+// Unlike time.ParseDuration it understands days and weeks.
+func ParseDuration(flag, value string) (time.Duration, error) {
+	lower := strings.ToLower(value)
+	if !durationPattern.MatchString(lower) {
+		return 0, NewInvalidValueError(flag, value, "a duration like 30m, 2h or 7d")
+	}
+
+	var total time.Duration
+	for _, match := range durationUnitPattern.FindAllStringSubmatch(lower, -1) {
+		amount, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, NewInvalidValueError(flag, value, "a duration like 30m, 2h or 7d")
+		}
+		total += time.Duration(amount * float64(durationUnits[match[2]]))
+	}
+
+	if total <= 0 {
+		return 0, NewInvalidValueError(flag, value, "longer than zero")
+	}
+	return total, nil
+}
+
+// clockLayouts are the accepted spellings of a time of day
+var clockLayouts = []string{"15:04", "3pm", "3:04pm"}
+
+// ParseTime converts a flag value to a point in time in ref's location. It accepts a
+// time of day (18:00, 6pm, 6:30pm), a date (2024-05-01) or both (2024-05-01T18:00). A
+// time of day without a date means its latest occurrence at or before ref, so "18:00"
+// in the morning is yesterday evening.
+func ParseTime(flag, value string, ref time.Time) (time.Time, error) {
+	lower := strings.ToLower(value)
+	loc := ref.Location()
+	for _, layout := range []string{time.DateOnly, "2006-01-02t15:04"} {
+		if t, err := time.ParseInLocation(layout, lower, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	for _, layout := range clockLayouts {
+		clock, err := time.Parse(layout, lower)
+		if err != nil {
+			continue
+		}
+		t := time.Date(ref.Year(), ref.Month(), ref.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		if t.After(ref) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t, nil
+	}
+
+	return time.Time{}, NewInvalidValueError(flag, value, "a time like 18:00, 6pm or 2024-05-01")
+}
+
+// Discord mention and reference formats
+var (
+	userMentionPattern = regexp.MustCompile(`^<@!?(\d+)>$`)
+	roleMentionPattern = regexp.MustCompile(`^<@&(\d+)>$`)
+	channelRefPattern  = regexp.MustCompile(`^<#(\d+)>$`)
+	// messageLinkPattern matches a message's jump link, including the ptb and canary
+	// hosts and the <link> form that suppresses embeds
+	messageLinkPattern = regexp.MustCompile(`^<?https://(?:(?:ptb|canary)\.)?discord(?:app)?\.com/channels/(\d+|@me)/(\d+)/(\d+)>?$`)
+)
+
+// UserID extracts the ID from a user mention such as <@123> or <@!123>
+func UserID(token string) (string, bool) {
+	return submatch(userMentionPattern, token)
+}
+
+// RoleID extracts the ID from a role mention such as <@&123>
+func RoleID(token string) (string, bool) {
+	return submatch(roleMentionPattern, token)
+}
+
+// ChannelID extracts the ID from a channel reference such as <#123>
+func ChannelID(token string) (string, bool) {
+	return submatch(channelRefPattern, token)
+}
+
+// MessageLink extracts the IDs from a message jump link such as
+// https://discord.com/channels/1/2/3. Links to direct messages have the guild ID "@me".
+func MessageLink(token string) (guildID, channelID, messageID string, ok bool) {
+	match := messageLinkPattern.FindStringSubmatch(token)
+	if match == nil {
+		return "", "", "", false
+	}
+	return match[1], match[2], match[3], true
+}
+
+// IsInt reports whether token is a plain integer
+func IsInt(token string) bool {
+	_, err := strconv.Atoi(token)
+	return err == nil
+}
+
+// submatch returns the first capture group of pattern in token
+func submatch(pattern *regexp.Regexp, token string) (string, bool) {
+	match := pattern.FindStringSubmatch(token)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
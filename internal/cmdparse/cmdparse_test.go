@@ -0,0 +1,249 @@
+package cmdparse
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "plain words", input: "!opinion grok 50", want: []string{"!opinion", "grok", "50"}},
+		{name: "extra whitespace", input: "  !ask   why \t now ", want: []string{"!ask", "why", "now"}},
+		{name: "double quotes", input: `!ask "is this real" grok`, want: []string{"!ask", "is this real", "grok"}},
+		{name: "single quotes", input: `!most 'most likely to win'`, want: []string{"!most", "most likely to win"}},
+		{name: "smart quotes", input: "!ask “who won”", want: []string{"!ask", "who won"}},
+		{name: "apostrophe inside word", input: "!ask don't stop", want: []string{"!ask", "don't", "stop"}},
+		{name: "quoted flag value", input: `!img --prompt="make it funny" grok`, want: []string{"!img", "--prompt=make it funny", "grok"}},
+		{name: "empty quotes", input: `!ask ""`, want: []string{"!ask", ""}},
+		{name: "empty input", input: "", want: nil},
+		{name: "unterminated quote", input: `!ask "who won`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Tokenize(tt.input)
+			if tt.wantErr {
+				var quoteErr *UnterminatedQuoteError
+				if !errors.As(err, &quoteErr) {
+					t.Fatalf("Tokenize(%q) error = %v, want UnterminatedQuoteError", tt.input, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Tokenize(%q) error = %v", tt.input, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeText(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "leading apostrophe", input: "!ask 'sup", want: []string{"!ask", "'sup"}},
+		{name: "quoted words stay quoted", input: `!ask what does "yeet" mean`, want: []string{"!ask", "what", "does", `"yeet"`, "mean"}},
+		{name: "quoted flag value", input: `!img cat.jpg --prompt="make it funny"`, want: []string{"!img", "cat.jpg", "--prompt=make it funny"}},
+		{name: "unterminated flag value", input: `!img --prompt="make it funny`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TokenizeText(tt.input)
+			if tt.wantErr {
+				var quoteErr *UnterminatedQuoteError
+				if !errors.As(err, &quoteErr) {
+					t.Fatalf("TokenizeText(%q) error = %v, want UnterminatedQuoteError", tt.input, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TokenizeText(%q) error = %v", tt.input, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("TokenizeText(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitFlag(t *testing.T) {
+	tests := []struct {
+		token        string
+		wantName     string
+		wantValue    string
+		wantHasValue bool
+		wantOK       bool
+	}{
+		{token: "--n=50", wantName: "n", wantValue: "50", wantHasValue: true, wantOK: true},
+		{token: "--Provider=grok", wantName: "provider", wantValue: "grok", wantHasValue: true, wantOK: true},
+		{token: "--days", wantName: "days", wantOK: true},
+		{token: "--url=https://x.test/?a=b", wantName: "url", wantValue: "https://x.test/?a=b", wantHasValue: true, wantOK: true},
+		{token: "--", wantOK: false},
+		{token: "-n", wantOK: false},
+		{token: "grok", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			name, value, hasValue, ok := SplitFlag(tt.token)
+			if name != tt.wantName || value != tt.wantValue || hasValue != tt.wantHasValue || ok != tt.wantOK {
+				t.Errorf("SplitFlag(%q) = (%q, %q, %v, %v), want (%q, %q, %v, %v)",
+					tt.token, name, value, hasValue, ok, tt.wantName, tt.wantValue, tt.wantHasValue, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "50", want: 50},
+		{value: "1", want: 1},
+		{value: "0", wantErr: true},
+		{value: "-3", wantErr: true},
+		{value: "fifty", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseInt("n", tt.value, 1)
+			if tt.wantErr {
+				var invalid *InvalidValueError
+				if !errors.As(err, &invalid) || invalid.Flag != "n" {
+					t.Fatalf("ParseInt(%q) error = %v, want InvalidValueError for --n", tt.value, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseInt(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "2h", want: 2 * time.Hour},
+		{value: "90m", want: 90 * time.Minute},
+		{value: "1h30m", want: 90 * time.Minute},
+		{value: "7d", want: 7 * 24 * time.Hour},
+		{value: "2W", want: 14 * 24 * time.Hour},
+		{value: "1.5h", want: 90 * time.Minute},
+		{value: "0h", wantErr: true},
+		{value: "2", wantErr: true},
+		{value: "two hours", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDuration("since", tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDuration(%q) = %v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferenceIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		extract func(string) (string, bool)
+		token   string
+		want    string
+		wantOK  bool
+	}{
+		{name: "user", extract: UserID, token: "<@123>", want: "123", wantOK: true},
+		{name: "user nickname form", extract: UserID, token: "<@!123>", want: "123", wantOK: true},
+		{name: "role is not a user", extract: UserID, token: "<@&123>"},
+		{name: "role", extract: RoleID, token: "<@&456>", want: "456", wantOK: true},
+		{name: "channel", extract: ChannelID, token: "<#789>", want: "789", wantOK: true},
+		{name: "plain text", extract: ChannelID, token: "#general"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.extract(tt.token)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("extract(%q) = %q, %v, want %q, %v", tt.token, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMessageLink(t *testing.T) {
+	tests := []struct {
+		token                          string
+		wantGuild, wantChannel, wantID string
+		wantOK                         bool
+	}{
+		{token: "https://discord.com/channels/1/2/3", wantGuild: "1", wantChannel: "2", wantID: "3", wantOK: true},
+		{token: "<https://ptb.discord.com/channels/1/2/3>", wantGuild: "1", wantChannel: "2", wantID: "3", wantOK: true},
+		{token: "https://discordapp.com/channels/@me/2/3", wantGuild: "@me", wantChannel: "2", wantID: "3", wantOK: true},
+		{token: "https://discord.com/channels/1/2"},
+		{token: "https://example.com/channels/1/2/3"},
+	}
+
+	for _, tt := range tests {
+		guildID, channelID, messageID, ok := MessageLink(tt.token)
+		if guildID != tt.wantGuild || channelID != tt.wantChannel || messageID != tt.wantID || ok != tt.wantOK {
+			t.Errorf("MessageLink(%q) = %q, %q, %q, %v", tt.token, guildID, channelID, messageID, ok)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	ref := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "09:30", want: time.Date(2024, 6, 10, 9, 30, 0, 0, time.UTC)},
+		{value: "18:00", want: time.Date(2024, 6, 9, 18, 0, 0, 0, time.UTC)},
+		{value: "6PM", want: time.Date(2024, 6, 9, 18, 0, 0, 0, time.UTC)},
+		{value: "11:15am", want: time.Date(2024, 6, 10, 11, 15, 0, 0, time.UTC)},
+		{value: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "2024-05-01T18:00", want: time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)},
+		{value: "25:00", wantErr: true},
+		{value: "tonight", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTime("from", tt.value, ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
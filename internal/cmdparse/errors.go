@@ -0,0 +1,69 @@
+package cmdparse
+
+import "fmt"
+
+// UnterminatedQuoteError is returned when a quoted argument is never closed
+type UnterminatedQuoteError struct {
+	Quote rune
+}
+
+// NewUnterminatedQuoteError creates a new unterminated quote error
+func NewUnterminatedQuoteError(quote rune) *UnterminatedQuoteError {
+	return &UnterminatedQuoteError{Quote: quote}
+}
+
+// Error implements the error interface
+func (e *UnterminatedQuoteError) Error() string {
+	return fmt.Sprintf("missing closing %c quote", e.Quote)
+}
+
+// UnknownFlagError is returned for a flag the command doesn't accept
+type UnknownFlagError struct {
+	Flag string
+}
+
+// NewUnknownFlagError creates a new unknown flag error
+func NewUnknownFlagError(flag string) *UnknownFlagError {
+	return &UnknownFlagError{Flag: flag}
+}
+
+// Error implements the error interface
+func (e *UnknownFlagError) Error() string {
+	return fmt.Sprintf("unknown flag --%s", e.Flag)
+}
+
+// MissingValueError is returned when a flag is given without a value
+type MissingValueError struct {
+	Flag string
+}
+
+// NewMissingValueError creates a new missing value error
+func NewMissingValueError(flag string) *MissingValueError {
+	return &MissingValueError{Flag: flag}
+}
+
+// Error implements the error interface
+func (e *MissingValueError) Error() string {
+	return fmt.Sprintf("--%s needs a value, e.g. --%s=...", e.Flag, e.Flag)
+}
+
+// InvalidValueError is returned when a value can't be converted to the expected type
+type InvalidValueError struct {
+	Flag     string
+	Value    string
+	Expected string
+}
+
+// NewInvalidValueError creates a new invalid value error
+func NewInvalidValueError(flag, value, expected string) *InvalidValueError {
+	return &InvalidValueError{
+		Flag:     flag,
+		Value:    value,
+		Expected: expected,
+	}
+}
+
+// Error implements the error interface
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("--%s must be %s, got %q", e.Flag, e.Expected, e.Value)
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +14,20 @@ type Config struct {
 	DiscordPoliticsChannel string
 	XAIAPIKey              string
 	OpenAIAPIKey           string
+	DataDir                string
+	ModerationLevel        string
+	ModerationBlocklist    string
+	PIIRedaction           bool
+	PIIPatternsFile        string
+	PersistMemory          bool
+	FactExtraction         bool
+	ArchiveEnabled         bool
+	ArchiveRetentionDays   int
+	ArchiveBackfill        int
+	MembersIntent          bool
+	RecallEnabled          bool
+	TimeZone               string
+	ImageCaptions          bool
 }
 
 // LoadConfig loads environment variables from .env file and returns a Config struct
@@ -24,6 +40,20 @@ func LoadConfig() (*Config, error) {
 		DiscordPoliticsChannel: os.Getenv("DISCORD_POLITICS_CHANNEL"),
 		XAIAPIKey:              os.Getenv("XAI_API_KEY"),
 		OpenAIAPIKey:           os.Getenv("OPENAI_API_KEY"),
+		DataDir:                os.Getenv("DATA_DIR"),
+		ModerationLevel:        os.Getenv("MODERATION_LEVEL"),
+		ModerationBlocklist:    os.Getenv("MODERATION_BLOCKLIST_FILE"),
+		PIIRedaction:           parseBoolDefault(os.Getenv("PII_REDACTION"), true),
+		PIIPatternsFile:        os.Getenv("PII_PATTERNS_FILE"),
+		PersistMemory:          parseBoolDefault(os.Getenv("MEMORY_PERSIST"), false),
+		FactExtraction:         parseBoolDefault(os.Getenv("FACT_EXTRACTION"), false),
+		ArchiveEnabled:         parseBoolDefault(os.Getenv("ARCHIVE_ENABLED"), true),
+		ArchiveRetentionDays:   parseIntDefault(os.Getenv("ARCHIVE_RETENTION_DAYS"), 30),
+		ArchiveBackfill:        parseIntDefault(os.Getenv("ARCHIVE_BACKFILL_MESSAGES"), 1000),
+		MembersIntent:          parseBoolDefault(os.Getenv("DISCORD_MEMBERS_INTENT"), false),
+		RecallEnabled:          parseBoolDefault(os.Getenv("RECALL_ENABLED"), false),
+		TimeZone:               os.Getenv("TIME_ZONE"),
+		ImageCaptions:          parseBoolDefault(os.Getenv("IMAGE_CAPTIONS"), false),
 	}
 
 	// Set default value for politics channel if not provided
@@ -31,9 +61,42 @@ func LoadConfig() (*Config, error) {
 		config.DiscordPoliticsChannel = "politics"
 	}
 
+	if config.DataDir == "" {
+		config.DataDir = "data"
+	}
+
+	if config.ModerationLevel == "" {
+		config.ModerationLevel = "standard"
+	}
+
+	if config.TimeZone == "" {
+		config.TimeZone = "UTC"
+	}
+
 	return config, nil
 }
 
+// parseBoolDefault interprets common on/off spellings, returning def for empty or unknown values
+func parseBoolDefault(value string, def bool) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	default:
+		return def
+	}
+}
+
+// parseIntDefault parses a non-negative integer, returning def for empty or invalid values
+func parseIntDefault(value string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
 // Validate checks that the configuration is valid
 func (c *Config) Validate() error {
 	if c.DiscordToken == "" {
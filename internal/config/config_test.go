@@ -92,6 +92,9 @@ func TestLoadConfig(t *testing.T) {
 	origXAIKey := os.Getenv("XAI_API_KEY")
 	origOpenAIKey := os.Getenv("OPENAI_API_KEY")
 	origPoliticsChannel := os.Getenv("DISCORD_POLITICS_CHANNEL")
+	origDataDir := os.Getenv("DATA_DIR")
+	origModerationLevel := os.Getenv("MODERATION_LEVEL")
+	origTimeZone := os.Getenv("TIME_ZONE")
 
 	// Cleanup after test
 	defer func() {
@@ -99,6 +102,9 @@ func TestLoadConfig(t *testing.T) {
 		os.Setenv("XAI_API_KEY", origXAIKey)
 		os.Setenv("OPENAI_API_KEY", origOpenAIKey)
 		os.Setenv("DISCORD_POLITICS_CHANNEL", origPoliticsChannel)
+		os.Setenv("DATA_DIR", origDataDir)
+		os.Setenv("MODERATION_LEVEL", origModerationLevel)
+		os.Setenv("TIME_ZONE", origTimeZone)
 	}()
 
 	tests := []struct {
@@ -108,6 +114,9 @@ func TestLoadConfig(t *testing.T) {
 		wantXAIKey    string
 		wantOpenAIKey string
 		wantPolitics  string
+		wantDataDir   string
+		wantModLevel  string
+		wantTimeZone  string
 	}{
 		{
 			name: "loads all env vars",
@@ -116,11 +125,17 @@ func TestLoadConfig(t *testing.T) {
 				"XAI_API_KEY":              "test-xai",
 				"OPENAI_API_KEY":           "test-openai",
 				"DISCORD_POLITICS_CHANNEL": "test-politics",
+				"DATA_DIR":                 "/var/lib/coonbot",
+				"MODERATION_LEVEL":         "strict",
+				"TIME_ZONE":                "America/New_York",
 			},
 			wantToken:     "test-token",
 			wantXAIKey:    "test-xai",
 			wantOpenAIKey: "test-openai",
 			wantPolitics:  "test-politics",
+			wantDataDir:   "/var/lib/coonbot",
+			wantModLevel:  "strict",
+			wantTimeZone:  "America/New_York",
 		},
 		{
 			name: "default politics channel",
@@ -131,6 +146,9 @@ func TestLoadConfig(t *testing.T) {
 			wantToken:    "test-token",
 			wantXAIKey:   "test-xai",
 			wantPolitics: "politics",
+			wantDataDir:  "data",
+			wantModLevel: "standard",
+			wantTimeZone: "UTC",
 		},
 	}
 
@@ -141,6 +159,9 @@ func TestLoadConfig(t *testing.T) {
 			os.Unsetenv("XAI_API_KEY")
 			os.Unsetenv("OPENAI_API_KEY")
 			os.Unsetenv("DISCORD_POLITICS_CHANNEL")
+			os.Unsetenv("DATA_DIR")
+			os.Unsetenv("MODERATION_LEVEL")
+			os.Unsetenv("TIME_ZONE")
 
 			// Set test env vars
 			for k, v := range tt.envVars {
@@ -164,6 +185,59 @@ func TestLoadConfig(t *testing.T) {
 			if cfg.DiscordPoliticsChannel != tt.wantPolitics {
 				t.Errorf("DiscordPoliticsChannel = %v, want %v", cfg.DiscordPoliticsChannel, tt.wantPolitics)
 			}
+			if cfg.DataDir != tt.wantDataDir {
+				t.Errorf("DataDir = %v, want %v", cfg.DataDir, tt.wantDataDir)
+			}
+			if cfg.ModerationLevel != tt.wantModLevel {
+				t.Errorf("ModerationLevel = %v, want %v", cfg.ModerationLevel, tt.wantModLevel)
+			}
+			if cfg.TimeZone != tt.wantTimeZone {
+				t.Errorf("TimeZone = %v, want %v", cfg.TimeZone, tt.wantTimeZone)
+			}
+		})
+	}
+}
+
+func TestParseBoolDefault(t *testing.T) {
+	tests := []struct {
+		value string
+		def   bool
+		want  bool
+	}{
+		{value: "", def: true, want: true},
+		{value: "off", def: true, want: false},
+		{value: "FALSE", def: true, want: false},
+		{value: "on", def: false, want: true},
+		{value: "1", def: false, want: true},
+		{value: "maybe", def: false, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseBoolDefault(tt.value, tt.def); got != tt.want {
+				t.Errorf("parseBoolDefault(%q, %v) = %v, want %v", tt.value, tt.def, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIntDefault(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{value: "", want: 30},
+		{value: "7", want: 7},
+		{value: " 0 ", want: 0},
+		{value: "-1", want: 30},
+		{value: "a week", want: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseIntDefault(tt.value, 30); got != tt.want {
+				t.Errorf("parseIntDefault(%q, 30) = %v, want %v", tt.value, got, tt.want)
+			}
 		})
 	}
 }
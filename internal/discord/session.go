@@ -18,6 +18,15 @@ type Session interface {
 	// ChannelMessageSend sends a message to a channel
 	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
 
+	// ChannelMessageSendReply sends a message to a channel as a reply to another message
+	ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	// ChannelMessageEdit replaces the content of a message the bot sent
+	ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	// ChannelMessageSendEmbed sends an embed to a channel
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
 	// ChannelTyping triggers a typing indicator in a channel for ~10 seconds
 	ChannelTyping(channelID string, options ...discordgo.RequestOption) error
 
@@ -27,9 +36,33 @@ type Session interface {
 	// ChannelMessage retrieves a specific message from a channel
 	ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error)
 
+	// Channel retrieves a channel
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+
+	// UserChannelPermissions returns the permission bits a user has in a channel
+	UserChannelPermissions(userID, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error)
+
+	// UserChannelCreate opens (or returns the existing) direct message channel with a user
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+
 	// GuildMember retrieves a guild member
 	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
 
+	// ApplicationCommandBulkOverwrite replaces the registered application (slash) commands
+	ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error)
+
+	// InteractionRespond sends the initial response to an interaction
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+
+	// InteractionResponseEdit edits the initial (possibly deferred) interaction response
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	// FollowupMessageCreate sends a follow-up message for an interaction
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	// FollowupMessageEdit edits an interaction's response or one of its follow-up messages
+	FollowupMessageEdit(interaction *discordgo.Interaction, messageID string, data *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
 	// AddHandler adds an event handler
 	AddHandler(handler interface{}) func()
 
@@ -49,7 +82,7 @@ func NewDiscordSession(token string) (*DiscordSession, error) {
 		return nil, err
 	}
 
-	session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent
+	session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent
 
 	return &DiscordSession{Session: session}, nil
 }
@@ -0,0 +1,18 @@
+package facts
+
+import "fmt"
+
+// LimitError is returned when a guild already stores the maximum number of facts
+type LimitError struct {
+	Limit int
+}
+
+// NewLimitError creates a new limit error
+func NewLimitError(limit int) *LimitError {
+	return &LimitError{Limit: limit}
+}
+
+// Error implements the error interface
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("this server already has %d remembered facts; forget some first", e.Limit)
+}
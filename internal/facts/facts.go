@@ -0,0 +1,256 @@
+// Package facts stores long-term facts about server members and server lore, such as
+// "Dave is a Jets fan", and retrieves the ones relevant to a message.
+package facts
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/storage"
+)
+
+// Fact statuses. Pending facts wait for moderator review and are never used in prompts.
+const (
+	StatusApproved = "approved"
+	StatusPending  = "pending"
+)
+
+// Fact sources
+const (
+	SourceCommand   = "command"
+	SourceExtracted = "extracted"
+)
+
+// Fact is one remembered statement
+type Fact struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+	// SubjectID and SubjectName identify the member the fact is about, if any
+	SubjectID   string    `json:"subject_id,omitempty"`
+	SubjectName string    `json:"subject_name,omitempty"`
+	AuthorID    string    `json:"author_id,omitempty"`
+	Source      string    `json:"source"`
+	Status      string    `json:"status"`
+	Created     time.Time `json:"created"`
+}
+
+// String renders the fact as it is shown to users and models
+func (f Fact) String() string {
+	if f.SubjectName != "" {
+		return fmt.Sprintf("(about %s) %s", f.SubjectName, f.Text)
+	}
+	return f.Text
+}
+
+// guildFacts holds one guild's facts and its ID counter
+type guildFacts struct {
+	NextID int    `json:"next_id"`
+	Facts  []Fact `json:"facts"`
+}
+
+// Store keeps facts per guild and persists them to a JSON file
+type Store struct {
+	mu       sync.RWMutex
+	path     string
+	maxFacts int
+	now      func() time.Time
+	guilds   map[string]*guildFacts
+}
+
+// NewStore creates a fact store backed by the file at path, loading any existing facts.
+// Each guild may hold at most maxFacts facts, pending ones included.
+func NewStore(path string, maxFacts int) (*Store, error) {
+	store := &Store{
+		path:     path,
+		maxFacts: maxFacts,
+		now:      time.Now,
+		guilds:   make(map[string]*guildFacts),
+	}
+
+	if err := storage.LoadJSON(path, &store.guilds); err != nil {
+		return nil, fmt.Errorf("failed to load facts: %w", err)
+	}
+
+	return store, nil
+}
+
+// Add stores a fact, assigning its ID and creation time
+func (s *Store) Add(guildID string, fact Fact) (Fact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gf, ok := s.guilds[guildID]
+	if !ok {
+		gf = &guildFacts{NextID: 1}
+		s.guilds[guildID] = gf
+	}
+	if s.maxFacts > 0 && len(gf.Facts) >= s.maxFacts {
+		return Fact{}, NewLimitError(s.maxFacts)
+	}
+
+	fact.ID = strconv.Itoa(gf.NextID)
+	fact.Created = s.now()
+	gf.NextID++
+	gf.Facts = append(gf.Facts, fact)
+
+	return fact, s.save()
+}
+
+// Get returns a fact by ID
+func (s *Store) Get(guildID, id string) (Fact, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gf, ok := s.guilds[guildID]
+	if !ok {
+		return Fact{}, false
+	}
+	i := slices.IndexFunc(gf.Facts, func(f Fact) bool { return f.ID == id })
+	if i < 0 {
+		return Fact{}, false
+	}
+	return gf.Facts[i], true
+}
+
+// Remove deletes a fact and reports whether it existed
+func (s *Store) Remove(guildID, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gf, ok := s.guilds[guildID]
+	if !ok {
+		return false, nil
+	}
+	before := len(gf.Facts)
+	gf.Facts = slices.DeleteFunc(gf.Facts, func(f Fact) bool { return f.ID == id })
+	if len(gf.Facts) == before {
+		return false, nil
+	}
+	return true, s.save()
+}
+
+// Approve marks a pending fact as approved and reports whether it was found
+func (s *Store) Approve(guildID, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gf, ok := s.guilds[guildID]
+	if !ok {
+		return false, nil
+	}
+	i := slices.IndexFunc(gf.Facts, func(f Fact) bool { return f.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	gf.Facts[i].Status = StatusApproved
+	return true, s.save()
+}
+
+// List returns a guild's facts with the given status, oldest first. An empty
+// subjectID lists facts about everyone.
+func (s *Store) List(guildID, status, subjectID string) []Fact {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gf, ok := s.guilds[guildID]
+	if !ok {
+		return nil
+	}
+	var list []Fact
+	for _, fact := range gf.Facts {
+		if fact.Status == status && (subjectID == "" || fact.SubjectID == subjectID) {
+			list = append(list, fact)
+		}
+	}
+	return list
+}
+
+// Relevant returns up to limit approved facts that relate to text or to one of the
+// given members, best match first. Facts are scored by the words they share with text,
+// with a bonus for facts about the listed members.
+func (s *Store) Relevant(guildID, text string, subjectIDs []string, limit int) []Fact {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gf, ok := s.guilds[guildID]
+	if !ok || limit <= 0 {
+		return nil
+	}
+
+	queryWords := keywords(text)
+
+	type scored struct {
+		fact  Fact
+		score int
+	}
+	var matches []scored
+	for _, fact := range gf.Facts {
+		if fact.Status != StatusApproved {
+			continue
+		}
+		score := 0
+		for word := range keywords(fact.Text + " " + fact.SubjectName) {
+			if queryWords[word] {
+				score++
+			}
+		}
+		if fact.SubjectID != "" && slices.Contains(subjectIDs, fact.SubjectID) {
+			score += 2
+		}
+		if score > 0 {
+			matches = append(matches, scored{fact: fact, score: score})
+		}
+	}
+
+	// Newer facts win ties, since they are more likely to still be true
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].fact.Created.After(matches[j].fact.Created)
+	})
+
+	var relevant []Fact
+	for i := 0; i < len(matches) && i < limit; i++ {
+		relevant = append(relevant, matches[i].fact)
+	}
+	return relevant
+}
+
+// stopWords are too common to say anything about relevance
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "but": true, "not": true,
+	"you": true, "your": true, "his": true, "her": true, "she": true, "they": true, "them": true,
+	"with": true, "this": true, "that": true, "what": true, "who": true, "how": true, "why": true,
+	"about": true, "have": true, "has": true, "does": true, "did": true, "just": true, "from": true,
+	"like": true, "really": true, "think": true, "its": true, "our": true, "any": true, "all": true,
+}
+
+// keywords returns the distinct lower-case words of text that are worth matching on
+func keywords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len(word) < 3 || stopWords[word] {
+			continue
+		}
+		// Treat simple plurals ("jets" and "jet") as the same word
+		words[strings.TrimSuffix(word, "s")] = true
+	}
+	return words
+}
+
+// save persists every guild's facts. The caller must hold the write lock.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	return storage.SaveJSON(s.path, s.guilds)
+}
@@ -0,0 +1,145 @@
+package facts
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStorePersistsFacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "facts.json")
+
+	store, err := NewStore(path, 10)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	added, err := store.Add("guild", Fact{Text: "is a Jets fan", SubjectID: "1", SubjectName: "dave", Status: StatusApproved})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if added.ID != "1" {
+		t.Errorf("first ID = %q, want 1", added.ID)
+	}
+
+	reloaded, err := NewStore(path, 10)
+	if err != nil {
+		t.Fatalf("NewStore() reload error = %v", err)
+	}
+	got, ok := reloaded.Get("guild", "1")
+	if !ok || got.String() != "(about dave) is a Jets fan" {
+		t.Errorf("reloaded fact = %q, %v", got.String(), ok)
+	}
+
+	// IDs keep counting after a reload so forgotten IDs are never reused
+	if _, err := reloaded.Remove("guild", "1"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	next, err := reloaded.Add("guild", Fact{Text: "the server was founded in 2019", Status: StatusApproved})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if next.ID != "2" {
+		t.Errorf("ID after reload = %q, want 2", next.ID)
+	}
+}
+
+func TestStoreLimit(t *testing.T) {
+	store, err := NewStore("", 1)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	if _, err := store.Add("guild", Fact{Text: "one"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	_, err = store.Add("guild", Fact{Text: "two"})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("Add() over limit error = %v, want LimitError", err)
+	}
+	if _, err := store.Add("other-guild", Fact{Text: "one"}); err != nil {
+		t.Errorf("limit applied across guilds: %v", err)
+	}
+}
+
+func TestStoreReview(t *testing.T) {
+	store, err := NewStore("", 10)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	pending, _ := store.Add("guild", Fact{Text: "is secretly a Patriots fan", SubjectID: "1", Status: StatusPending})
+
+	if got := store.Relevant("guild", "patriots", []string{"1"}, 5); len(got) != 0 {
+		t.Errorf("pending fact was retrieved: %v", got)
+	}
+	if got := store.List("guild", StatusPending, ""); len(got) != 1 {
+		t.Errorf("pending list = %v, want 1 fact", got)
+	}
+
+	if ok, err := store.Approve("guild", pending.ID); !ok || err != nil {
+		t.Fatalf("Approve() = %v, %v", ok, err)
+	}
+	if got := store.Relevant("guild", "patriots", nil, 5); len(got) != 1 {
+		t.Errorf("approved fact not retrieved: %v", got)
+	}
+	if ok, _ := store.Approve("guild", "99"); ok {
+		t.Error("Approve() of unknown ID = true")
+	}
+}
+
+func TestStoreRelevant(t *testing.T) {
+	store, err := NewStore("", 10)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	for _, fact := range []Fact{
+		{Text: "is a Jets fan", SubjectID: "1", SubjectName: "dave"},
+		{Text: "hates pineapple on pizza", SubjectID: "2", SubjectName: "erin"},
+		{Text: "The server's pizza night is every Friday"},
+		{Text: "roots for the Celtics", SubjectID: "1", SubjectName: "dave"},
+	} {
+		fact.Status = StatusApproved
+		if _, err := store.Add("guild", fact); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		subjects []string
+		limit    int
+		want     []string
+	}{
+		{name: "keyword match with plural", text: "what do you think of the jet", want: []string{"1"}},
+		{name: "best match first", text: "pineapple pizza", want: []string{"2", "3"}},
+		{name: "subject bonus, newest first", text: "hello", subjects: []string{"1"}, want: []string{"4", "1"}},
+		{name: "mentions by name", text: "is dave around", want: []string{"4", "1"}},
+		{name: "limit", text: "pizza", limit: 1, want: []string{"3"}},
+		{name: "nothing relevant", text: "the weather is nice", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			if limit == 0 {
+				limit = 5
+			}
+			var ids []string
+			for _, fact := range store.Relevant("guild", tt.text, tt.subjects, limit) {
+				ids = append(ids, fact.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("Relevant(%q) = %v, want %v", tt.text, ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package jobs keeps persistent records of long-running commands, so they can be listed
+// and cancelled while they run, and resumed or failed cleanly after a restart.
+package jobs
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/storage"
+)
+
+// Job states. Queued and running jobs are active; the others are final.
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateDone      = "done"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// Job is the record of one long-running command invocation
+type Job struct {
+	ID        int    `json:"id"`
+	GuildID   string `json:"guild_id"`
+	ChannelID string `json:"channel_id"`
+	// MessageID is the invoking message, or the interaction for slash commands
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Command   string `json:"command"`
+	// Args are the raw arguments, kept so the command can be run again after a restart
+	Args []string `json:"args,omitempty"`
+	// StatusMessageID is the message edited with the job's progress
+	StatusMessageID string `json:"status_message_id,omitempty"`
+	State           string `json:"state"`
+	Error           string `json:"error,omitempty"`
+	// Attempts counts how many times the job has started running
+	Attempts int       `json:"attempts"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Active reports whether the job is queued or running
+func (j Job) Active() bool {
+	return j.State == StateQueued || j.State == StateRunning
+}
+
+// file is the on-disk layout of the store
+type file struct {
+	NextID int   `json:"next_id"`
+	Jobs   []Job `json:"jobs"`
+}
+
+// Store keeps job records and persists them to a JSON file
+type Store struct {
+	mu   sync.RWMutex
+	path string
+	now  func() time.Time
+	data file
+}
+
+// NewStore creates a job store backed by the file at path, loading any existing jobs
+func NewStore(path string) (*Store, error) {
+	store := &Store{path: path, now: time.Now, data: file{NextID: 1}}
+	if err := storage.LoadJSON(path, &store.data); err != nil {
+		return nil, fmt.Errorf("failed to load jobs: %w", err)
+	}
+	return store, nil
+}
+
+// Create stores a new queued job, assigning its ID and timestamps
+func (s *Store) Create(job Job) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job.ID = s.data.NextID
+	job.State = StateQueued
+	job.Created = s.now()
+	job.Updated = job.Created
+	s.data.NextID++
+	s.data.Jobs = append(s.data.Jobs, job)
+
+	return job, s.save()
+}
+
+// Get returns a job by ID
+func (s *Store) Get(id int) (Job, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return Job{}, false
+	}
+	return s.data.Jobs[i], true
+}
+
+// Update applies fn to a job and saves it, returning the updated job. It reports false
+// when there is no job with that ID.
+func (s *Store) Update(id int, fn func(*Job)) (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return Job{}, false, nil
+	}
+	fn(&s.data.Jobs[i])
+	s.data.Jobs[i].Updated = s.now()
+	return s.data.Jobs[i], true, s.save()
+}
+
+// Active returns the queued and running jobs of a guild, oldest first. An empty guildID
+// lists the active jobs of every guild.
+func (s *Store) Active(guildID string) []Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var active []Job
+	for _, job := range s.data.Jobs {
+		if job.Active() && (guildID == "" || job.GuildID == guildID) {
+			active = append(active, job)
+		}
+	}
+	return active
+}
+
+// Prune removes finished jobs last updated before cutoff and returns how many it removed
+func (s *Store) Prune(cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.data.Jobs)
+	s.data.Jobs = slices.DeleteFunc(s.data.Jobs, func(job Job) bool {
+		return !job.Active() && job.Updated.Before(cutoff)
+	})
+	removed := before - len(s.data.Jobs)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save()
+}
+
+// index returns the position of a job, or -1. Callers must hold the lock.
+func (s *Store) index(id int) int {
+	return slices.IndexFunc(s.data.Jobs, func(job Job) bool { return job.ID == id })
+}
+
+// save writes the store to disk. Callers must hold the lock.
+func (s *Store) save() error {
+	return storage.SaveJSON(s.path, s.data)
+}
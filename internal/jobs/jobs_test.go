@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStorePersistsJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	created, err := store.Create(Job{GuildID: "guild", Command: "opinion", Args: []string{"--since", "2h"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.ID != 1 || created.State != StateQueued {
+		t.Errorf("created job = %+v, want ID 1 and queued", created)
+	}
+	if _, _, err := store.Update(created.ID, func(job *Job) {
+		job.State = StateRunning
+		job.StatusMessageID = "status"
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() reload error = %v", err)
+	}
+	got, ok := reloaded.Get(created.ID)
+	if !ok || got.State != StateRunning || got.StatusMessageID != "status" || !slices.Equal(got.Args, []string{"--since", "2h"}) {
+		t.Errorf("reloaded job = %+v, %v", got, ok)
+	}
+
+	// IDs keep counting after a reload so cancelled IDs are never reused
+	next, err := reloaded.Create(Job{GuildID: "guild", Command: "most"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if next.ID != 2 {
+		t.Errorf("ID after reload = %d, want 2", next.ID)
+	}
+
+	if _, ok, err := reloaded.Update(99, func(job *Job) {}); ok || err != nil {
+		t.Errorf("Update() of a missing job = %v, %v, want false and no error", ok, err)
+	}
+}
+
+func TestStoreActiveAndPrune(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "jobs.json"))
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	states := []struct {
+		guild string
+		state string
+	}{
+		{"guild", StateRunning},
+		{"guild", StateDone},
+		{"other", StateQueued},
+		{"guild", StateCancelled},
+	}
+	for _, s := range states {
+		job, err := store.Create(Job{GuildID: s.guild})
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, _, err := store.Update(job.ID, func(j *Job) { j.State = s.state }); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+
+	ids := func(jobs []Job) []int {
+		var out []int
+		for _, job := range jobs {
+			out = append(out, job.ID)
+		}
+		return out
+	}
+	if got := ids(store.Active("guild")); !slices.Equal(got, []int{1}) {
+		t.Errorf("Active(guild) = %v, want [1]", got)
+	}
+	if got := ids(store.Active("")); !slices.Equal(got, []int{1, 3}) {
+		t.Errorf("Active(all) = %v, want [1 3]", got)
+	}
+
+	if removed, err := store.Prune(now); err != nil || removed != 0 {
+		t.Errorf("Prune(now) = %d, %v, want nothing removed", removed, err)
+	}
+	removed, err := store.Prune(now.Add(time.Minute))
+	if err != nil || removed != 2 {
+		t.Errorf("Prune() = %d, %v, want 2 finished jobs removed", removed, err)
+	}
+	if _, ok := store.Get(1); !ok {
+		t.Error("Prune() removed an active job")
+	}
+}
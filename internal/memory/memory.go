@@ -0,0 +1,237 @@
+// Package memory keeps short-term conversation memory: a rolling, token-bounded window
+// of recent turns per user and channel, with older turns folded into a running summary.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+	"github.com/Dmetrikx/goDiscordChatter/internal/storage"
+)
+
+// Key identifies one rolling memory: a user's conversation with the bot in a channel
+type Key struct {
+	ChannelID string
+	UserID    string
+}
+
+// String returns the key in the form used to persist it
+func (k Key) String() string {
+	return k.ChannelID + ":" + k.UserID
+}
+
+// Conversation is what the bot remembers for a key
+type Conversation struct {
+	// Summary condenses turns that no longer fit in the window
+	Summary string           `json:"summary,omitempty"`
+	Turns   []ai.ChatMessage `json:"turns,omitempty"`
+	Updated time.Time        `json:"updated"`
+}
+
+// tokens estimates the size of the summary and turns
+func (c *Conversation) tokens() int {
+	total := ai.EstimateTokens(c.Summary)
+	for _, turn := range c.Turns {
+		total += ai.EstimateTokens(turn.Content)
+	}
+	return total
+}
+
+// clone returns a deep copy so callers can't race with later updates
+func (c *Conversation) clone() Conversation {
+	copied := *c
+	copied.Turns = slices.Clone(c.Turns)
+	return copied
+}
+
+// Summarizer folds older turns into an existing summary and returns the new summary
+type Summarizer func(ctx context.Context, summary string, turns []ai.ChatMessage) (string, error)
+
+// Store keeps conversations in memory, optionally persisting them to a JSON file
+type Store struct {
+	mu            sync.Mutex
+	path          string
+	maxTokens     int
+	idleTimeout   time.Duration
+	now           func() time.Time
+	conversations map[string]*Conversation
+}
+
+// NewStore creates a memory store. Conversations idle longer than idleTimeout are
+// forgotten, and Compact keeps each one within maxTokens. When path is non-empty,
+// memory is loaded from and saved to that file; otherwise it lives only in memory.
+func NewStore(path string, maxTokens int, idleTimeout time.Duration) (*Store, error) {
+	store := &Store{
+		path:          path,
+		maxTokens:     maxTokens,
+		idleTimeout:   idleTimeout,
+		now:           time.Now,
+		conversations: make(map[string]*Conversation),
+	}
+
+	if path != "" {
+		if err := storage.LoadJSON(path, &store.conversations); err != nil {
+			return nil, fmt.Errorf("failed to load conversation memory: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// Get returns a copy of the conversation for key. Expired conversations are empty.
+func (s *Store) Get(key Key) Conversation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, ok := s.live(key.String())
+	if !ok {
+		return Conversation{}
+	}
+	return conv.clone()
+}
+
+// Append adds turns to the conversation for key and persists the result
+func (s *Store) Append(key Key, turns ...ai.ChatMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, ok := s.live(key.String())
+	if !ok {
+		conv = &Conversation{}
+		s.conversations[key.String()] = conv
+	}
+	conv.Turns = append(conv.Turns, turns...)
+	conv.Updated = s.now()
+
+	return s.save()
+}
+
+// Reset forgets the conversation for key and reports whether there was one
+func (s *Store) Reset(key Key) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.live(key.String())
+	if !ok {
+		return false, nil
+	}
+	delete(s.conversations, key.String())
+	return true, s.save()
+}
+
+// ResetChannel forgets every conversation in a channel and returns how many there were
+func (s *Store) ResetChannel(channelID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := channelID + ":"
+	removed := 0
+	for id := range s.conversations {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		if _, ok := s.live(id); ok {
+			removed++
+		}
+		delete(s.conversations, id)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save()
+}
+
+// Compact keeps the conversation for key within the token budget. When it has grown
+// too large, the oldest turns are folded into the summary until the remaining turns
+// fit in half the budget. The summarizer runs without holding the lock; if it fails,
+// the oldest turns are dropped anyway so memory stays bounded, and the error is returned.
+func (s *Store) Compact(ctx context.Context, key Key, summarize Summarizer) error {
+	s.mu.Lock()
+	conv, ok := s.live(key.String())
+	if !ok || conv.tokens() <= s.maxTokens {
+		s.mu.Unlock()
+		return nil
+	}
+	snapshot := conv.clone()
+	s.mu.Unlock()
+
+	fold := foldCount(snapshot.Turns, s.maxTokens/2)
+	if fold == 0 {
+		return nil
+	}
+	folded := snapshot.Turns[:fold]
+
+	summary, err := summarize(ctx, snapshot.Summary, folded)
+	if err == nil {
+		// A runaway summary must not eat the window it is meant to protect
+		summary, _ = ai.TruncateToTokens(summary, s.maxTokens/4)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The conversation may have been reset or compacted while we were summarizing
+	conv, ok = s.live(key.String())
+	if !ok || len(conv.Turns) < fold || !slices.Equal(conv.Turns[:fold], folded) {
+		return err
+	}
+
+	conv.Turns = slices.Clone(conv.Turns[fold:])
+	if err != nil {
+		err = fmt.Errorf("failed to summarize conversation, dropped %d turns: %w", fold, err)
+	} else {
+		conv.Summary = summary
+	}
+
+	if saveErr := s.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// foldCount returns how many of the oldest turns must go for the rest to fit within
+// budget. The latest exchange is always kept.
+func foldCount(turns []ai.ChatMessage, budget int) int {
+	total := 0
+	for _, turn := range turns {
+		total += ai.EstimateTokens(turn.Content)
+	}
+
+	fold := 0
+	for fold < len(turns)-2 && total > budget {
+		total -= ai.EstimateTokens(turns[fold].Content)
+		fold++
+	}
+	return fold
+}
+
+// live returns a conversation that hasn't expired, forgetting it if it has.
+// The caller must hold the lock.
+func (s *Store) live(id string) (*Conversation, bool) {
+	conv, ok := s.conversations[id]
+	if !ok {
+		return nil, false
+	}
+	if s.idleTimeout > 0 && s.now().Sub(conv.Updated) > s.idleTimeout {
+		delete(s.conversations, id)
+		return nil, false
+	}
+	return conv, true
+}
+
+// save persists conversations when the store is backed by a file, leaving out expired
+// ones. The caller must hold the lock.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	for id := range s.conversations {
+		s.live(id)
+	}
+	return storage.SaveJSON(s.path, s.conversations)
+}
@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+)
+
+func turn(role, content string) ai.ChatMessage {
+	return ai.ChatMessage{Role: role, Content: content}
+}
+
+func TestStorePersistsConversations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.json")
+	key := Key{ChannelID: "c1", UserID: "u1"}
+
+	store, err := NewStore(path, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	if err := store.Append(key, turn(ai.RoleUser, "who won in 1986?"), turn(ai.RoleAssistant, "the Celtics")); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	reloaded, err := NewStore(path, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore() reload error = %v", err)
+	}
+	got := reloaded.Get(key)
+	if len(got.Turns) != 2 || got.Turns[1].Content != "the Celtics" {
+		t.Errorf("reloaded turns = %+v, want the appended exchange", got.Turns)
+	}
+	if other := reloaded.Get(Key{ChannelID: "c1", UserID: "u2"}); len(other.Turns) != 0 {
+		t.Errorf("other user's turns = %+v, want none", other.Turns)
+	}
+}
+
+func TestStoreForgetsIdleConversations(t *testing.T) {
+	store, err := NewStore("", 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	key := Key{ChannelID: "c1", UserID: "u1"}
+	if err := store.Append(key, turn(ai.RoleUser, "hi")); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	now = now.Add(59 * time.Minute)
+	if got := store.Get(key); len(got.Turns) != 1 {
+		t.Fatalf("turns before timeout = %d, want 1", len(got.Turns))
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := store.Get(key); len(got.Turns) != 0 {
+		t.Errorf("turns after timeout = %d, want 0", len(got.Turns))
+	}
+}
+
+func TestStoreReset(t *testing.T) {
+	store, err := NewStore("", 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	keys := []Key{{ChannelID: "c1", UserID: "u1"}, {ChannelID: "c1", UserID: "u2"}, {ChannelID: "c2", UserID: "u1"}}
+	for _, key := range keys {
+		if err := store.Append(key, turn(ai.RoleUser, "hi")); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	if removed, err := store.Reset(keys[0]); err != nil || !removed {
+		t.Errorf("Reset() = %v, %v, want true", removed, err)
+	}
+	if removed, _ := store.Reset(keys[0]); removed {
+		t.Error("second Reset() = true, want false")
+	}
+
+	if n, err := store.ResetChannel("c1"); err != nil || n != 1 {
+		t.Errorf("ResetChannel() = %d, %v, want 1", n, err)
+	}
+	if got := store.Get(keys[2]); len(got.Turns) != 1 {
+		t.Errorf("other channel lost its memory: %+v", got)
+	}
+}
+
+func TestStoreCompact(t *testing.T) {
+	long := strings.Repeat("x", 400) // ~100 tokens
+
+	tests := []struct {
+		name        string
+		turns       int
+		summarize   Summarizer
+		wantTurns   int
+		wantSummary string
+		wantErr     bool
+	}{
+		{
+			name:      "within budget",
+			turns:     3,
+			summarize: func(context.Context, string, []ai.ChatMessage) (string, error) { return "unused", nil },
+			wantTurns: 3,
+		},
+		{
+			name:  "folds oldest turns",
+			turns: 6,
+			summarize: func(_ context.Context, summary string, turns []ai.ChatMessage) (string, error) {
+				return "summary of " + strconv.Itoa(len(turns)), nil
+			},
+			wantTurns:   2,
+			wantSummary: "summary of 4",
+		},
+		{
+			name:  "drops turns when summarizing fails",
+			turns: 6,
+			summarize: func(context.Context, string, []ai.ChatMessage) (string, error) {
+				return "", errors.New("provider down")
+			},
+			wantTurns: 2,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStore("", 400, time.Hour)
+			if err != nil {
+				t.Fatalf("NewStore() error = %v", err)
+			}
+			key := Key{ChannelID: "c1", UserID: "u1"}
+			for i := range tt.turns {
+				role := ai.RoleUser
+				if i%2 == 1 {
+					role = ai.RoleAssistant
+				}
+				if err := store.Append(key, turn(role, long)); err != nil {
+					t.Fatalf("Append() error = %v", err)
+				}
+			}
+
+			err = store.Compact(context.Background(), key, tt.summarize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compact() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got := store.Get(key)
+			if len(got.Turns) != tt.wantTurns {
+				t.Errorf("turns = %d, want %d", len(got.Turns), tt.wantTurns)
+			}
+			if got.Summary != tt.wantSummary {
+				t.Errorf("summary = %q, want %q", got.Summary, tt.wantSummary)
+			}
+		})
+	}
+}
+
+func TestCompactSkipsResetConversation(t *testing.T) {
+	store, err := NewStore("", 100, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	key := Key{ChannelID: "c1", UserID: "u1"}
+	for range 4 {
+		if err := store.Append(key, turn(ai.RoleUser, strings.Repeat("x", 400))); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	err = store.Compact(context.Background(), key, func(context.Context, string, []ai.ChatMessage) (string, error) {
+		// The user runs !reset while the summary is being written
+		store.Reset(key)
+		return "stale summary", nil
+	})
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	if got := store.Get(key); got.Summary != "" || len(got.Turns) != 0 {
+		t.Errorf("conversation after reset = %+v, want empty", got)
+	}
+}
@@ -0,0 +1,78 @@
+package moderation
+
+import (
+	"context"
+	"regexp"
+	"sort"
+)
+
+// LocalClassifier flags text using regular expressions, with no network calls.
+// It is deliberately conservative and intended as an offline fallback.
+type LocalClassifier struct {
+	rules map[string][]*regexp.Regexp
+}
+
+// defaultRules are the built-in patterns per category
+var defaultRules = map[string][]string{
+	CategorySelfHarm: {
+		`(?i)\bkys\b`,
+		`(?i)\bkill\s+(your\s*self|urself)\b`,
+		`(?i)\b(go|just)\s+(die|end\s+it)\b`,
+	},
+	CategorySelfHarmInstructions: {
+		`(?i)\bhow\s+to\s+(kill|hang|cut)\s+(your\s*self|urself)\b`,
+	},
+	CategoryHarassmentThreatening: {
+		`(?i)\bi(\s*'?\s*ll|\s+will|\s+am\s+going\s+to|'?m\s+gonna)\s+(kill|murder|stab|shoot)\s+(you|u)\b`,
+		`(?i)\b(dox|doxx)(ing)?\s+(you|u|him|her|them)\b`,
+	},
+	CategoryHateThreatening: {
+		`(?i)\b(exterminate|gas|wipe\s+out)\s+(all|the)\s+\w+s\b`,
+	},
+	CategorySexualMinors: {
+		`(?i)\b(child|kid|minor|underage|preteen)\w*\s+(porn|nudes?|sex)\b`,
+	},
+	CategoryViolenceGraphic: {
+		`(?i)\b(disembowel|dismember)(ed|ing)?\b`,
+	},
+}
+
+// NewLocalClassifier creates a classifier from the built-in rules plus any extra
+// patterns, keyed by category. Invalid extra patterns are returned as an error.
+// Server-specific word lists should use CategoryBlocklist.
+func NewLocalClassifier(extra map[string][]string) (*LocalClassifier, error) {
+	c := &LocalClassifier{rules: make(map[string][]*regexp.Regexp)}
+
+	for category, patterns := range defaultRules {
+		for _, p := range patterns {
+			c.rules[category] = append(c.rules[category], regexp.MustCompile(p))
+		}
+	}
+
+	for category, patterns := range extra {
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, err
+			}
+			c.rules[category] = append(c.rules[category], re)
+		}
+	}
+
+	return c, nil
+}
+
+// Classify implements Classifier
+func (c *LocalClassifier) Classify(ctx context.Context, text string) (*Result, error) {
+	result := &Result{}
+	for category, patterns := range c.rules {
+		for _, re := range patterns {
+			if re.MatchString(text) {
+				result.Categories = append(result.Categories, category)
+				break
+			}
+		}
+	}
+	sort.Strings(result.Categories)
+	return result, nil
+}
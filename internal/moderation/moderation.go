@@ -0,0 +1,185 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Level controls how aggressively generated output is filtered before posting
+type Level string
+
+// Strictness levels, from most permissive to most restrictive
+const (
+	LevelOff      Level = "off"
+	LevelRelaxed  Level = "relaxed"
+	LevelStandard Level = "standard"
+	LevelStrict   Level = "strict"
+)
+
+// Levels lists all strictness levels in increasing order
+var Levels = []Level{LevelOff, LevelRelaxed, LevelStandard, LevelStrict}
+
+// ParseLevel converts a user-supplied string to a Level
+func ParseLevel(s string) (Level, error) {
+	level := Level(strings.ToLower(strings.TrimSpace(s)))
+	for _, l := range Levels {
+		if level == l {
+			return level, nil
+		}
+	}
+	return "", fmt.Errorf("unknown moderation level %q (want one of off, relaxed, standard, strict)", s)
+}
+
+// rank returns the position of the level in Levels, used for comparisons
+func (l Level) rank() int {
+	for i, candidate := range Levels {
+		if l == candidate {
+			return i
+		}
+	}
+	return 0
+}
+
+// Moderation categories, named after the OpenAI moderation endpoint categories
+const (
+	CategoryHate                  = "hate"
+	CategoryHateThreatening       = "hate/threatening"
+	CategoryHarassment            = "harassment"
+	CategoryHarassmentThreatening = "harassment/threatening"
+	CategorySelfHarm              = "self-harm"
+	CategorySelfHarmIntent        = "self-harm/intent"
+	CategorySelfHarmInstructions  = "self-harm/instructions"
+	CategorySexual                = "sexual"
+	CategorySexualMinors          = "sexual/minors"
+	CategoryViolence              = "violence"
+	CategoryViolenceGraphic       = "violence/graphic"
+	// CategoryBlocklist is used for operator-supplied patterns
+	CategoryBlocklist = "blocklist"
+)
+
+// categoryMinLevel is the least strict level at which a category is blocked.
+// Categories missing from the map are only blocked at LevelStrict.
+var categoryMinLevel = map[string]Level{
+	CategorySexualMinors:          LevelRelaxed,
+	CategoryHateThreatening:       LevelRelaxed,
+	CategorySelfHarmInstructions:  LevelRelaxed,
+	CategoryHarassmentThreatening: LevelRelaxed,
+	CategoryBlocklist:             LevelRelaxed,
+	CategoryHate:                  LevelStandard,
+	CategorySelfHarm:              LevelStandard,
+	CategorySelfHarmIntent:        LevelStandard,
+	CategoryViolenceGraphic:       LevelStandard,
+	CategorySexual:                LevelStrict,
+	CategoryHarassment:            LevelStrict,
+	CategoryViolence:              LevelStrict,
+}
+
+// Result is the outcome of classifying a piece of text
+type Result struct {
+	Categories []string
+}
+
+// Classifier flags text with moderation categories
+type Classifier interface {
+	Classify(ctx context.Context, text string) (*Result, error)
+}
+
+// multiClassifier merges the categories reported by several classifiers
+type multiClassifier []Classifier
+
+// Combine returns a classifier that flags every category reported by any of the given classifiers
+func Combine(classifiers ...Classifier) Classifier {
+	return multiClassifier(classifiers)
+}
+
+// Classify implements Classifier
+func (mc multiClassifier) Classify(ctx context.Context, text string) (*Result, error) {
+	seen := make(map[string]bool)
+	merged := &Result{}
+	for _, c := range mc {
+		result, err := c.Classify(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		for _, category := range result.Categories {
+			if !seen[category] {
+				seen[category] = true
+				merged.Categories = append(merged.Categories, category)
+			}
+		}
+	}
+	sort.Strings(merged.Categories)
+	return merged, nil
+}
+
+// Verdict describes whether text may be posted at a given level
+type Verdict struct {
+	Blocked    bool
+	Categories []string
+}
+
+// Moderator applies a classifier and a strictness level to generated text
+type Moderator struct {
+	classifier Classifier
+	fallback   Classifier
+}
+
+// NewModerator creates a moderator. If the primary classifier fails, the
+// fallback (typically a LocalClassifier) is used instead so outages fail closed.
+func NewModerator(classifier, fallback Classifier) *Moderator {
+	return &Moderator{
+		classifier: classifier,
+		fallback:   fallback,
+	}
+}
+
+// Check classifies text and decides whether it is blocked at the given level
+func (m *Moderator) Check(ctx context.Context, text string, level Level) (*Verdict, error) {
+	if level == LevelOff || text == "" {
+		return &Verdict{}, nil
+	}
+
+	result, err := m.classify(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	verdict := &Verdict{}
+	for _, category := range result.Categories {
+		if Blocks(level, category) {
+			verdict.Blocked = true
+			verdict.Categories = append(verdict.Categories, category)
+		}
+	}
+
+	return verdict, nil
+}
+
+// classify runs the primary classifier, falling back on error
+func (m *Moderator) classify(ctx context.Context, text string) (*Result, error) {
+	if m.classifier != nil {
+		result, err := m.classifier.Classify(ctx, text)
+		if err == nil {
+			return result, nil
+		}
+		if m.fallback == nil {
+			return nil, fmt.Errorf("moderation classifier failed: %w", err)
+		}
+	}
+
+	if m.fallback == nil {
+		return &Result{}, nil
+	}
+	return m.fallback.Classify(ctx, text)
+}
+
+// Blocks reports whether a category is blocked at the given level
+func Blocks(level Level, category string) bool {
+	minLevel, ok := categoryMinLevel[category]
+	if !ok {
+		minLevel = LevelStrict
+	}
+	return level != LevelOff && level.rank() >= minLevel.rank()
+}
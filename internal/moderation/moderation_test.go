@@ -0,0 +1,182 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Level
+		wantErr bool
+	}{
+		{name: "off", input: "off", want: LevelOff},
+		{name: "mixed case with spaces", input: " Strict ", want: LevelStrict},
+		{name: "standard", input: "standard", want: LevelStandard},
+		{name: "unknown", input: "extreme", wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    Level
+		category string
+		want     bool
+	}{
+		{name: "off never blocks", level: LevelOff, category: CategorySexualMinors, want: false},
+		{name: "relaxed blocks minors", level: LevelRelaxed, category: CategorySexualMinors, want: true},
+		{name: "relaxed allows hate", level: LevelRelaxed, category: CategoryHate, want: false},
+		{name: "standard blocks hate", level: LevelStandard, category: CategoryHate, want: true},
+		{name: "standard allows harassment", level: LevelStandard, category: CategoryHarassment, want: false},
+		{name: "strict blocks harassment", level: LevelStrict, category: CategoryHarassment, want: true},
+		{name: "strict blocks unknown categories", level: LevelStrict, category: "illicit", want: true},
+		{name: "relaxed blocks blocklist", level: LevelRelaxed, category: CategoryBlocklist, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Blocks(tt.level, tt.category); got != tt.want {
+				t.Errorf("Blocks(%s, %s) = %v, want %v", tt.level, tt.category, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalClassifier(t *testing.T) {
+	classifier, err := NewLocalClassifier(map[string][]string{
+		CategoryBlocklist: {`(?i)\bpineapple pizza\b`},
+	})
+	if err != nil {
+		t.Fatalf("NewLocalClassifier() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "clean text", text: "Celtics in six, book it.", want: nil},
+		{name: "self-harm", text: "honestly just kys", want: []string{CategorySelfHarm}},
+		{name: "threat", text: "I'm gonna kill you if you touch my chocolate", want: []string{CategoryHarassmentThreatening}},
+		{name: "custom blocklist", text: "Pineapple Pizza is elite", want: []string{CategoryBlocklist}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := classifier.Classify(context.Background(), tt.text)
+			if err != nil {
+				t.Fatalf("Classify() error = %v", err)
+			}
+			if len(result.Categories) != len(tt.want) {
+				t.Fatalf("Classify() categories = %v, want %v", result.Categories, tt.want)
+			}
+			for i := range tt.want {
+				if result.Categories[i] != tt.want[i] {
+					t.Errorf("Classify() categories[%d] = %v, want %v", i, result.Categories[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewLocalClassifierInvalidPattern(t *testing.T) {
+	_, err := NewLocalClassifier(map[string][]string{CategoryBlocklist: {"("}})
+	if err == nil {
+		t.Error("NewLocalClassifier() error = nil, want error for invalid pattern")
+	}
+}
+
+// stubClassifier returns fixed categories or an error
+type stubClassifier struct {
+	categories []string
+	err        error
+}
+
+func (s *stubClassifier) Classify(ctx context.Context, text string) (*Result, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &Result{Categories: s.categories}, nil
+}
+
+func TestModeratorCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		primary     Classifier
+		fallback    Classifier
+		level       Level
+		wantBlocked bool
+		wantErr     bool
+	}{
+		{
+			name:        "off skips classification",
+			primary:     &stubClassifier{err: errors.New("should not be called")},
+			level:       LevelOff,
+			wantBlocked: false,
+		},
+		{
+			name:        "flagged category above level blocks",
+			primary:     &stubClassifier{categories: []string{CategoryHate}},
+			level:       LevelStandard,
+			wantBlocked: true,
+		},
+		{
+			name:        "flagged category below level passes",
+			primary:     &stubClassifier{categories: []string{CategoryHarassment}},
+			level:       LevelStandard,
+			wantBlocked: false,
+		},
+		{
+			name:        "primary failure uses fallback",
+			primary:     &stubClassifier{err: errors.New("endpoint down")},
+			fallback:    &stubClassifier{categories: []string{CategorySelfHarm}},
+			level:       LevelStandard,
+			wantBlocked: true,
+		},
+		{
+			name:    "primary failure without fallback errors",
+			primary: &stubClassifier{err: errors.New("endpoint down")},
+			level:   LevelStandard,
+			wantErr: true,
+		},
+		{
+			name:        "combined classifiers merge categories",
+			primary:     Combine(&stubClassifier{}, &stubClassifier{categories: []string{CategoryBlocklist}}),
+			level:       LevelRelaxed,
+			wantBlocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewModerator(tt.primary, tt.fallback)
+			verdict, err := m.Check(context.Background(), "some generated text", tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if verdict.Blocked != tt.wantBlocked {
+				t.Errorf("Check() blocked = %v, want %v (categories %v)", verdict.Blocked, tt.wantBlocked, verdict.Categories)
+			}
+		})
+	}
+}
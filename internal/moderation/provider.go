@@ -0,0 +1,31 @@
+package moderation
+
+import (
+	"context"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/ai"
+)
+
+// moderationAPI is the subset of ai.Client used for remote classification
+type moderationAPI interface {
+	Moderate(ctx context.Context, text string) (*ai.ModerationResult, error)
+}
+
+// ProviderClassifier classifies text with an AI provider's moderation endpoint
+type ProviderClassifier struct {
+	client moderationAPI
+}
+
+// NewProviderClassifier creates a classifier backed by the provider moderation endpoint
+func NewProviderClassifier(client moderationAPI) *ProviderClassifier {
+	return &ProviderClassifier{client: client}
+}
+
+// Classify implements Classifier
+func (c *ProviderClassifier) Classify(ctx context.Context, text string) (*Result, error) {
+	result, err := c.client.Moderate(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Categories: result.Categories}, nil
+}
@@ -0,0 +1,112 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule masks every match of a pattern with a placeholder naming the kind of data removed
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Built-in rule names
+const (
+	RuleEmail      = "email"
+	RulePhone      = "phone"
+	RuleSSN        = "ssn"
+	RuleCreditCard = "credit_card"
+	RuleAddress    = "street_address"
+)
+
+// defaultRules run in order; more specific patterns come first so that, for example,
+// a card number isn't partially consumed by the phone pattern
+var defaultRules = []Rule{
+	{Name: RuleEmail, Pattern: regexp.MustCompile(`(?i)\b[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}\b`)},
+	{Name: RuleCreditCard, Pattern: regexp.MustCompile(`\b(?:\d[ \-]?){12,15}\d\b`)},
+	{Name: RuleSSN, Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{Name: RulePhone, Pattern: regexp.MustCompile(`(?:\+?\d{1,3}[\s.\-]?)?(?:\(\d{3}\)|\b\d{3})[\s.\-]?\d{3}[\s.\-]?\d{4}\b`)},
+	{Name: RuleAddress, Pattern: regexp.MustCompile(`(?i)\b\d{1,6}\s+(?:[a-z0-9.]+\s+){1,4}(?:street|st|avenue|ave|road|rd|boulevard|blvd|lane|ln|drive|dr|court|ct|way|place|pl|terrace|circle|parkway|pkwy)\b\.?`)},
+}
+
+// Counts records how many matches each rule redacted
+type Counts map[string]int
+
+// Total returns the number of redactions across all rules
+func (c Counts) Total() int {
+	total := 0
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// Add merges other into c
+func (c Counts) Add(other Counts) {
+	for name, n := range other {
+		c[name] += n
+	}
+}
+
+// Redactor masks personally identifiable information in text
+type Redactor struct {
+	rules []Rule
+}
+
+// NewRedactor creates a redactor from the built-in rules plus custom patterns.
+// Custom patterns are given as "name=regex"; a bare regex is named "custom".
+func NewRedactor(customPatterns []string) (*Redactor, error) {
+	r := &Redactor{rules: append([]Rule(nil), defaultRules...)}
+
+	for _, spec := range customPatterns {
+		name, expr := "custom", spec
+		if before, after, ok := strings.Cut(spec, "="); ok && isRuleName(before) {
+			name, expr = before, after
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", name, err)
+		}
+		r.rules = append(r.rules, Rule{Name: name, Pattern: re})
+	}
+
+	return r, nil
+}
+
+// ruleNamePattern matches custom rule names
+var ruleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// isRuleName reports whether s looks like a rule name rather than part of a regex
+func isRuleName(s string) bool {
+	return ruleNamePattern.MatchString(s)
+}
+
+// Redact replaces PII in text with [REDACTED:<rule>] placeholders and reports counts per rule
+func (r *Redactor) Redact(text string) (string, Counts) {
+	counts := Counts{}
+	for _, rule := range r.rules {
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(string) string {
+			counts[rule.Name]++
+			return "[REDACTED:" + rule.Name + "]"
+		})
+	}
+	return text, counts
+}
+
+// RuleNames returns the names of all configured rules, sorted
+func (r *Redactor) RuleNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, rule := range r.rules {
+		if !seen[rule.Name] {
+			seen[rule.Name] = true
+			names = append(names, rule.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
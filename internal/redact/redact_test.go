@@ -0,0 +1,87 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	r, err := NewRedactor([]string{"plate=\\b[A-Z]{3}-\\d{4}\\b"})
+	if err != nil {
+		t.Fatalf("NewRedactor() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		text       string
+		want       string
+		wantCounts Counts
+	}{
+		{
+			name:       "no PII",
+			text:       "Celtics in 6, 100 percent",
+			want:       "Celtics in 6, 100 percent",
+			wantCounts: Counts{},
+		},
+		{
+			name:       "email",
+			text:       "hit me up at dave.smith+bots@example.co.uk",
+			want:       "hit me up at [REDACTED:email]",
+			wantCounts: Counts{RuleEmail: 1},
+		},
+		{
+			name:       "phone numbers",
+			text:       "call (617) 555-0199 or +1 617.555.0123",
+			want:       "call [REDACTED:phone] or [REDACTED:phone]",
+			wantCounts: Counts{RulePhone: 2},
+		},
+		{
+			name:       "ssn",
+			text:       "ssn 123-45-6789 lol",
+			want:       "ssn [REDACTED:ssn] lol",
+			wantCounts: Counts{RuleSSN: 1},
+		},
+		{
+			name:       "card number",
+			text:       "card 4111 1111 1111 1111 exp soon",
+			want:       "card [REDACTED:credit_card] exp soon",
+			wantCounts: Counts{RuleCreditCard: 1},
+		},
+		{
+			name:       "street address",
+			text:       "party at 42 Wallaby Way tonight",
+			want:       "party at [REDACTED:street_address] tonight",
+			wantCounts: Counts{RuleAddress: 1},
+		},
+		{
+			name:       "custom pattern",
+			text:       "my plate is ABC-1234",
+			want:       "my plate is [REDACTED:plate]",
+			wantCounts: Counts{"plate": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, counts := r.Redact(tt.text)
+			if got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+			if counts.Total() != tt.wantCounts.Total() {
+				t.Errorf("Redact() counts = %v, want %v", counts, tt.wantCounts)
+			}
+			for name, n := range tt.wantCounts {
+				if counts[name] != n {
+					t.Errorf("Redact() counts[%s] = %d, want %d", name, counts[name], n)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRedactorInvalidPattern(t *testing.T) {
+	_, err := NewRedactor([]string{"broken=("})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("NewRedactor() error = %v, want error naming the pattern", err)
+	}
+}
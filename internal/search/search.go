@@ -0,0 +1,351 @@
+// Package search keeps an inverted index of archived messages in an embedded bbolt
+// database, so keyword searches with author, channel and date filters are answered
+// locally.
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/boltdb"
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+// MinTermLength is the shortest word that is indexed, in characters
+const MinTermLength = 2
+
+var (
+	// postingsBucket holds one nested bucket per term. Keys are the message ID followed
+	// by the channel ID, so they sort by message age; values are the author ID.
+	postingsBucket = []byte("postings")
+	// docsBucket holds one nested bucket per channel recording the terms of each
+	// indexed message, so its postings can be removed on edit or deletion
+	docsBucket = []byte("docs")
+	// cursorsBucket holds the ID of the newest message indexed in each channel
+	cursorsBucket = []byte("cursors")
+)
+
+// Document is a message to index
+type Document struct {
+	ChannelID string
+	MessageID string
+	AuthorID  string
+	Content   string
+}
+
+// doc is what is stored about an indexed message
+type doc struct {
+	AuthorID string   `json:"author_id"`
+	Terms    []string `json:"terms"`
+}
+
+// Query selects messages that contain every term and match the filters. Empty filters
+// match everything.
+type Query struct {
+	Terms      []string
+	ChannelIDs []string
+	AuthorID   string
+	// BeforeID and AfterID bound the results to messages older or newer than these
+	// snowflakes, exclusively
+	BeforeID string
+	AfterID  string
+	Limit    int
+}
+
+// Result is a matching message
+type Result struct {
+	ChannelID string
+	MessageID string
+}
+
+// Index is an inverted index of messages
+type Index struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the index database at path
+func Open(path string) (*Index, error) {
+	db, err := boltdb.Open(path, "search index", postingsBucket, docsBucket, cursorsBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Close closes the database
+func (x *Index) Close() error {
+	return x.db.Close()
+}
+
+// Terms splits text into the lower-cased, de-duplicated words that are indexed and
+// searched for
+func Terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms []string
+	for _, word := range words {
+		if utf8.RuneCountInString(word) >= MinTermLength && !slices.Contains(terms, word) {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// Cursor returns the ID of the newest message indexed in a channel, or "" if none has been
+func (x *Index) Cursor(channelID string) (string, error) {
+	var cursor string
+	err := x.db.View(func(tx *bolt.Tx) error {
+		cursor = string(tx.Bucket(cursorsBucket).Get([]byte(channelID)))
+		return nil
+	})
+	return cursor, err
+}
+
+// Add indexes a channel's messages, replacing earlier versions of them, and moves the
+// channel's cursor to cursorID
+func (x *Index) Add(channelID string, docs []Document, cursorID string) error {
+	return x.db.Update(func(tx *bolt.Tx) error {
+		for _, d := range docs {
+			if err := put(tx, d); err != nil {
+				return err
+			}
+		}
+		if cursorID == "" {
+			return nil
+		}
+		return tx.Bucket(cursorsBucket).Put([]byte(channelID), []byte(cursorID))
+	})
+}
+
+// Update re-indexes an edited message. Messages that aren't indexed yet are left for
+// the next Add.
+func (x *Index) Update(d Document) error {
+	return x.db.Update(func(tx *bolt.Tx) error {
+		key, err := snowflake.Key(d.MessageID)
+		if err != nil {
+			return err
+		}
+		channel := tx.Bucket(docsBucket).Bucket([]byte(d.ChannelID))
+		if channel == nil || channel.Get(key) == nil {
+			return nil
+		}
+		return put(tx, d)
+	})
+}
+
+// Delete removes messages from the index
+func (x *Index) Delete(channelID string, messageIDs ...string) error {
+	return x.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range messageIDs {
+			key, err := snowflake.Key(id)
+			if err != nil {
+				return err
+			}
+			if err := remove(tx, channelID, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Search returns up to limit matching messages, newest first, and the total number of
+// matches
+func (x *Index) Search(q Query) ([]Result, int, error) {
+	var before, after []byte
+	var err error
+	if q.BeforeID != "" {
+		if before, err = snowflake.Key(q.BeforeID); err != nil {
+			return nil, 0, err
+		}
+	}
+	if q.AfterID != "" {
+		if after, err = snowflake.Key(q.AfterID); err != nil {
+			return nil, 0, err
+		}
+	}
+	inRange := func(id []byte) bool {
+		return (before == nil || bytes.Compare(id, before) < 0) && (after == nil || bytes.Compare(id, after) > 0)
+	}
+	inChannel := func(channelID string) bool {
+		return len(q.ChannelIDs) == 0 || slices.Contains(q.ChannelIDs, channelID)
+	}
+
+	var matches [][]byte
+	err = x.db.View(func(tx *bolt.Tx) error {
+		if len(q.Terms) == 0 {
+			matches, err = x.scanDocs(tx, q, inRange, inChannel)
+			return err
+		}
+
+		// Walk the first term's postings and look the rest up, so only messages holding
+		// every term are kept
+		var postings []*bolt.Bucket
+		for _, term := range q.Terms {
+			bucket := tx.Bucket(postingsBucket).Bucket([]byte(term))
+			if bucket == nil {
+				return nil
+			}
+			postings = append(postings, bucket)
+		}
+		return postings[0].ForEach(func(key, author []byte) error {
+			if !inRange(key[:8]) || !inChannel(string(key[8:])) {
+				return nil
+			}
+			if q.AuthorID != "" && string(author) != q.AuthorID {
+				return nil
+			}
+			for _, other := range postings[1:] {
+				if other.Get(key) == nil {
+					return nil
+				}
+			}
+			matches = append(matches, bytes.Clone(key))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Newest first; keys start with the big-endian message ID
+	slices.SortFunc(matches, func(a, b []byte) int { return bytes.Compare(b, a) })
+
+	results := make([]Result, 0, min(q.Limit, len(matches)))
+	for _, key := range matches[:min(q.Limit, len(matches))] {
+		results = append(results, Result{ChannelID: string(key[8:]), MessageID: snowflake.ID(key[:8])})
+	}
+	return results, len(matches), nil
+}
+
+// scanDocs finds messages by filters alone, for queries without search terms
+func (x *Index) scanDocs(tx *bolt.Tx, q Query, inRange func([]byte) bool, inChannel func(string) bool) ([][]byte, error) {
+	var matches [][]byte
+	err := tx.Bucket(docsBucket).ForEachBucket(func(name []byte) error {
+		if !inChannel(string(name)) {
+			return nil
+		}
+		return tx.Bucket(docsBucket).Bucket(name).ForEach(func(key, value []byte) error {
+			if !inRange(key) {
+				return nil
+			}
+			if q.AuthorID != "" {
+				var d doc
+				if err := json.Unmarshal(value, &d); err != nil {
+					return err
+				}
+				if d.AuthorID != q.AuthorID {
+					return nil
+				}
+			}
+			matches = append(matches, append(bytes.Clone(key), name...))
+			return nil
+		})
+	})
+	return matches, err
+}
+
+// Prune removes messages sent before cutoff and returns how many were removed
+func (x *Index) Prune(cutoff time.Time) (int, error) {
+	limit := snowflake.TimeKey(cutoff)
+	removed := 0
+	err := x.db.Update(func(tx *bolt.Tx) error {
+		// Collect first: deleting while iterating skips keys
+		expired := map[string][][]byte{}
+		err := tx.Bucket(docsBucket).ForEachBucket(func(name []byte) error {
+			expired[string(name)] = boltdb.KeysBefore(tx.Bucket(docsBucket).Bucket(name), limit)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for channelID, keys := range expired {
+			for _, key := range keys {
+				if err := remove(tx, channelID, key); err != nil {
+					return err
+				}
+			}
+			removed += len(keys)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// put indexes a message, first removing the postings of any earlier version
+func put(tx *bolt.Tx, d Document) error {
+	key, err := snowflake.Key(d.MessageID)
+	if err != nil {
+		return err
+	}
+	if err := remove(tx, d.ChannelID, key); err != nil {
+		return err
+	}
+
+	terms := Terms(d.Content)
+	posting := append(bytes.Clone(key), d.ChannelID...)
+	for _, term := range terms {
+		bucket, err := tx.Bucket(postingsBucket).CreateBucketIfNotExists([]byte(term))
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(posting, []byte(d.AuthorID)); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(doc{AuthorID: d.AuthorID, Terms: terms})
+	if err != nil {
+		return err
+	}
+	channel, err := tx.Bucket(docsBucket).CreateBucketIfNotExists([]byte(d.ChannelID))
+	if err != nil {
+		return err
+	}
+	return channel.Put(key, data)
+}
+
+// remove deletes an indexed message and its postings. Terms left without postings are
+// dropped so the index doesn't grow with every word ever typed.
+func remove(tx *bolt.Tx, channelID string, key []byte) error {
+	channel := tx.Bucket(docsBucket).Bucket([]byte(channelID))
+	if channel == nil {
+		return nil
+	}
+	data := channel.Get(key)
+	if data == nil {
+		return nil
+	}
+	var d doc
+	if err := json.Unmarshal(data, &d); err != nil {
+		return fmt.Errorf("failed to decode indexed message %s: %w", snowflake.ID(key), err)
+	}
+
+	posting := append(bytes.Clone(key), channelID...)
+	for _, term := range d.Terms {
+		bucket := tx.Bucket(postingsBucket).Bucket([]byte(term))
+		if bucket == nil {
+			continue
+		}
+		if err := bucket.Delete(posting); err != nil {
+			return err
+		}
+		if first, _ := bucket.Cursor().First(); first == nil {
+			if err := tx.Bucket(postingsBucket).DeleteBucket([]byte(term)); err != nil {
+				return err
+			}
+		}
+	}
+	return channel.Delete(key)
+}
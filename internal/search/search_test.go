@@ -0,0 +1,141 @@
+package search
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+func openTestIndex(t *testing.T) (*Index, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "search.db")
+	x, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { x.Close() })
+	return x, path
+}
+
+func resultIDs(results []Result) []string {
+	ids := []string{}
+	for _, r := range results {
+		ids = append(ids, r.MessageID)
+	}
+	return ids
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "The Vegas trip, the VEGAS trip!", want: []string{"the", "vegas", "trip"}},
+		{text: "a b c", want: nil},
+		{text: "don't re-book", want: []string{"don", "re", "book"}},
+		{text: "Café 2024", want: []string{"café", "2024"}},
+	}
+
+	for _, tt := range tests {
+		if got := Terms(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	x, _ := openTestIndex(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ids := make([]string, 5)
+	for i := range ids {
+		ids[i] = snowflake.At(start.Add(time.Duration(i) * time.Hour))
+	}
+
+	err := x.Add("general", []Document{
+		{ChannelID: "general", MessageID: ids[0], AuthorID: "dave", Content: "Vegas trip is in March"},
+		{ChannelID: "general", MessageID: ids[1], AuthorID: "erin", Content: "booking the vegas hotel"},
+		{ChannelID: "general", MessageID: ids[3], AuthorID: "dave", Content: "what about the trip to Vegas?"},
+	}, ids[3])
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := x.Add("random", []Document{
+		{ChannelID: "random", MessageID: ids[2], AuthorID: "erin", Content: "vegas trip memes"},
+		{ChannelID: "random", MessageID: ids[4], AuthorID: "gus", Content: "pizza tonight"},
+	}, ids[4]); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		query     Query
+		want      []string
+		wantTotal int
+	}{
+		{name: "every term must match", query: Query{Terms: []string{"vegas", "trip"}, Limit: 10}, want: []string{ids[3], ids[2], ids[0]}, wantTotal: 3},
+		{name: "limit keeps the newest", query: Query{Terms: []string{"vegas"}, Limit: 2}, want: []string{ids[3], ids[2]}, wantTotal: 4},
+		{name: "from", query: Query{Terms: []string{"vegas"}, AuthorID: "erin", Limit: 10}, want: []string{ids[2], ids[1]}, wantTotal: 2},
+		{name: "in", query: Query{Terms: []string{"vegas"}, ChannelIDs: []string{"general"}, Limit: 10}, want: []string{ids[3], ids[1], ids[0]}, wantTotal: 3},
+		{name: "before and after", query: Query{Terms: []string{"vegas"}, AfterID: ids[0], BeforeID: ids[3], Limit: 10}, want: []string{ids[2], ids[1]}, wantTotal: 2},
+		{name: "unknown term", query: Query{Terms: []string{"vegas", "skiing"}, Limit: 10}, want: []string{}, wantTotal: 0},
+		{name: "filters only", query: Query{AuthorID: "dave", Limit: 10}, want: []string{ids[3], ids[0]}, wantTotal: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := x.Search(tt.query)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if gotIDs := resultIDs(got); !slices.Equal(gotIDs, tt.want) || total != tt.wantTotal {
+				t.Errorf("Search() = %v (%d total), want %v (%d total)", gotIDs, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestUpdateDeleteAndPrune(t *testing.T) {
+	x, path := openTestIndex(t)
+	now := time.Now()
+	old, recent, edited := snowflake.At(now.AddDate(0, 0, -10)), snowflake.At(now.Add(-time.Hour)), snowflake.At(now)
+
+	err := x.Add("general", []Document{
+		{ChannelID: "general", MessageID: old, AuthorID: "dave", Content: "old vegas plans"},
+		{ChannelID: "general", MessageID: recent, AuthorID: "dave", Content: "new vegas plans"},
+		{ChannelID: "general", MessageID: edited, AuthorID: "erin", Content: "vegas typo"},
+	}, edited)
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// Edits replace the old terms; edits of unindexed messages are ignored
+	x.Update(Document{ChannelID: "general", MessageID: edited, AuthorID: "erin", Content: "reno instead"})
+	x.Update(Document{ChannelID: "general", MessageID: snowflake.At(now.Add(time.Minute)), AuthorID: "erin", Content: "reno again"})
+
+	if removed, err := x.Prune(now.AddDate(0, 0, -1)); err != nil || removed != 1 {
+		t.Errorf("Prune() = %d, %v, want 1", removed, err)
+	}
+	if err := x.Delete("general", recent); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	x.Close()
+	x, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer x.Close()
+
+	if got, total, _ := x.Search(Query{Terms: []string{"vegas"}, Limit: 10}); total != 0 {
+		t.Errorf("Search(vegas) = %v, want nothing", resultIDs(got))
+	}
+	if got, _, _ := x.Search(Query{Terms: []string{"reno"}, Limit: 10}); !slices.Equal(resultIDs(got), []string{edited}) {
+		t.Errorf("Search(reno) = %v, want the edited message", resultIDs(got))
+	}
+	if cursor, _ := x.Cursor("general"); cursor != edited {
+		t.Errorf("Cursor() = %q, want %q", cursor, edited)
+	}
+}
@@ -0,0 +1,69 @@
+package settings
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Opt-out categories members can withdraw consent from
+const (
+	OptOutRoast    = "roast"
+	OptOutAnalysis = "analysis"
+)
+
+// OptOutCategories lists every opt-out category
+var OptOutCategories = []string{OptOutRoast, OptOutAnalysis}
+
+// ParseOptOutCategories converts a user-supplied category ("roast", "analysis" or "all")
+// to the list of categories it covers
+func ParseOptOutCategories(s string) ([]string, error) {
+	category := strings.ToLower(strings.TrimSpace(s))
+	if category == "all" {
+		return OptOutCategories, nil
+	}
+	if slices.Contains(OptOutCategories, category) {
+		return []string{category}, nil
+	}
+	return nil, fmt.Errorf("unknown category %q (want roast, analysis or all)", s)
+}
+
+// IsOptedOut reports whether a user has opted out of a category
+func (gs GuildSettings) IsOptedOut(userID, category string) bool {
+	return slices.Contains(gs.OptOuts[category], userID)
+}
+
+// SetOptOut records or clears a user's opt-out for a category
+func (gs *GuildSettings) SetOptOut(userID, category string, optedOut bool) {
+	users := slices.DeleteFunc(gs.OptOuts[category], func(id string) bool { return id == userID })
+	if optedOut {
+		users = append(users, userID)
+	}
+
+	if gs.OptOuts == nil {
+		gs.OptOuts = make(map[string][]string)
+	}
+	if len(users) == 0 {
+		delete(gs.OptOuts, category)
+		return
+	}
+	gs.OptOuts[category] = users
+}
+
+// HasProtectedRole reports whether any of the given roles is protected
+func (gs GuildSettings) HasProtectedRole(roleIDs []string) bool {
+	for _, roleID := range roleIDs {
+		if slices.Contains(gs.ProtectedRoles, roleID) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetProtectedRole adds or removes a role from the protected list
+func (gs *GuildSettings) SetProtectedRole(roleID string, protected bool) {
+	gs.ProtectedRoles = slices.DeleteFunc(gs.ProtectedRoles, func(id string) bool { return id == roleID })
+	if protected {
+		gs.ProtectedRoles = append(gs.ProtectedRoles, roleID)
+	}
+}
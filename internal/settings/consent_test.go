@@ -0,0 +1,77 @@
+package settings
+
+import "testing"
+
+func TestParseOptOutCategories(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "roast", input: "roast", want: []string{OptOutRoast}},
+		{name: "analysis uppercase", input: "ANALYSIS", want: []string{OptOutAnalysis}},
+		{name: "all", input: "all", want: []string{OptOutRoast, OptOutAnalysis}},
+		{name: "unknown", input: "everything", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOptOutCategories(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOptOutCategories() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseOptOutCategories() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ParseOptOutCategories()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetOptOut(t *testing.T) {
+	var gs GuildSettings
+
+	gs.SetOptOut("alice", OptOutRoast, true)
+	gs.SetOptOut("alice", OptOutRoast, true) // idempotent
+	gs.SetOptOut("bob", OptOutAnalysis, true)
+
+	if !gs.IsOptedOut("alice", OptOutRoast) {
+		t.Error("alice should be opted out of roasts")
+	}
+	if gs.IsOptedOut("alice", OptOutAnalysis) {
+		t.Error("alice should not be opted out of analysis")
+	}
+	if got := len(gs.OptOuts[OptOutRoast]); got != 1 {
+		t.Errorf("roast opt-out count = %d, want 1", got)
+	}
+
+	gs.SetOptOut("alice", OptOutRoast, false)
+	if gs.IsOptedOut("alice", OptOutRoast) {
+		t.Error("alice should be opted back in to roasts")
+	}
+	if _, ok := gs.OptOuts[OptOutRoast]; ok {
+		t.Error("empty category should be removed")
+	}
+}
+
+func TestProtectedRoles(t *testing.T) {
+	var gs GuildSettings
+	gs.SetProtectedRole("mods", true)
+
+	if !gs.HasProtectedRole([]string{"everyone", "mods"}) {
+		t.Error("member with mods role should be protected")
+	}
+	if gs.HasProtectedRole([]string{"everyone"}) {
+		t.Error("member without protected role should not be protected")
+	}
+
+	gs.SetProtectedRole("mods", false)
+	if gs.HasProtectedRole([]string{"mods"}) {
+		t.Error("role should no longer be protected")
+	}
+}
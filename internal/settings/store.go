@@ -0,0 +1,84 @@
+package settings
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/storage"
+)
+
+// GuildSettings holds per-guild overrides. Zero values mean "use the bot default".
+type GuildSettings struct {
+	ModerationLevel   string `json:"moderation_level,omitempty"`
+	MaxRoastIntensity string `json:"max_roast_intensity,omitempty"`
+	PIIRedaction      string `json:"pii_redaction,omitempty"` // "on", "off" or empty for the bot default
+
+	// OptOuts maps an opt-out category to the IDs of users who opted out of it
+	OptOuts map[string][]string `json:"opt_outs,omitempty"`
+	// ProtectedRoles are role IDs whose members can never be roasted or analyzed
+	ProtectedRoles []string `json:"protected_roles,omitempty"`
+}
+
+// clone returns a deep copy so callers can't race with later updates
+func (gs *GuildSettings) clone() GuildSettings {
+	c := *gs
+	if gs.OptOuts != nil {
+		c.OptOuts = make(map[string][]string, len(gs.OptOuts))
+		for category, users := range gs.OptOuts {
+			c.OptOuts[category] = slices.Clone(users)
+		}
+	}
+	c.ProtectedRoles = slices.Clone(gs.ProtectedRoles)
+	return c
+}
+
+// Store keeps per-guild settings in memory and persists them to a JSON file
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	guilds map[string]*GuildSettings
+}
+
+// NewStore creates a settings store backed by the file at path, loading any existing settings
+func NewStore(path string) (*Store, error) {
+	store := &Store{
+		path:   path,
+		guilds: make(map[string]*GuildSettings),
+	}
+
+	if err := storage.LoadJSON(path, &store.guilds); err != nil {
+		return nil, fmt.Errorf("failed to load guild settings: %w", err)
+	}
+
+	return store, nil
+}
+
+// Guild returns a copy of the settings for a guild
+func (s *Store) Guild(guildID string) GuildSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if gs, ok := s.guilds[guildID]; ok {
+		return gs.clone()
+	}
+	return GuildSettings{}
+}
+
+// UpdateGuild applies fn to a guild's settings and persists the result
+func (s *Store) UpdateGuild(guildID string, fn func(*GuildSettings)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gs, ok := s.guilds[guildID]
+	if !ok {
+		gs = &GuildSettings{}
+		s.guilds[guildID] = gs
+	}
+	fn(gs)
+
+	if s.path == "" {
+		return nil
+	}
+	return storage.SaveJSON(s.path, s.guilds)
+}
@@ -0,0 +1,38 @@
+package settings
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStorePersistsGuildSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guild_settings.json")
+
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	if got := store.Guild("guild-1"); got.ModerationLevel != "" {
+		t.Errorf("Guild() on empty store = %+v, want zero value", got)
+	}
+
+	err = store.UpdateGuild("guild-1", func(gs *GuildSettings) {
+		gs.ModerationLevel = "strict"
+	})
+	if err != nil {
+		t.Fatalf("UpdateGuild() error = %v", err)
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore() reload error = %v", err)
+	}
+
+	if got := reloaded.Guild("guild-1").ModerationLevel; got != "strict" {
+		t.Errorf("reloaded ModerationLevel = %q, want %q", got, "strict")
+	}
+	if got := reloaded.Guild("guild-2").ModerationLevel; got != "" {
+		t.Errorf("unrelated guild ModerationLevel = %q, want empty", got)
+	}
+}
@@ -0,0 +1,62 @@
+// Package snowflake converts Discord snowflake IDs to and from database keys that sort
+// by message age, and finds the snowflakes that bound a point in time.
+package snowflake
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Epoch is the first millisecond of 2015, the zero point of Discord snowflakes
+const Epoch = 1420070400000
+
+// Key encodes a snowflake as a big-endian key so keys sort by message age
+func Key(id string) ([]byte, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message ID %q: %w", id, err)
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, n)
+	return key, nil
+}
+
+// ID decodes a key back into a snowflake
+func ID(key []byte) string {
+	return strconv.FormatUint(binary.BigEndian.Uint64(key), 10)
+}
+
+// Prev returns the snowflake just before id, so reads of messages after it start at id
+func Prev(id string) (string, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid message ID %q: %w", id, err)
+	}
+	if n == 0 {
+		return id, nil
+	}
+	return strconv.FormatUint(n-1, 10), nil
+}
+
+// TimeKey returns the key of the first possible snowflake at t
+func TimeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, at(t))
+	return key
+}
+
+// At returns the smallest snowflake Discord could assign at t, for paging history by time
+func At(t time.Time) string {
+	return strconv.FormatUint(at(t), 10)
+}
+
+// at returns the first snowflake at t, or zero before the epoch
+func at(t time.Time) uint64 {
+	ms := t.UnixMilli() - Epoch
+	if ms <= 0 {
+		return 0
+	}
+	return uint64(ms) << 22
+}
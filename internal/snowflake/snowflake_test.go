@@ -0,0 +1,70 @@
+package snowflake
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestKeyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{name: "message ID", id: "1234567890123456789"},
+		{name: "zero", id: "0"},
+		{name: "not a number", id: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := Key(tt.id)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Key(%q) succeeded, want error", tt.id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Key(%q) error = %v", tt.id, err)
+			}
+			if got := ID(key); got != tt.id {
+				t.Errorf("ID(Key(%q)) = %q", tt.id, got)
+			}
+		})
+	}
+}
+
+func TestTimeKeyOrdersByAge(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier, _ := Key(At(at.Add(-time.Second)))
+	later, _ := Key(At(at.Add(time.Second)))
+	limit := TimeKey(at)
+
+	if bytes.Compare(earlier, limit) >= 0 || bytes.Compare(later, limit) < 0 {
+		t.Errorf("TimeKey(%v) doesn't fall between the snowflakes around it", at)
+	}
+	if got := At(time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)); got != "0" {
+		t.Errorf("At(before the epoch) = %q, want 0", got)
+	}
+}
+
+func TestPrev(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{id: "1234567890123456789", want: "1234567890123456788"},
+		{id: "0", want: "0"},
+		{id: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Prev(tt.id)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Prev(%q) = %q, %v, want %q", tt.id, got, err, tt.want)
+		}
+	}
+}
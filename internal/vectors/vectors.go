@@ -0,0 +1,199 @@
+// Package vectors stores embeddings of archived messages in an embedded bbolt database
+// and finds the messages closest in meaning to a query.
+package vectors
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/boltdb"
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+var (
+	// vectorsBucket holds one nested bucket of vectors per channel, keyed by message ID
+	vectorsBucket = []byte("vectors")
+	// cursorsBucket holds the ID of the newest message indexed in each channel
+	cursorsBucket = []byte("cursors")
+)
+
+// Entry is the embedding of one message
+type Entry struct {
+	MessageID string
+	Vector    []float32
+}
+
+// Match is a message found by Search
+type Match struct {
+	ChannelID string
+	MessageID string
+	// Score is the cosine similarity between the message and the query
+	Score float32
+}
+
+// Index stores message embeddings per channel
+type Index struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the index database at path
+func Open(path string) (*Index, error) {
+	db, err := boltdb.Open(path, "vector index", vectorsBucket, cursorsBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Close closes the database
+func (x *Index) Close() error {
+	return x.db.Close()
+}
+
+// Cursor returns the ID of the newest message indexed in a channel, or "" if none has been
+func (x *Index) Cursor(channelID string) (string, error) {
+	var cursor string
+	err := x.db.View(func(tx *bolt.Tx) error {
+		cursor = string(tx.Bucket(cursorsBucket).Get([]byte(channelID)))
+		return nil
+	})
+	return cursor, err
+}
+
+// Add stores embeddings for a channel's messages and moves the channel's cursor to
+// cursorID. Messages that were skipped still advance the cursor, so they aren't
+// considered again.
+func (x *Index) Add(channelID string, entries []Entry, cursorID string) error {
+	return x.db.Update(func(tx *bolt.Tx) error {
+		channel, err := tx.Bucket(vectorsBucket).CreateBucketIfNotExists([]byte(channelID))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			key, err := snowflake.Key(entry.MessageID)
+			if err != nil {
+				return err
+			}
+			if err := channel.Put(key, encode(normalize(entry.Vector))); err != nil {
+				return err
+			}
+		}
+		if cursorID == "" {
+			return nil
+		}
+		return tx.Bucket(cursorsBucket).Put([]byte(channelID), []byte(cursorID))
+	})
+}
+
+// Delete removes the embeddings of deleted messages
+func (x *Index) Delete(channelID string, messageIDs ...string) error {
+	return x.db.Update(func(tx *bolt.Tx) error {
+		channel := tx.Bucket(vectorsBucket).Bucket([]byte(channelID))
+		if channel == nil {
+			return nil
+		}
+		for _, id := range messageIDs {
+			key, err := snowflake.Key(id)
+			if err != nil {
+				return err
+			}
+			if err := channel.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Search returns up to limit messages from the given channels most similar to query, best
+// first. Vectors of a different dimension, left behind by an earlier embedding model, are
+// skipped.
+func (x *Index) Search(channelIDs []string, query []float32, limit int) ([]Match, error) {
+	query = normalize(query)
+	var matches []Match
+	err := x.db.View(func(tx *bolt.Tx) error {
+		for _, channelID := range channelIDs {
+			channel := tx.Bucket(vectorsBucket).Bucket([]byte(channelID))
+			if channel == nil {
+				continue
+			}
+			err := channel.ForEach(func(key, value []byte) error {
+				if len(value) != 4*len(query) {
+					return nil
+				}
+				matches = append(matches, Match{
+					ChannelID: channelID,
+					MessageID: snowflake.ID(key),
+					Score:     dot(query, value),
+				})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// Prune deletes embeddings of messages sent before cutoff and returns how many were removed
+func (x *Index) Prune(cutoff time.Time) (int, error) {
+	limit := snowflake.TimeKey(cutoff)
+	removed := 0
+	err := x.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(vectorsBucket).ForEachBucket(func(name []byte) error {
+			n, err := boltdb.DeleteBefore(tx.Bucket(vectorsBucket).Bucket(name), limit)
+			removed += n
+			return err
+		})
+	})
+	return removed, err
+}
+
+// normalize scales v to unit length, so a dot product gives cosine similarity
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, f := range v {
+		out[i] = f / norm
+	}
+	return out
+}
+
+// encode packs a vector as little-endian float32s
+func encode(v []float32) []byte {
+	data := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(f))
+	}
+	return data
+}
+
+// dot multiplies a query with an encoded vector of the same length
+func dot(query []float32, data []byte) float32 {
+	var sum float32
+	for i, f := range query {
+		sum += f * math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return sum
+}
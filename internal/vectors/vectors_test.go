@@ -0,0 +1,115 @@
+package vectors
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Dmetrikx/goDiscordChatter/internal/snowflake"
+)
+
+func openTestIndex(t *testing.T) (*Index, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vectors.db")
+	x, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { x.Close() })
+	return x, path
+}
+
+func messageIDs(matches []Match) []string {
+	ids := []string{}
+	for _, m := range matches {
+		ids = append(ids, m.MessageID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	x, _ := openTestIndex(t)
+	err := x.Add("general", []Entry{
+		{MessageID: "1", Vector: []float32{1, 0, 0}},
+		{MessageID: "2", Vector: []float32{3, 3, 0}},
+		{MessageID: "3", Vector: []float32{0, 0, 2}},
+	}, "3")
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := x.Add("random", []Entry{
+		{MessageID: "4", Vector: []float32{0.9, 0.1, 0}},
+		{MessageID: "5", Vector: []float32{1, 0}},
+	}, "5"); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		channels []string
+		query    []float32
+		limit    int
+		want     []string
+	}{
+		{name: "ranked by similarity", channels: []string{"general", "random"}, query: []float32{2, 0, 0}, limit: 3, want: []string{"1", "4", "2"}},
+		{name: "only the given channels", channels: []string{"general"}, query: []float32{1, 0, 0}, limit: 2, want: []string{"1", "2"}},
+		{name: "unknown channel", channels: []string{"missing"}, query: []float32{1, 0, 0}, limit: 2, want: []string{}},
+		{name: "other dimensions skipped", channels: []string{"random"}, query: []float32{1, 0}, limit: 5, want: []string{"5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := x.Search(tt.channels, tt.query, tt.limit)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if ids := messageIDs(got); !slices.Equal(ids, tt.want) {
+				t.Errorf("Search() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	if got, _ := x.Search([]string{"general"}, []float32{1, 0, 0}, 1); got[0].Score < 0.999 {
+		t.Errorf("score of an identical direction = %v, want 1", got[0].Score)
+	}
+}
+
+func TestCursorDeleteAndPrune(t *testing.T) {
+	x, path := openTestIndex(t)
+	now := time.Now()
+	old, recent := snowflake.At(now.AddDate(0, 0, -10)), snowflake.At(now)
+
+	if cursor, _ := x.Cursor("general"); cursor != "" {
+		t.Errorf("Cursor() of a new channel = %q, want empty", cursor)
+	}
+	err := x.Add("general", []Entry{
+		{MessageID: old, Vector: []float32{1, 0}},
+		{MessageID: recent, Vector: []float32{0, 1}},
+	}, recent)
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	removed, err := x.Prune(now.AddDate(0, 0, -1))
+	if err != nil || removed != 1 {
+		t.Errorf("Prune() = %d, %v, want 1", removed, err)
+	}
+	if err := x.Delete("general", recent); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	x.Close()
+	x, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer x.Close()
+
+	if cursor, _ := x.Cursor("general"); cursor != recent {
+		t.Errorf("Cursor() after reopen = %q, want %q", cursor, recent)
+	}
+	if got, _ := x.Search([]string{"general"}, []float32{1, 1}, 5); len(got) != 0 {
+		t.Errorf("Search() after delete and prune = %v, want nothing", messageIDs(got))
+	}
+}
@@ -0,0 +1,52 @@
+package webfetch
+
+import "fmt"
+
+// BlockedError is returned when a URL or address is refused by the SSRF guard
+type BlockedError struct {
+	Target string
+	Reason string
+}
+
+// NewBlockedError creates a new blocked error
+func NewBlockedError(target, reason string) *BlockedError {
+	return &BlockedError{
+		Target: target,
+		Reason: reason,
+	}
+}
+
+// Error implements the error interface
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("refusing to fetch %s: %s", e.Target, e.Reason)
+}
+
+// StatusError is returned when the remote server responds with a non-200 status
+type StatusError struct {
+	StatusCode int
+}
+
+// NewStatusError creates a new status error
+func NewStatusError(statusCode int) *StatusError {
+	return &StatusError{StatusCode: statusCode}
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
+
+// TooLargeError is returned when a response body exceeds the size limit
+type TooLargeError struct {
+	Limit int64
+}
+
+// NewTooLargeError creates a new too-large error
+func NewTooLargeError(limit int64) *TooLargeError {
+	return &TooLargeError{Limit: limit}
+}
+
+// Error implements the error interface
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
@@ -0,0 +1,156 @@
+package webfetch
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Article holds the readable content extracted from an HTML page
+type Article struct {
+	Title string
+	Text  string
+}
+
+// skippedElements are never part of the readable article body
+var skippedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Head:     true,
+}
+
+// blockElements start a new line in the extracted text
+var blockElements = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Div:        true,
+	atom.Br:         true,
+	atom.Li:         true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Blockquote: true,
+	atom.Pre:        true,
+	atom.Tr:         true,
+	atom.Section:    true,
+	atom.Article:    true,
+	atom.Main:       true,
+	atom.Figcaption: true,
+}
+
+// ExtractArticle parses an HTML document and returns its title and readable text.
+// When the page has an <article> or <main> element only that subtree is used,
+// otherwise the whole <body> is used. Navigation, scripts and other chrome are dropped.
+func ExtractArticle(body []byte) (*Article, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	article := &Article{}
+	if titleNode := findFirst(doc, atom.Title); titleNode != nil {
+		article.Title = collapseSpaces(textContent(titleNode))
+	}
+
+	root := findFirst(doc, atom.Article)
+	if root == nil {
+		root = findFirst(doc, atom.Main)
+	}
+	if root == nil {
+		root = findFirst(doc, atom.Body)
+	}
+	if root == nil {
+		root = doc
+	}
+
+	var sb strings.Builder
+	writeReadableText(&sb, root)
+	article.Text = normalizeLines(sb.String())
+
+	return article, nil
+}
+
+// findFirst returns the first element node with the given atom in document order
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent concatenates all text nodes below n
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			sb.WriteString(node.Data)
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// writeReadableText writes the visible text below n, inserting newlines at block boundaries
+func writeReadableText(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if skippedElements[n.DataAtom] {
+			return
+		}
+		if blockElements[n.DataAtom] {
+			sb.WriteString("\n")
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeReadableText(sb, c)
+	}
+
+	if n.Type == html.ElementNode && blockElements[n.DataAtom] {
+		sb.WriteString("\n")
+	}
+}
+
+// normalizeLines collapses whitespace within lines and drops empty lines
+func normalizeLines(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = collapseSpaces(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// collapseSpaces replaces runs of whitespace with a single space
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
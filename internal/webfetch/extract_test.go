@@ -0,0 +1,57 @@
+package webfetch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractArticle(t *testing.T) {
+	tests := []struct {
+		name        string
+		html        string
+		wantTitle   string
+		wantText    string
+		wantMissing []string
+	}{
+		{
+			name: "prefers article element",
+			html: `<html><head><title> Big News </title><script>var x = 1;</script></head>
+<body><nav>Home | About</nav><article><h1>Headline</h1><p>First   paragraph.</p><p>Second paragraph.</p></article>
+<footer>Copyright</footer></body></html>`,
+			wantTitle:   "Big News",
+			wantText:    "Headline\nFirst paragraph.\nSecond paragraph.",
+			wantMissing: []string{"Home", "Copyright", "var x"},
+		},
+		{
+			name:        "falls back to body",
+			html:        `<html><body><div>Hello <b>world</b></div><style>p{}</style><div>Bye</div></body></html>`,
+			wantText:    "Hello world\nBye",
+			wantMissing: []string{"p{}"},
+		},
+		{
+			name:     "empty document",
+			html:     ``,
+			wantText: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractArticle([]byte(tt.html))
+			if err != nil {
+				t.Fatalf("ExtractArticle() error = %v", err)
+			}
+			if got.Title != tt.wantTitle {
+				t.Errorf("ExtractArticle() title = %q, want %q", got.Title, tt.wantTitle)
+			}
+			if got.Text != tt.wantText {
+				t.Errorf("ExtractArticle() text = %q, want %q", got.Text, tt.wantText)
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(got.Text, missing) {
+					t.Errorf("ExtractArticle() text contains %q, want it stripped", missing)
+				}
+			}
+		})
+	}
+}
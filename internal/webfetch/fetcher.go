@@ -0,0 +1,199 @@
+package webfetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Default fetch limits
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxBodyBytes = 10 << 20 // 10 MiB
+	MaxRedirects        = 5
+)
+
+// Response holds the result of a successful fetch
+type Response struct {
+	URL         string
+	ContentType string
+	Body        []byte
+}
+
+// Fetcher downloads remote resources while refusing to connect to private,
+// loopback, link-local or otherwise internal addresses (SSRF protection).
+// The address check runs on every dial, so redirects and DNS rebinding
+// cannot be used to reach internal hosts.
+type Fetcher struct {
+	httpClient   *http.Client
+	maxBodyBytes int64
+}
+
+// NewFetcher creates a new SSRF-safe fetcher
+func NewFetcher(timeout time.Duration, maxBodyBytes int64) *Fetcher {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkDialAddress(address)
+		},
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil, // a proxy would bypass the dial-time address check
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Fetcher{
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= MaxRedirects {
+					return fmt.Errorf("stopped after %d redirects", MaxRedirects)
+				}
+				return validateURL(req.URL)
+			},
+		},
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// Fetch performs a GET request and returns the response body, capped at the
+// fetcher's maximum body size
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := validateURL(parsed); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", parsed.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Coonbot/1.0)")
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", parsed.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewStatusError(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(body)) > f.maxBodyBytes {
+		return nil, NewTooLargeError(f.maxBodyBytes)
+	}
+
+	return &Response{
+		URL:         resp.Request.URL.String(),
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        body,
+	}, nil
+}
+
+// validateURL rejects non-HTTP schemes and hosts that are literal internal addresses
+func validateURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return NewBlockedError(u.String(), "only http and https URLs are allowed")
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return NewBlockedError(u.String(), "missing host")
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return NewBlockedError(host, "loopback host")
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return NewBlockedError(host, "internal address")
+	}
+
+	return nil
+}
+
+// checkDialAddress verifies the resolved address right before connecting
+func checkDialAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return NewBlockedError(address, "malformed address")
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return NewBlockedError(host, "unresolved address")
+	}
+
+	if !isPublicAddr(addr) {
+		return NewBlockedError(host, "internal address")
+	}
+
+	return nil
+}
+
+// isPublicAddr reports whether addr is a globally routable unicast address
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() ||
+		addr.IsUnspecified() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// blockedPrefixes lists special-purpose ranges not covered by the netip helpers
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, may map to internal IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("2002::/16"),      // 6to4, may embed internal IPv4
+	netip.MustParsePrefix("fec0::/10"),      // deprecated site-local
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+	netip.MustParsePrefix("100::/64"),       // discard-only
+	netip.MustParsePrefix("255.255.255.255/32"),
+}
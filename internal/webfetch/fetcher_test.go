@@ -0,0 +1,85 @@
+package webfetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		want bool
+	}{
+		{name: "public IPv4", addr: "93.184.216.34", want: true},
+		{name: "public IPv6", addr: "2606:4700::1111", want: true},
+		{name: "loopback", addr: "127.0.0.1", want: false},
+		{name: "private 10/8", addr: "10.1.2.3", want: false},
+		{name: "private 192.168/16", addr: "192.168.1.1", want: false},
+		{name: "link-local metadata", addr: "169.254.169.254", want: false},
+		{name: "unspecified", addr: "0.0.0.0", want: false},
+		{name: "carrier-grade NAT", addr: "100.64.0.1", want: false},
+		{name: "IPv6 loopback", addr: "::1", want: false},
+		{name: "IPv6 unique local", addr: "fd00::1", want: false},
+		{name: "IPv4-mapped loopback", addr: "::ffff:127.0.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := isPublicAddr(netip.MustParseAddr(tt.addr))
+			if got != tt.want {
+				t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		wantErr bool
+	}{
+		{name: "https host", rawURL: "https://example.com/article", wantErr: false},
+		{name: "http host", rawURL: "http://example.com", wantErr: false},
+		{name: "file scheme", rawURL: "file:///etc/passwd", wantErr: true},
+		{name: "ftp scheme", rawURL: "ftp://example.com", wantErr: true},
+		{name: "localhost", rawURL: "http://localhost:8080", wantErr: true},
+		{name: "literal loopback", rawURL: "http://127.0.0.1/admin", wantErr: true},
+		{name: "metadata endpoint", rawURL: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "literal IPv6 loopback", rawURL: "http://[::1]/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.rawURL)
+			if err != nil {
+				t.Fatalf("url.Parse() error = %v", err)
+			}
+			err = validateURL(u)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateURL(%s) error = %v, wantErr %v", tt.rawURL, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchRefusesLoopbackServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(0, 0)
+	_, err := fetcher.Fetch(context.Background(), server.URL)
+
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("Fetch() error = %v, want BlockedError", err)
+	}
+}